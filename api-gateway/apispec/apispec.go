@@ -0,0 +1,311 @@
+package apispec
+
+import (
+	"blog/api-gateway/config"
+	"blog/api-gateway/routes"
+	"blog/shared/openapi"
+	"blog/shared/validation"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// GatewayService 网关自身文档的名称，文档中的路径已经是对外路径
+const GatewayService = "api-gateway"
+
+// maxValidateBodySize 校验时读取的最大请求体，更大的请求体只校验路径和查询参数
+const maxValidateBodySize = 1 << 20
+
+var defineFormatsOnce sync.Once
+
+// Registry 各服务的OpenAPI文档，用于校验转发的请求和对外提供文档
+type Registry struct {
+	specs map[string]*serviceSpec
+}
+
+// serviceSpec 单个服务的文档和按文档路径匹配的路由器
+type serviceSpec struct {
+	doc    *openapi3.T
+	router routers.Router
+}
+
+// NewRegistry 加载所有服务的文档
+func NewRegistry() (*Registry, error) {
+	// kin-openapi 默认不校验 email 格式，与服务端 binding:"email" 保持一致
+	defineFormatsOnce.Do(func() {
+		openapi3.DefineStringFormatValidator("email", openapi3.NewRegexpFormatValidator(openapi3.FormatOfStringForEmail))
+	})
+
+	r := &Registry{specs: make(map[string]*serviceSpec)}
+	for _, service := range openapi.Services() {
+		doc, err := openapi.Load(service)
+		if err != nil {
+			return nil, err
+		}
+		router, err := gorillamux.NewRouter(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build router for %s: %v", service, err)
+		}
+		r.specs[service] = &serviceSpec{doc: doc, router: router}
+	}
+	return r, nil
+}
+
+// Services 返回有文档的服务名，按名称排序
+func (r *Registry) Services() []string {
+	services := make([]string, 0, len(r.specs))
+	for service := range r.specs {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// Title 返回服务文档的标题
+func (r *Registry) Title(service string) string {
+	spec, ok := r.specs[service]
+	if !ok || spec.doc.Info == nil {
+		return service
+	}
+	return spec.doc.Info.Title
+}
+
+// Validate 按上游服务的文档校验请求的路径参数、查询参数和请求体
+// upstreamPath 为路由重写后的路径；文档中没有的接口不校验，由服务自行处理
+// 请求体读取后会还原，不影响后续转发
+func (r *Registry) Validate(req *http.Request, service, upstreamPath string) []validation.FieldError {
+	spec, ok := r.specs[service]
+	if !ok {
+		return nil
+	}
+
+	in := req.Clone(req.Context())
+	in.URL.Path = upstreamPath
+	in.URL.RawPath = ""
+	route, pathParams, err := spec.router.FindRoute(in)
+	if err != nil {
+		return nil
+	}
+
+	options := &openapi3filter.Options{
+		MultiError:          true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc, // 认证已由网关完成
+		SkipSettingDefaults: true,
+	}
+	switch {
+	case req.ContentLength > 0 && req.ContentLength <= maxValidateBodySize:
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return []validation.FieldError{{In: validation.InBody, Rule: "body", Message: "failed to read request body"}}
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		in.Body = io.NopCloser(bytes.NewReader(body))
+		// 服务按JSON解析请求体而不检查Content-Type，这里保持一致
+		if in.Header.Get("Content-Type") == "" {
+			in.Header.Set("Content-Type", "application/json")
+		}
+	case req.ContentLength != 0:
+		options.ExcludeRequestBody = true
+	default:
+		in.Body = http.NoBody
+	}
+
+	err = openapi3filter.ValidateRequest(req.Context(), &openapi3filter.RequestValidationInput{
+		Request:    in,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    options,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var fields []validation.FieldError
+	collectFieldErrors(err, &fields)
+	return fields
+}
+
+// Document 返回服务文档的副本，路径换算为经网关访问的路径
+// 通过路由表无法访问的接口（如服务自身的健康检查）不出现在文档中，
+// 是否需要认证以路由表为准
+func (r *Registry) Document(service string, table *routes.Table) (*openapi3.T, error) {
+	if _, ok := r.specs[service]; !ok {
+		return nil, fmt.Errorf("no openapi spec for %s", service)
+	}
+	doc, err := openapi.Load(service)
+	if err != nil {
+		return nil, err
+	}
+	doc.Servers = openapi3.Servers{{URL: "/", Description: "API Gateway"}}
+	if service == GatewayService {
+		return doc, nil
+	}
+
+	routeList := table.Routes()
+	paths := openapi3.NewPaths()
+	for _, path := range doc.Paths.InMatchingOrder() {
+		item := doc.Paths.Value(path)
+		for method, operation := range item.Operations() {
+			gatewayPath, route, ok := resolveGatewayPath(table, routeList, service, method, path)
+			if !ok {
+				continue
+			}
+			if route.AuthRequired {
+				operation.Security = nil
+			} else {
+				operation.Security = openapi3.NewSecurityRequirements()
+			}
+
+			target := paths.Value(gatewayPath)
+			if target == nil {
+				target = &openapi3.PathItem{
+					Summary:     item.Summary,
+					Description: item.Description,
+					Parameters:  item.Parameters,
+				}
+				paths.Set(gatewayPath, target)
+			}
+			target.SetOperation(method, operation)
+		}
+	}
+	doc.Paths = paths
+	return doc, nil
+}
+
+// resolveGatewayPath 找到转发到服务指定路径的网关路径，优先使用不重写的路由
+// 候选路径需经路由表匹配回同一服务、同一上游路径才算有效，避免被更长的前缀截走
+func resolveGatewayPath(table *routes.Table, routeList []config.RouteConfig, service, method, path string) (string, config.RouteConfig, bool) {
+	candidates := make([]string, 0, 2)
+	for _, rewrite := range []bool{false, true} {
+		for _, route := range routeList {
+			if route.Service != service || (route.Rewrite != "") != rewrite {
+				continue
+			}
+			if !rewrite {
+				candidates = append(candidates, path)
+				continue
+			}
+			upstreamPrefix := strings.TrimSuffix(route.Rewrite, "/")
+			if path == upstreamPrefix || strings.HasPrefix(path, upstreamPrefix+"/") {
+				candidates = append(candidates, strings.TrimSuffix(route.Prefix, "/")+strings.TrimPrefix(path, upstreamPrefix))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		route, err := table.Match(method, candidate)
+		if err != nil || route.Service != service || routes.UpstreamPath(route, candidate) != path {
+			continue
+		}
+		return candidate, route, true
+	}
+	return "", config.RouteConfig{}, false
+}
+
+// collectFieldErrors 把kin-openapi的校验错误展开为字段错误
+func collectFieldErrors(err error, fields *[]validation.FieldError) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, sub := range e {
+			collectFieldErrors(sub, fields)
+		}
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			collectSchemaErrors(e.Err, e.Parameter.In, e.Parameter.Name, fields)
+			return
+		}
+		collectSchemaErrors(e.Err, validation.InBody, "", fields)
+	default:
+		addFieldError(fields, validation.FieldError{Rule: "request", Message: err.Error()})
+	}
+}
+
+// collectSchemaErrors 展开参数或请求体的错误，field 为参数名，请求体时为空
+func collectSchemaErrors(err error, in, field string, fields *[]validation.FieldError) {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		for _, e := range multi {
+			collectSchemaErrors(e, in, field, fields)
+		}
+		return
+	}
+
+	var schemaErr *openapi3.SchemaError
+	var parseErr *openapi3filter.ParseError
+	switch {
+	case errors.As(err, &schemaErr):
+		name := joinField(field, schemaErr.JSONPointer())
+		addFieldError(fields, validation.FieldError{
+			Field:   name,
+			In:      in,
+			Rule:    schemaErr.SchemaField,
+			Message: schemaMessage(name, schemaErr),
+		})
+	case errors.Is(err, openapi3filter.ErrInvalidRequired), errors.Is(err, openapi3filter.ErrInvalidEmptyValue):
+		message := "request body is required"
+		if field != "" {
+			message = field + " is required"
+		}
+		addFieldError(fields, validation.FieldError{Field: field, In: in, Rule: "required", Message: message})
+	case errors.As(err, &parseErr) && field == "":
+		addFieldError(fields, validation.FieldError{In: in, Rule: "json", Message: "malformed JSON: " + parseErr.RootCause().Error()})
+	case errors.As(err, &parseErr):
+		addFieldError(fields, validation.FieldError{Field: field, In: in, Rule: "type", Message: field + ": " + parseErr.Error()})
+	default:
+		addFieldError(fields, validation.FieldError{Field: field, In: in, Rule: "request", Message: err.Error()})
+	}
+}
+
+// addFieldError 同一字段只保留第一个错误，如 exclusiveMinimum 会同时报告 minimum
+func addFieldError(fields *[]validation.FieldError, fieldErr validation.FieldError) {
+	for _, existing := range *fields {
+		if existing.In == fieldErr.In && existing.Field == fieldErr.Field {
+			return
+		}
+	}
+	*fields = append(*fields, fieldErr)
+}
+
+// schemaMessage 常见规则使用与服务端相近的描述，其余沿用kin-openapi的原因
+func schemaMessage(name string, schemaErr *openapi3.SchemaError) string {
+	switch {
+	case name == "":
+		return schemaErr.Reason
+	case schemaErr.SchemaField == "required":
+		return name + " is required"
+	case schemaErr.SchemaField == "format" && schemaErr.Schema != nil:
+		return fmt.Sprintf("%s must be a valid %s", name, schemaErr.Schema.Format)
+	default:
+		return name + ": " + schemaErr.Reason
+	}
+}
+
+// joinField 把JSON指针拼成与服务端一致的字段名，如 items/0/quantity 变为 items[0].quantity
+func joinField(field string, pointer []string) string {
+	var b strings.Builder
+	b.WriteString(field)
+	for _, part := range pointer {
+		if part != "" && strings.Trim(part, "0123456789") == "" {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed   State = iota // 关闭：正常放行请求
+	StateOpen                  // 打开：直接拒绝请求
+	StateHalfOpen              // 半开：放行少量探测请求
+)
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrOpen 熔断器打开时返回的错误
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Settings 熔断器配置
+type Settings struct {
+	FailureThreshold int           // 连续失败次数达到该值时打开熔断器
+	OpenTimeout      time.Duration // 打开状态持续时间，之后进入半开状态
+	HalfOpenRequests int           // 半开状态允许的探测请求数，全部成功后关闭熔断器
+}
+
+// Snapshot 熔断器状态快照
+type Snapshot struct {
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// Breaker 熔断器
+type Breaker struct {
+	settings Settings
+
+	mu         sync.Mutex
+	state      State
+	generation uint64 // 每次状态切换递增，用于忽略过期的请求结果
+	failures   int
+	openedAt   time.Time
+	probes     int // 半开状态已放行的探测请求数
+	successes  int // 半开状态成功的探测请求数
+}
+
+// NewBreaker 创建熔断器
+func NewBreaker(settings Settings) *Breaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+	return &Breaker{settings: settings}
+}
+
+// Allow 判断是否放行请求
+// 放行时返回done回调，调用方必须在请求结束后以请求是否成功调用它
+func (b *Breaker) Allow() (func(success bool), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(success bool) {
+		b.record(generation, success)
+	}, nil
+}
+
+// record 记录请求结果
+func (b *Breaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := time.Now()
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		if !success {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// setState 切换状态并重置计数，调用方需持有锁
+func (b *Breaker) setState(state State, now time.Time) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	if state == StateOpen {
+		b.openedAt = now
+	}
+	if state == StateClosed {
+		b.failures = 0
+	}
+}
+
+// Snapshot 返回当前状态快照
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := Snapshot{
+		State:    b.state.String(),
+		Failures: b.failures,
+	}
+	if b.state == StateOpen {
+		openUntil := b.openedAt.Add(b.settings.OpenTimeout)
+		snapshot.OpenUntil = &openUntil
+	}
+	return snapshot
+}
+
+// Group 按上游服务划分的熔断器集合
+type Group struct {
+	settings Settings
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup 创建熔断器集合
+func NewGroup(settings Settings) *Group {
+	return &Group{
+		settings: settings,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get 获取服务的熔断器，不存在时创建
+func (g *Group) Get(service string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.breakers[service]
+	if !ok {
+		b = NewBreaker(g.settings)
+		g.breakers[service] = b
+	}
+	return b
+}
+
+// Snapshots 返回所有熔断器的状态快照
+func (g *Group) Snapshots() map[string]Snapshot {
+	g.mu.Lock()
+	breakers := make(map[string]*Breaker, len(g.breakers))
+	for service, b := range g.breakers {
+		breakers[service] = b
+	}
+	g.mu.Unlock()
+
+	snapshots := make(map[string]Snapshot, len(breakers))
+	for service, b := range breakers {
+		snapshots[service] = b.Snapshot()
+	}
+	return snapshots
+}
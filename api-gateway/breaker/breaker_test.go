@@ -0,0 +1,107 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 测试步骤
+const (
+	opPass      = "pass"       // 放行并以成功结束
+	opFail      = "fail"       // 放行并以失败结束
+	opReject    = "reject"     // 应被拒绝
+	opHold      = "hold"       // 放行但暂不结束
+	opReleaseOK = "release-ok" // 以成功结束最早暂存的请求
+	opRelease   = "release"    // 以失败结束最早暂存的请求
+	opExpire    = "expire"     // 打开状态到期
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	settings := Settings{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenRequests: 2}
+
+	tests := []struct {
+		name  string
+		steps []string
+		want  State
+	}{
+		{"success keeps closed", []string{opPass, opPass}, StateClosed},
+		{"failures below threshold", []string{opFail, opFail}, StateClosed},
+		{"success resets failures", []string{opFail, opFail, opPass, opFail, opFail}, StateClosed},
+		{"threshold opens", []string{opFail, opFail, opFail}, StateOpen},
+		{"open rejects", []string{opFail, opFail, opFail, opReject}, StateOpen},
+		{"timeout half-opens", []string{opFail, opFail, opFail, opExpire, opHold}, StateHalfOpen},
+		{"half-open limits probes", []string{opFail, opFail, opFail, opExpire, opHold, opHold, opReject}, StateHalfOpen},
+		{"all probes succeed closes", []string{opFail, opFail, opFail, opExpire, opPass, opPass}, StateClosed},
+		{"probe failure reopens", []string{opFail, opFail, opFail, opExpire, opPass, opFail}, StateOpen},
+		{"reopened rejects", []string{opFail, opFail, opFail, opExpire, opFail, opReject}, StateOpen},
+		{"stale failure ignored in half-open", []string{opHold, opFail, opFail, opFail, opExpire, opHold, opRelease}, StateHalfOpen},
+		{"stale success ignored while open", []string{opHold, opFail, opFail, opFail, opReleaseOK}, StateOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(settings)
+			var held []func(bool)
+			for i, op := range tt.steps {
+				switch op {
+				case opExpire:
+					b.mu.Lock()
+					b.openedAt = b.openedAt.Add(-settings.OpenTimeout)
+					b.mu.Unlock()
+					continue
+				case opRelease, opReleaseOK:
+					held[0](op == opReleaseOK)
+					held = held[1:]
+					continue
+				}
+
+				done, err := b.Allow()
+				if op == opReject {
+					if !errors.Is(err, ErrOpen) {
+						t.Fatalf("step %d: Allow() error = %v, want ErrOpen", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("step %d (%s): Allow() error = %v", i, op, err)
+				}
+				if op == opHold {
+					held = append(held, done)
+				} else {
+					done(op == opPass)
+				}
+			}
+
+			if got := b.Snapshot().State; got != tt.want.String() {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBreakerDefaults(t *testing.T) {
+	b := NewBreaker(Settings{})
+	want := Settings{FailureThreshold: 5, OpenTimeout: 30 * time.Second, HalfOpenRequests: 1}
+	if b.settings != want {
+		t.Errorf("settings = %+v, want %+v", b.settings, want)
+	}
+}
+
+func TestSnapshotOpenUntil(t *testing.T) {
+	b := NewBreaker(Settings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	if b.Snapshot().OpenUntil != nil {
+		t.Fatal("closed breaker has open_until")
+	}
+
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(false)
+
+	snapshot := b.Snapshot()
+	if snapshot.OpenUntil == nil || !snapshot.OpenUntil.Equal(b.openedAt.Add(time.Minute)) {
+		t.Errorf("open_until = %v, want %v", snapshot.OpenUntil, b.openedAt.Add(time.Minute))
+	}
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"blog/api-gateway/config"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// keyPrefix 缓存相关键的前缀
+const keyPrefix = "gateway:cache:"
+
+// MaxBodySize 允许缓存的最大响应体大小
+const MaxBodySize = 1 << 20
+
+// storedHeaders 随响应体一起缓存的响应头
+// CORS、限流等由网关中间件按请求生成的头不缓存
+var storedHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language", "Cache-Control", "ETag", "Last-Modified"}
+
+// Entry 缓存的响应
+type Entry struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// Age 缓存已存在的秒数
+func (e *Entry) Age() int {
+	return int(time.Since(e.StoredAt) / time.Second)
+}
+
+// Cache 基于Redis的网关响应缓存
+//
+// 每个路由维护一个代次号，缓存键包含代次号；失效时只需递增代次号，
+// 旧代次的缓存不再被读取，到期后由Redis自动清理
+type Cache struct {
+	client *redis.Client
+}
+
+// NewCache 创建响应缓存
+func NewCache(client *redis.Client) *Cache {
+	return &Cache{client: client}
+}
+
+// Key 计算请求的缓存键，随路由代次、上游版本、路径、查询参数和配置的请求头变化
+// 灰度版本和稳定版本的响应可能不同，分开缓存
+func (c *Cache) Key(ctx context.Context, route config.RouteConfig, version string, req *http.Request) (string, error) {
+	generation, err := c.client.Get(ctx, generationKey(route.Prefix)).Int64()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(req.URL.Path))
+	h.Write([]byte{0})
+	// Encode按参数名排序，参数顺序不同的请求共享缓存
+	h.Write([]byte(req.URL.Query().Encode()))
+	for _, name := range route.Cache.VaryHeaders {
+		h.Write([]byte{0})
+		h.Write([]byte(strings.ToLower(name)))
+		h.Write([]byte{':'})
+		h.Write([]byte(strings.Join(req.Header.Values(name), ",")))
+	}
+
+	return keyPrefix + route.Prefix + ":" + strconv.FormatInt(generation, 10) + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get 读取缓存，未命中或读取失败时返回false
+func (c *Cache) Get(ctx context.Context, key string) (*Entry, bool) {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Set 写入缓存
+func (c *Cache) Set(ctx context.Context, key string, status int, header http.Header, body []byte, ttl time.Duration) error {
+	entry := Entry{
+		Status:   status,
+		Header:   make(http.Header),
+		Body:     body,
+		StoredAt: time.Now(),
+	}
+	for _, name := range storedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			entry.Header[name] = values
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, data, ttl).Err()
+}
+
+// Invalidate 使路由的全部缓存失效
+func (c *Cache) Invalidate(ctx context.Context, prefix string) error {
+	return c.client.Incr(ctx, generationKey(prefix)).Err()
+}
+
+// generationKey 路由代次号的键
+func generationKey(prefix string) string {
+	return keyPrefix + "generation:" + prefix
+}
+
+// RequestDirectives 根据请求的Cache-Control决定是否读取和写入缓存
+// no-store 既不读也不写；no-cache 或 max-age=0 跳过读取，但用新响应刷新缓存
+func RequestDirectives(header http.Header) (lookup, store bool) {
+	directives := parseCacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return false, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return false, true
+	}
+	if maxAge, ok := directives["max-age"]; ok && maxAge == "0" {
+		return false, true
+	}
+	return true, true
+}
+
+// ResponseTTL 判断响应是否可缓存，并结合上游Cache-Control计算缓存时间
+// 只缓存状态码为200、业务码为0的响应
+func ResponseTTL(status int, header http.Header, body []byte, routeTTL time.Duration) (time.Duration, bool) {
+	if status != http.StatusOK || header.Get("Set-Cookie") != "" || len(body) > MaxBodySize {
+		return 0, false
+	}
+
+	directives := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+
+	ttl := routeTTL
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		value, ok := directives[directive]
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+		if upstreamTTL := time.Duration(seconds) * time.Second; upstreamTTL < ttl {
+			ttl = upstreamTTL
+		}
+		break
+	}
+
+	// 服务统一返回200，失败信息在业务码中
+	if strings.Contains(header.Get("Content-Type"), "json") {
+		var state struct {
+			Code int `json:"code"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&state); err != nil || state.Code != 0 {
+			return 0, false
+		}
+	}
+
+	return ttl, true
+}
+
+// parseCacheControl 解析Cache-Control头，指令名转为小写
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return directives
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"blog/api-gateway/routes"
+	"blog/shared/kafka"
+	"blog/shared/tracing"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Invalidator 消费Kafka事件，使配置了对应主题的路由缓存失效
+type Invalidator struct {
+	cache    *Cache
+	consumer *kafka.Consumer
+	routes   *routes.Table
+
+	mu         sync.Mutex
+	subscribed map[string]bool
+}
+
+// NewInvalidator 创建缓存失效器
+func NewInvalidator(cache *Cache, consumer *kafka.Consumer, routeTable *routes.Table) *Invalidator {
+	return &Invalidator{
+		cache:      cache,
+		consumer:   consumer,
+		routes:     routeTable,
+		subscribed: make(map[string]bool),
+	}
+}
+
+// Start 立即订阅路由表中的失效主题，之后定期检查路由表的变化和订阅失败的主题
+func (inv *Invalidator) Start(interval time.Duration, stop <-chan struct{}) {
+	inv.Subscribe()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				inv.Subscribe()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Subscribe 订阅路由表中尚未订阅的失效主题
+// 主题不存在时订阅失败，下次调用时重试
+func (inv *Invalidator) Subscribe() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for _, route := range inv.routes.Routes() {
+		if route.Cache == nil {
+			continue
+		}
+		for _, topic := range route.Cache.InvalidateOn {
+			if inv.subscribed[topic] {
+				continue
+			}
+			if err := inv.consumer.ConsumeMessages(topic, inv.handleMessage); err != nil {
+				log.Printf("Failed to subscribe cache invalidation topic %s: %v", topic, err)
+				continue
+			}
+			inv.subscribed[topic] = true
+			log.Printf("Subscribed cache invalidation topic %s", topic)
+		}
+	}
+}
+
+// handleMessage 使监听该主题的所有路由缓存失效
+func (inv *Invalidator) handleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	for _, route := range inv.routes.Routes() {
+		if route.Cache == nil || !containsTopic(route.Cache.InvalidateOn, msg.Topic) {
+			continue
+		}
+		if err := inv.cache.Invalidate(ctx, route.Prefix); err != nil {
+			tracing.Printf(ctx, "Failed to invalidate cache for route %s: %v", route.Prefix, err)
+			continue
+		}
+		tracing.Printf(ctx, "Cache invalidated for route %s by %s", route.Prefix, msg.Topic)
+	}
+	return nil
+}
+
+// containsTopic 判断主题列表中是否包含指定主题
+func containsTopic(topics []string, topic string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package canary
+
+import (
+	"blog/api-gateway/config"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HeaderCanary 测试人员指定版本的请求头
+// true 使用灰度版本，false 使用稳定版本，也可以直接填写版本标签
+const HeaderCanary = "X-Canary"
+
+// Router 按服务在版本之间分配流量，支持运行时整体替换规则
+type Router struct {
+	mu    sync.RWMutex
+	rules map[string]*rule
+	raw   []config.CanaryConfig
+}
+
+// rule 预先排好序的分流规则
+type rule struct {
+	service  string
+	versions []string // 灰度版本排在最前，其余按名称排序
+	bounds   []int    // versions[i] 的累计权重上界
+	total    int
+	canary   string
+	stable   string
+	sticky   bool
+}
+
+// NewRouter 创建分流路由
+func NewRouter(rules []config.CanaryConfig) (*Router, error) {
+	r := &Router{}
+	if err := r.Update(rules); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update 校验并替换所有分流规则，校验失败时保留原规则
+func (r *Router) Update(rules []config.CanaryConfig) error {
+	compiled := make(map[string]*rule, len(rules))
+	for i, cfg := range rules {
+		rl, err := compileRule(cfg)
+		if err != nil {
+			return fmt.Errorf("canary rule %d: %v", i, err)
+		}
+		if _, ok := compiled[rl.service]; ok {
+			return fmt.Errorf("canary rule %d: duplicate rule for %s", i, rl.service)
+		}
+		compiled[rl.service] = rl
+	}
+
+	raw := make([]config.CanaryConfig, len(rules))
+	copy(raw, rules)
+
+	r.mu.Lock()
+	r.rules = compiled
+	r.raw = raw
+	r.mu.Unlock()
+	return nil
+}
+
+// Rules 返回当前的分流规则
+func (r *Router) Rules() []config.CanaryConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]config.CanaryConfig, len(r.raw))
+	copy(rules, r.raw)
+	return rules
+}
+
+// Select 为请求选择版本，服务没有分流规则时返回空字符串
+// override 为 X-Canary 请求头的值；userID 为空或未开启粘性分配时按权重随机选择
+func (r *Router) Select(service, userID, override string) string {
+	r.mu.RLock()
+	rl, ok := r.rules[service]
+	r.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+
+	if version, ok := rl.override(override); ok {
+		return version
+	}
+
+	var n int
+	if rl.sticky && userID != "" {
+		h := fnv.New32a()
+		h.Write([]byte(service + "/" + userID))
+		n = int(h.Sum32() % uint32(rl.total))
+	} else {
+		n = rand.Intn(rl.total)
+	}
+	for i, bound := range rl.bounds {
+		if n < bound {
+			return rl.versions[i]
+		}
+	}
+	return rl.versions[len(rl.versions)-1]
+}
+
+// override 解析 X-Canary 请求头，值无法识别时忽略
+func (rl *rule) override(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return "", false
+	case strings.EqualFold(value, "true"):
+		return rl.canary, rl.canary != ""
+	case strings.EqualFold(value, "false"):
+		return rl.stable, rl.stable != ""
+	}
+	for _, version := range rl.versions {
+		if version == value {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// compileRule 校验规则并计算累计权重
+// 灰度版本占据哈希区间的开头，调大它的权重时已分到灰度的用户不会被换回稳定版本
+func compileRule(cfg config.CanaryConfig) (*rule, error) {
+	if cfg.Service == "" {
+		return nil, errors.New("service is required")
+	}
+	if len(cfg.Weights) == 0 {
+		return nil, fmt.Errorf("%s: weights are required", cfg.Service)
+	}
+	if cfg.CanaryVersion != "" {
+		if _, ok := cfg.Weights[cfg.CanaryVersion]; !ok {
+			return nil, fmt.Errorf("%s: canary_version %q is not in weights", cfg.Service, cfg.CanaryVersion)
+		}
+	}
+	if cfg.StableVersion != "" {
+		if _, ok := cfg.Weights[cfg.StableVersion]; !ok {
+			return nil, fmt.Errorf("%s: stable_version %q is not in weights", cfg.Service, cfg.StableVersion)
+		}
+	}
+
+	versions := make([]string, 0, len(cfg.Weights))
+	for version, weight := range cfg.Weights {
+		if version == "" {
+			return nil, fmt.Errorf("%s: version must not be empty", cfg.Service)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("%s: weight of %s must not be negative", cfg.Service, version)
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if (versions[i] == cfg.CanaryVersion) != (versions[j] == cfg.CanaryVersion) {
+			return versions[i] == cfg.CanaryVersion
+		}
+		return versions[i] < versions[j]
+	})
+
+	rl := &rule{
+		service: cfg.Service,
+		canary:  cfg.CanaryVersion,
+		stable:  cfg.StableVersion,
+		sticky:  cfg.Sticky,
+	}
+	// 权重为0的版本区间为空，只能通过 X-Canary 访问
+	for _, version := range versions {
+		rl.total += cfg.Weights[version]
+		rl.versions = append(rl.versions, version)
+		rl.bounds = append(rl.bounds, rl.total)
+	}
+	if rl.total == 0 {
+		return nil, fmt.Errorf("%s: total weight must be positive", cfg.Service)
+	}
+	return rl, nil
+}
@@ -0,0 +1,187 @@
+package canary
+
+import (
+	"blog/api-gateway/config"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config.CanaryConfig
+		wantErr      bool
+		wantVersions []string
+		wantBounds   []int
+	}{
+		{
+			name:         "canary first",
+			cfg:          config.CanaryConfig{Service: "svc", Weights: map[string]int{"stable": 90, "v2": 10}, CanaryVersion: "v2", StableVersion: "stable"},
+			wantVersions: []string{"v2", "stable"},
+			wantBounds:   []int{10, 100},
+		},
+		{
+			name:         "others sorted by name",
+			cfg:          config.CanaryConfig{Service: "svc", Weights: map[string]int{"c": 1, "a": 2, "b": 3}},
+			wantVersions: []string{"a", "b", "c"},
+			wantBounds:   []int{2, 5, 6},
+		},
+		{
+			name:         "zero weight keeps empty range",
+			cfg:          config.CanaryConfig{Service: "svc", Weights: map[string]int{"stable": 100, "v2": 0}, CanaryVersion: "v2"},
+			wantVersions: []string{"v2", "stable"},
+			wantBounds:   []int{0, 100},
+		},
+		{name: "missing service", cfg: config.CanaryConfig{Weights: map[string]int{"stable": 1}}, wantErr: true},
+		{name: "missing weights", cfg: config.CanaryConfig{Service: "svc"}, wantErr: true},
+		{name: "unknown canary", cfg: config.CanaryConfig{Service: "svc", Weights: map[string]int{"stable": 1}, CanaryVersion: "v2"}, wantErr: true},
+		{name: "unknown stable", cfg: config.CanaryConfig{Service: "svc", Weights: map[string]int{"v2": 1}, StableVersion: "stable"}, wantErr: true},
+		{name: "empty version", cfg: config.CanaryConfig{Service: "svc", Weights: map[string]int{"": 1}}, wantErr: true},
+		{name: "negative weight", cfg: config.CanaryConfig{Service: "svc", Weights: map[string]int{"stable": 2, "v2": -1}}, wantErr: true},
+		{name: "zero total", cfg: config.CanaryConfig{Service: "svc", Weights: map[string]int{"stable": 0}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, err := compileRule(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(rl.versions, tt.wantVersions) || !reflect.DeepEqual(rl.bounds, tt.wantBounds) {
+				t.Errorf("versions = %v bounds = %v, want %v %v", rl.versions, rl.bounds, tt.wantVersions, tt.wantBounds)
+			}
+		})
+	}
+}
+
+func TestRouterSelectOverride(t *testing.T) {
+	router, err := NewRouter([]config.CanaryConfig{
+		{Service: "svc", Weights: map[string]int{"stable": 100, "v2": 0, "v3": 0}, CanaryVersion: "v2", StableVersion: "stable"},
+		{Service: "plain", Weights: map[string]int{"a": 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		service, override string
+		want              string
+	}{
+		{"svc", "true", "v2"},
+		{"svc", " TRUE ", "v2"},
+		{"svc", "false", "stable"},
+		{"svc", "v3", "v3"},
+		{"svc", "unknown", "stable"},
+		{"svc", "", "stable"},
+		{"plain", "true", "a"},
+		{"other", "true", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service+"/"+tt.override, func(t *testing.T) {
+			if got := router.Select(tt.service, "", tt.override); got != tt.want {
+				t.Errorf("Select(%q, %q) = %q, want %q", tt.service, tt.override, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterSelectSticky(t *testing.T) {
+	rules := func(canaryWeight int) []config.CanaryConfig {
+		return []config.CanaryConfig{{
+			Service:       "svc",
+			Weights:       map[string]int{"stable": 100 - canaryWeight, "v2": canaryWeight},
+			CanaryVersion: "v2",
+			Sticky:        true,
+		}}
+	}
+
+	router, err := NewRouter(rules(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const users = 1000
+	before := make(map[string]string, users)
+	canaryUsers := 0
+	for i := 0; i < users; i++ {
+		userID := fmt.Sprint(i)
+		before[userID] = router.Select("svc", userID, "")
+		for j := 0; j < 3; j++ {
+			if got := router.Select("svc", userID, ""); got != before[userID] {
+				t.Fatalf("user %s moved from %s to %s", userID, before[userID], got)
+			}
+		}
+		if before[userID] == "v2" {
+			canaryUsers++
+		}
+	}
+	if canaryUsers < users/10 || canaryUsers > users*3/10 {
+		t.Errorf("%d of %d users on canary, want about 20%%", canaryUsers, users)
+	}
+
+	// 调大灰度权重时已在灰度的用户保持不变
+	if err := router.Update(rules(50)); err != nil {
+		t.Fatal(err)
+	}
+	for userID, version := range before {
+		if version == "v2" && router.Select("svc", userID, "") != "v2" {
+			t.Errorf("user %s moved back to stable after raising canary weight", userID)
+		}
+	}
+
+	// 粘性分配时请求头仍然优先
+	for userID, version := range before {
+		if version == "stable" {
+			if got := router.Select("svc", userID, "true"); got != "v2" {
+				t.Errorf("Select(%s) with X-Canary true = %q, want v2", userID, got)
+			}
+			break
+		}
+	}
+}
+
+func TestRouterSelectWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		want    string
+	}{
+		{"all stable", map[string]int{"stable": 100, "v2": 0}, "stable"},
+		{"all canary", map[string]int{"stable": 0, "v2": 100}, "v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewRouter([]config.CanaryConfig{{Service: "svc", Weights: tt.weights, CanaryVersion: "v2"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100; i++ {
+				if got := router.Select("svc", fmt.Sprint(i), ""); got != tt.want {
+					t.Fatalf("Select() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterUpdateRejectsDuplicates(t *testing.T) {
+	router, err := NewRouter([]config.CanaryConfig{{Service: "svc", Weights: map[string]int{"stable": 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = router.Update([]config.CanaryConfig{
+		{Service: "svc", Weights: map[string]int{"stable": 1}},
+		{Service: "svc", Weights: map[string]int{"v2": 1}},
+	})
+	if err == nil {
+		t.Fatal("Update() accepted duplicate rules")
+	}
+	if rules := router.Rules(); len(rules) != 1 {
+		t.Errorf("rules after failed update = %v, want original rule", rules)
+	}
+}
@@ -1,22 +1,44 @@
 package config
 
 import (
+	"blog/shared/auth"
 	"blog/shared/config"
+	"blog/shared/jwks"
+	"blog/shared/kafka"
+	"blog/shared/serviceauth"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 // Config API网关配置
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Services ServicesConfig `json:"services"`
-	JWT      JWTConfig      `json:"jwt"`
+	Server         ServerConfig         `json:"server"`
+	Services       ServicesConfig       `json:"services"`
+	JWT            JWTConfig            `json:"jwt"`
+	Registry       RegistryConfig       `json:"registry"`
+	LoadBalancer   LoadBalancerConfig   `json:"load_balancer"`
+	Redis          RedisConfig          `json:"redis"`
+	RateLimit      RateLimitConfig      `json:"rate_limit"`
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+	Retry          RetryConfig          `json:"retry"`
+	Routes         []RouteConfig        `json:"routes"`
+	Kafka          KafkaConfig          `json:"kafka"`
+	HealthCheck    HealthCheckConfig    `json:"health_check"`
+	Aggregation    AggregationConfig    `json:"aggregation"`
+	Cors           CorsConfig           `json:"cors"`
+	Canary         []CanaryConfig       `json:"canary"`
+	ServiceAuth    serviceauth.Config   `json:"service_auth"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
 	Port string `json:"port"`
+	// TrustedProxies 网关前的可信代理（如Nginx）的IP或CIDR，只有来自这些地址的 X-Forwarded-For 会被采用
+	// 为空时不信任任何代理，客户端地址取连接地址
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 // ServicesConfig 微服务配置
@@ -33,15 +55,149 @@ type ServiceConfig struct {
 	Port string `json:"port"`
 }
 
-// JWTConfig JWT配置
+// JWTConfig 访问令牌验证配置，验签公钥从签发服务的JWKS接口获取
 type JWTConfig struct {
-	Secret string `json:"secret"`
+	JWKSService     string `json:"jwks_service"`     // 公布验签公钥的服务
+	RefreshInterval int    `json:"refresh_interval"` // 公钥集刷新间隔（秒）
 }
 
+// RegistryConfig 服务注册中心配置
+type RegistryConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+// LoadBalancerConfig 负载均衡配置
+type LoadBalancerConfig struct {
+	Strategy        string `json:"strategy"`         // round_robin, least_connections
+	RefreshInterval int    `json:"refresh_interval"` // 实例列表刷新间隔（秒）
+}
+
+// RedisConfig 网关共享状态（限流计数等）使用的Redis配置
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Enabled bool             `json:"enabled"`
+	Default RateLimitRule    `json:"default"`
+	Routes  []RouteRateLimit `json:"routes"`
+}
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	PerIP   LimitConfig `json:"per_ip"`
+	PerUser LimitConfig `json:"per_user"`
+}
+
+// LimitConfig 滑动窗口配额，Limit为0表示不限制
+type LimitConfig struct {
+	Limit  int `json:"limit"`  // 窗口内允许的请求数
+	Window int `json:"window"` // 窗口长度（秒）
+}
+
+// RouteRateLimit 路由级限流规则，按路径前缀匹配，Methods为空表示所有方法
+type RouteRateLimit struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	RateLimitRule
+}
+
+// CircuitBreakerConfig 上游服务熔断配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold"`  // 连续失败次数达到该值时熔断
+	OpenTimeout      int `json:"open_timeout"`       // 熔断持续时间（秒），之后进入半开状态
+	HalfOpenRequests int `json:"half_open_requests"` // 半开状态允许的探测请求数
+}
+
+// RetryConfig 幂等请求重试配置
+type RetryConfig struct {
+	MaxRetries  int `json:"max_retries"`  // 最大重试次数，0表示不重试
+	BaseBackoff int `json:"base_backoff"` // 首次重试的退避时间（毫秒），之后指数增长
+	MaxBackoff  int `json:"max_backoff"`  // 退避时间上限（毫秒）
+}
+
+// RouteConfig 网关路由规则，按最长路径前缀匹配
+type RouteConfig struct {
+	Prefix           string            `json:"prefix"`            // 网关路径前缀，如 /api/v1/users
+	Methods          []string          `json:"methods"`           // 允许的HTTP方法，为空表示所有方法
+	Service          string            `json:"service"`           // 注册中心中的上游服务名
+	Rewrite          string            `json:"rewrite"`           // 转发时替换Prefix的上游路径前缀，为空表示原样转发
+	AuthRequired     bool              `json:"auth_required"`     // 是否需要JWT认证
+	VerifiedRequired bool              `json:"verified_required"` // 写请求（GET、HEAD、OPTIONS以外）是否要求邮箱已验证，需同时开启认证
+	Permission       string            `json:"permission"`        // 要求JWT中带有的权限，如 products:write，为空表示不检查，需同时开启认证
+	Timeout          int               `json:"timeout"`           // 请求超时（秒），0表示不限制
+	Cache            *RouteCacheConfig `json:"cache,omitempty"`   // 响应缓存，为空表示不缓存
+}
+
+// RouteCacheConfig 路由响应缓存配置，只缓存GET请求
+type RouteCacheConfig struct {
+	TTL          int      `json:"ttl"`           // 缓存时间（秒），上游Cache-Control的max-age更短时以其为准
+	VaryHeaders  []string `json:"vary_headers"`  // 参与缓存键计算的请求头，如 Accept-Language、X-User-ID
+	InvalidateOn []string `json:"invalidate_on"` // 收到这些Kafka主题的消息时清空该路由的缓存
+}
+
+// HealthCheckConfig 网关检查微服务就绪状态的配置
+type HealthCheckConfig struct {
+	Timeout int `json:"timeout"` // 单个实例检查的超时时间（毫秒）
+}
+
+// AggregationConfig 网关聚合接口的配置
+type AggregationConfig struct {
+	Timeout     int `json:"timeout"`      // 单个上游调用的超时时间（毫秒）
+	RecentLimit int `json:"recent_limit"` // 列表数据保留的最近条数
+}
+
+// CorsConfig 跨域策略，修改后立即生效
+type CorsConfig struct {
+	AllowedOrigins   []string        `json:"allowed_origins"`   // 允许的来源，如 https://app.example.com；https://*.example.com 匹配任意子域名
+	AllowCredentials bool            `json:"allow_credentials"` // 是否允许携带Cookie和Authorization，开启时来源不能为 *
+	ExposeHeaders    []string        `json:"expose_headers"`    // 允许浏览器读取的响应头
+	MaxAge           int             `json:"max_age"`           // 预检结果的缓存时间（秒），0表示不缓存
+	Default          CorsRule        `json:"default"`
+	Routes           []RouteCorsRule `json:"routes"`
+}
+
+// CorsRule 允许的方法和请求头
+type CorsRule struct {
+	AllowMethods []string `json:"allow_methods"`
+	AllowHeaders []string `json:"allow_headers"`
+}
+
+// RouteCorsRule 路由级跨域规则，按最长路径前缀匹配，未匹配时使用默认规则
+type RouteCorsRule struct {
+	Path string `json:"path"`
+	CorsRule
+}
+
+// CanaryConfig 服务的灰度分流规则，按实例的版本标签在版本之间分配流量，修改后立即生效
+type CanaryConfig struct {
+	Service       string         `json:"service"`        // 注册中心中的服务名
+	Weights       map[string]int `json:"weights"`        // 版本标签到权重，如 {"stable": 95, "v2": 5}
+	CanaryVersion string         `json:"canary_version"` // 灰度版本，请求头 X-Canary: true 时使用
+	StableVersion string         `json:"stable_version"` // 稳定版本，请求头 X-Canary: false 时使用
+	Sticky        bool           `json:"sticky"`         // 按用户ID哈希分配版本，同一用户始终访问同一版本
+}
+
+// KafkaConfig Kafka配置
+type KafkaConfig struct {
+	Brokers []string `json:"brokers"`
+}
+
+// 配置中心地址
+const (
+	configCenterAddr     = "47.118.19.28:6379"
+	configCenterPassword = "sta_go"
+)
+
 // LoadConfig 从Redis配置中心加载配置
 func LoadConfig() *Config {
 	// 尝试从Redis配置中心加载
-	configCenter, err := config.NewConfigCenter("47.118.19.28:6379", "sta_go", 0)
+	configCenter, err := config.NewConfigCenter(configCenterAddr, configCenterPassword, 0)
 	if err != nil {
 		log.Printf("Failed to connect to Redis config center: %v, using default config", err)
 		return loadDefaultConfig()
@@ -54,30 +210,76 @@ func LoadConfig() *Config {
 		return loadDefaultConfig()
 	}
 
-	// 解析配置
+	cfg, err := parseConfig(configData)
+	if err != nil {
+		log.Printf("%v, using default config", err)
+		return loadDefaultConfig()
+	}
+
+	log.Printf("Config loaded from Redis config center for api-gateway")
+	return cfg
+}
+
+// WatchConfig 监听配置中心中api-gateway配置的变化，每次收到新配置时调用callback
+// 返回的函数用于停止监听
+func WatchConfig(callback func(*Config)) (func(), error) {
+	configCenter, err := config.NewConfigCenter(configCenterAddr, configCenterPassword, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := configCenter.WatchConfig("api-gateway", func(configData *config.ConfigData) {
+			cfg, err := parseConfig(configData)
+			if err != nil {
+				log.Printf("Ignoring config update for api-gateway: %v", err)
+				return
+			}
+			callback(cfg)
+		})
+		log.Printf("Stopped watching config for api-gateway: %v", err)
+	}()
+
+	return func() { configCenter.Close() }, nil
+}
+
+// parseConfig 解析配置中心中的配置，未配置路由表、跨域策略、服务密钥或公钥来源时使用默认值
+func parseConfig(configData *config.ConfigData) (*Config, error) {
 	var cfg Config
 	configBytes, err := json.Marshal(configData.Config)
 	if err != nil {
-		log.Printf("Failed to marshal config: %v, using default config", err)
-		return loadDefaultConfig()
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
 	}
 
 	err = json.Unmarshal(configBytes, &cfg)
 	if err != nil {
-		log.Printf("Failed to unmarshal config: %v, using default config", err)
-		return loadDefaultConfig()
+		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
 	}
 
-	log.Printf("Config loaded from Redis config center for api-gateway")
-	return &cfg
+	if len(cfg.Routes) == 0 {
+		cfg.Routes = defaultRoutes()
+	}
+	// 未配置跨域策略时使用默认策略；allowed_origins 为空列表表示拒绝所有跨域请求
+	if cfg.Cors.AllowedOrigins == nil {
+		cfg.Cors = defaultCorsConfig()
+	}
+	if cfg.ServiceAuth.Key == "" {
+		cfg.ServiceAuth = serviceauth.DefaultConfig("api-gateway")
+	}
+	if cfg.Server.TrustedProxies == nil {
+		cfg.Server.TrustedProxies = defaultTrustedProxies()
+	}
+	if cfg.JWT.JWKSService == "" {
+		cfg.JWT.JWKSService = defaultJWTConfig().JWKSService
+	}
+	if cfg.JWT.RefreshInterval <= 0 {
+		cfg.JWT.RefreshInterval = defaultJWTConfig().RefreshInterval
+	}
+	return &cfg, nil
 }
 
 // loadDefaultConfig 加载默认配置
 func loadDefaultConfig() *Config {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "sta_go_jwt_secret"
-	}
 	// 检查环境变量
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
@@ -104,9 +306,35 @@ func loadDefaultConfig() *Config {
 		shopHost = "localhost"
 	}
 
+	redisHost := os.Getenv("REDIS_HOST")
+	if redisHost == "" {
+		redisHost = "redis"
+	}
+
+	redisPort := os.Getenv("REDIS_PORT")
+	if redisPort == "" {
+		redisPort = "6379"
+	}
+
+	lbStrategy := os.Getenv("LB_STRATEGY")
+	if lbStrategy == "" {
+		lbStrategy = "round_robin"
+	}
+
+	kafkaHost := os.Getenv("KAFKA_HOST")
+	if kafkaHost == "" {
+		kafkaHost = "kafka"
+	}
+
+	kafkaPort := os.Getenv("KAFKA_PORT")
+	if kafkaPort == "" {
+		kafkaPort = "9092"
+	}
+
 	return &Config{
 		Server: ServerConfig{
-			Port: port,
+			Port:           port,
+			TrustedProxies: defaultTrustedProxies(),
 		},
 		Services: ServicesConfig{
 			UserService: ServiceConfig{
@@ -126,8 +354,171 @@ func loadDefaultConfig() *Config {
 				Port: "8004",
 			},
 		},
-		JWT: JWTConfig{
-			Secret: jwtSecret,
+		JWT: defaultJWTConfig(),
+		Registry: RegistryConfig{
+			Addr:     redisHost + ":" + redisPort,
+			Password: "sta_go",
+			DB:       0,
+		},
+		LoadBalancer: LoadBalancerConfig{
+			Strategy:        lbStrategy,
+			RefreshInterval: 5,
+		},
+		Redis: RedisConfig{
+			Addr:     redisHost + ":" + redisPort,
+			Password: "sta_go",
+			DB:       0,
+		},
+		RateLimit: defaultRateLimitConfig(),
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      30,
+			HalfOpenRequests: 1,
+		},
+		Retry: RetryConfig{
+			MaxRetries:  2,
+			BaseBackoff: 100,
+			MaxBackoff:  1000,
+		},
+		Routes: defaultRoutes(),
+		Kafka: KafkaConfig{
+			Brokers: []string{kafkaHost + ":" + kafkaPort},
+		},
+		HealthCheck: HealthCheckConfig{
+			Timeout: 2000,
+		},
+		Aggregation: AggregationConfig{
+			Timeout:     3000,
+			RecentLimit: 5,
+		},
+		Cors:        defaultCorsConfig(),
+		ServiceAuth: serviceauth.DefaultConfig("api-gateway"),
+	}
+}
+
+// defaultTrustedProxies 可信代理取自环境变量 TRUSTED_PROXIES，多个地址以逗号分隔
+func defaultTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// defaultRoutes 默认路由表，商品的写操作和用户服务的管理接口需要相应权限
+func defaultRoutes() []RouteConfig {
+	writeMethods := []string{"POST", "PUT", "PATCH", "DELETE"}
+	return []RouteConfig{
+		{Prefix: "/api/v1/users", Service: "user-service", AuthRequired: true, Timeout: 30},
+		{Prefix: "/api/v1/users/register", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/login", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/refresh", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/logout", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/forgot-password", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/reset-password", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/verify-email", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/resend-code", Methods: []string{"POST"}, Service: "user-service", Timeout: 30},
+		{Prefix: "/api/v1/users/admin", Service: "user-service", AuthRequired: true, Permission: auth.PermUsersManage, Timeout: 30},
+		{Prefix: jwks.Path, Methods: []string{"GET"}, Service: "user-service", Timeout: 10, Cache: &RouteCacheConfig{TTL: 60}},
+		{Prefix: "/api/v1/wallets", Service: "wallet-service", AuthRequired: true, VerifiedRequired: true, Timeout: 30},
+		{
+			Prefix: "/api/v1/comments", Service: "comment-service", AuthRequired: true, VerifiedRequired: true, Timeout: 30,
+			Cache: &RouteCacheConfig{TTL: 30, InvalidateOn: []string{kafka.TopicCommentCreate, kafka.TopicCommentUpdate, kafka.TopicCommentDelete}},
+		},
+		{
+			Prefix: "/api/v1/products", Service: "shop-service", AuthRequired: true, VerifiedRequired: true, Timeout: 30,
+			Cache: &RouteCacheConfig{TTL: 60, InvalidateOn: []string{kafka.TopicProductCreate, kafka.TopicProductUpdate, kafka.TopicProductDelete}},
+		},
+		{
+			Prefix: "/api/v1/products", Methods: writeMethods, Service: "shop-service", AuthRequired: true, VerifiedRequired: true,
+			Permission: auth.PermProductsWrite, Timeout: 30,
+		},
+		{Prefix: "/api/v1/orders", Service: "shop-service", AuthRequired: true, VerifiedRequired: true, Timeout: 30},
+		{Prefix: "/api/v1/shop", Service: "shop-service", Rewrite: "/api/v1", AuthRequired: true, VerifiedRequired: true, Timeout: 30},
+		{
+			Prefix: "/api/v1/shop/products", Methods: writeMethods, Service: "shop-service", Rewrite: "/api/v1/products", AuthRequired: true, VerifiedRequired: true,
+			Permission: auth.PermProductsWrite, Timeout: 30,
+		},
+	}
+}
+
+// defaultJWTConfig 默认从用户服务获取验签公钥，每5分钟刷新一次
+func defaultJWTConfig() JWTConfig {
+	return JWTConfig{
+		JWKSService:     "user-service",
+		RefreshInterval: 300,
+	}
+}
+
+// defaultRateLimitConfig 默认限流配置
+func defaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Enabled: true,
+		Default: RateLimitRule{
+			PerIP:   LimitConfig{Limit: 300, Window: 60},
+			PerUser: LimitConfig{Limit: 120, Window: 60},
+		},
+		Routes: []RouteRateLimit{
+			{
+				Path:          "/api/v1/users/login",
+				Methods:       []string{"POST"},
+				RateLimitRule: RateLimitRule{PerIP: LimitConfig{Limit: 10, Window: 60}},
+			},
+			{
+				Path:          "/api/v1/users/register",
+				Methods:       []string{"POST"},
+				RateLimitRule: RateLimitRule{PerIP: LimitConfig{Limit: 5, Window: 60}},
+			},
+			{
+				Path:          "/api/v1/users/refresh",
+				Methods:       []string{"POST"},
+				RateLimitRule: RateLimitRule{PerIP: LimitConfig{Limit: 30, Window: 60}},
+			},
+			{
+				Path:          "/api/v1/users/forgot-password",
+				Methods:       []string{"POST"},
+				RateLimitRule: RateLimitRule{PerIP: LimitConfig{Limit: 5, Window: 60}},
+			},
+			{
+				Path:          "/api/v1/users/reset-password",
+				Methods:       []string{"POST"},
+				RateLimitRule: RateLimitRule{PerIP: LimitConfig{Limit: 10, Window: 60}},
+			},
+			{
+				Path:    "/api/v1/wallets/transfer",
+				Methods: []string{"POST"},
+				RateLimitRule: RateLimitRule{
+					PerIP:   LimitConfig{Limit: 30, Window: 60},
+					PerUser: LimitConfig{Limit: 10, Window: 60},
+				},
+			},
+			{
+				Path:          "/api/v1/users/me/export",
+				Methods:       []string{"POST"},
+				RateLimitRule: RateLimitRule{PerUser: LimitConfig{Limit: 3, Window: 3600}},
+			},
+		},
+	}
+}
+
+// defaultCorsConfig 默认跨域策略，只允许本地开发的前端
+func defaultCorsConfig() CorsConfig {
+	return CorsConfig{
+		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:5173", "http://localhost:8080"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
+		MaxAge:           600,
+		Default: CorsRule{
+			AllowMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowHeaders: []string{"Content-Type", "Authorization", "X-Request-ID", "X-Canary", "traceparent", "tracestate"},
+		},
+		Routes: []RouteCorsRule{
+			{
+				Path:     "/api/v1/docs",
+				CorsRule: CorsRule{AllowMethods: []string{"GET"}, AllowHeaders: []string{"Accept"}},
+			},
 		},
 	}
 }
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"blog/api-gateway/discovery"
+	"blog/api-gateway/middleware"
+	"blog/shared/auth"
+	"blog/shared/tracing"
+	"blog/shared/validation"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Dearlimg/Goutils/pkg/app"
+	"github.com/Dearlimg/Goutils/pkg/app/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminController 网关管理接口，查看运行状态并摘除实例、开启维护模式
+type AdminController struct {
+	gateway     *GatewayController
+	rateLimit   *middleware.RateLimitMiddleware
+	maintenance *middleware.MaintenanceMiddleware
+}
+
+// NewAdminController 创建网关管理控制器
+func NewAdminController(gatewayController *GatewayController, rateLimitMiddleware *middleware.RateLimitMiddleware, maintenanceMiddleware *middleware.MaintenanceMiddleware) *AdminController {
+	return &AdminController{
+		gateway:     gatewayController,
+		rateLimit:   rateLimitMiddleware,
+		maintenance: maintenanceMiddleware,
+	}
+}
+
+// adminInstance 实例的运行状态
+type adminInstance struct {
+	ID             string            `json:"id"`
+	Endpoint       string            `json:"endpoint"`
+	Version        string            `json:"version"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	LastCheck      time.Time         `json:"last_check"`
+	ActiveRequests int64             `json:"active_requests"`
+	Drained        bool              `json:"drained"`
+}
+
+// adminService 服务的实例列表，没有注册实例时使用静态地址
+type adminService struct {
+	Service   string          `json:"service"`
+	Static    string          `json:"static,omitempty"`
+	Instances []adminInstance `json:"instances"`
+}
+
+// maintenanceRequest 开启维护模式的请求
+type maintenanceRequest struct {
+	Path        string `json:"path" binding:"required,startswith=/"`
+	Body        string `json:"body"`
+	ContentType string `json:"content_type"`
+	RetryAfter  int    `json:"retry_after" binding:"gte=0"`
+}
+
+// ListRoutes 返回当前生效的路由表和灰度规则
+func (a *AdminController) ListRoutes(c *gin.Context) {
+	rly := app.NewResponse(c)
+	rly.Reply(nil, gin.H{
+		"routes": a.gateway.routes.Routes(),
+		"canary": a.gateway.canary.Rules(),
+	})
+}
+
+// ListInstances 返回路由表中各服务的实例、进行中的请求数和摘除状态
+func (a *AdminController) ListInstances(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	services := make([]adminService, 0)
+	for _, service := range a.gateway.routedServices() {
+		result := adminService{Service: service, Instances: []adminInstance{}}
+		if static, ok := a.gateway.discovery.Static(service); ok {
+			result.Static = static
+		}
+		for _, instance := range a.gateway.discovery.Instances(service) {
+			result.Instances = append(result.Instances, adminInstance{
+				ID:             instance.ID,
+				Endpoint:       instance.Endpoint(),
+				Version:        instance.Version(),
+				Labels:         instance.Labels,
+				LastCheck:      instance.LastCheck,
+				ActiveRequests: instance.ActiveRequests(),
+				Drained:        a.gateway.discovery.Drained(service, instance.ID),
+			})
+		}
+		sort.Slice(result.Instances, func(i, j int) bool {
+			return result.Instances[i].ID < result.Instances[j].ID
+		})
+		services = append(services, result)
+	}
+
+	rly.Reply(nil, services)
+}
+
+// DrainInstance 摘除实例，网关不再向其转发新请求，进行中的请求不受影响
+func (a *AdminController) DrainInstance(c *gin.Context) {
+	a.setDrained(c, true)
+}
+
+// RestoreInstance 恢复被摘除的实例
+func (a *AdminController) RestoreInstance(c *gin.Context) {
+	a.setDrained(c, false)
+}
+
+// setDrained 修改实例的摘除状态
+func (a *AdminController) setDrained(c *gin.Context, drained bool) {
+	rly := app.NewResponse(c)
+	service, instanceID := c.Param("service"), c.Param("id")
+
+	err := a.gateway.discovery.SetDrained(service, instanceID, drained)
+	switch {
+	case errors.Is(err, discovery.ErrInstanceNotFound):
+		rly.Reply(errcode.ErrNotFound.WithDetails("instance not found: " + service + "/" + instanceID))
+		return
+	case err != nil:
+		tracing.Printf(c.Request.Context(), "Failed to update drain state of %s/%s: %v", service, instanceID, err)
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	tracing.Printf(c.Request.Context(), "Admin %s set drained=%v on %s/%s", c.GetHeader(auth.HeaderUserID), drained, service, instanceID)
+	rly.Reply(nil, gin.H{"service": service, "id": instanceID, "drained": drained})
+}
+
+// ListBreakers 返回各上游服务的熔断器状态
+func (a *AdminController) ListBreakers(c *gin.Context) {
+	rly := app.NewResponse(c)
+	rly.Reply(nil, a.gateway.breakers.Snapshots())
+}
+
+// RateLimitState 返回限流配置，指定 ip 或 user_id 时返回其在各条规则上已用的配额
+func (a *AdminController) RateLimitState(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	data := gin.H{
+		"enabled": a.rateLimit.Enabled(),
+		"config":  a.rateLimit.Config(),
+	}
+	ip, userID := c.Query("ip"), c.Query("user_id")
+	if ip != "" || userID != "" {
+		usage, err := a.rateLimit.Usage(c.Request.Context(), ip, userID)
+		if err != nil {
+			tracing.Printf(c.Request.Context(), "Failed to read rate limit usage: %v", err)
+			rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+			return
+		}
+		data["usage"] = usage
+	}
+
+	rly.Reply(nil, data)
+}
+
+// ListMaintenance 返回生效中的维护规则
+func (a *AdminController) ListMaintenance(c *gin.Context) {
+	rly := app.NewResponse(c)
+	rly.Reply(nil, a.maintenance.Rules())
+}
+
+// EnableMaintenance 开启路径前缀或全站（path 为 /）的维护模式
+func (a *AdminController) EnableMaintenance(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req maintenanceRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	rule := middleware.MaintenanceRule{
+		Path:        req.Path,
+		Body:        req.Body,
+		ContentType: req.ContentType,
+		RetryAfter:  req.RetryAfter,
+	}
+	if err := a.maintenance.Enable(c.Request.Context(), rule); err != nil {
+		tracing.Printf(c.Request.Context(), "Failed to enable maintenance for %s: %v", req.Path, err)
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	tracing.Printf(c.Request.Context(), "Admin %s enabled maintenance for %s", c.GetHeader(auth.HeaderUserID), req.Path)
+	rly.Reply(nil, a.maintenance.Rules())
+}
+
+// DisableMaintenance 关闭 path 查询参数指定的维护规则
+func (a *AdminController) DisableMaintenance(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	path := c.Query("path")
+	if path == "" {
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails("path is required"))
+		return
+	}
+
+	existed, err := a.maintenance.Disable(c.Request.Context(), path)
+	if err != nil {
+		tracing.Printf(c.Request.Context(), "Failed to disable maintenance for %s: %v", path, err)
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+	if !existed {
+		rly.Reply(errcode.ErrNotFound.WithDetails("no maintenance rule for " + path))
+		return
+	}
+
+	tracing.Printf(c.Request.Context(), "Admin %s disabled maintenance for %s", c.GetHeader(auth.HeaderUserID), path)
+	rly.Reply(nil, a.maintenance.Rules())
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"blog/api-gateway/cache"
+	"blog/api-gateway/config"
+	"blog/shared/tracing"
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheable 判断请求是否走响应缓存
+func (gc *GatewayController) cacheable(c *gin.Context, route config.RouteConfig) bool {
+	return gc.cache != nil && route.Cache != nil && route.Cache.TTL > 0 && c.Request.Method == http.MethodGet
+}
+
+// serveCached 命中缓存时直接响应，否则转发请求并缓存可缓存的响应
+func (gc *GatewayController) serveCached(c *gin.Context, route config.RouteConfig, version, upstreamPath string) {
+	proxy := gc.newReverseProxy(route.Service, version, upstreamPath)
+
+	lookup, store := cache.RequestDirectives(c.Request.Header)
+	if !store {
+		proxy.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+
+	key, err := gc.cache.Key(c.Request.Context(), route, version, c.Request)
+	if err != nil {
+		// Redis不可用时直接转发
+		tracing.Printf(c.Request.Context(), "Failed to build cache key for %s: %v", c.Request.URL.Path, err)
+		proxy.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+
+	if lookup {
+		if entry, ok := gc.cache.Get(c.Request.Context(), key); ok {
+			writeCachedResponse(c, entry)
+			return
+		}
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Header("X-Cache", "MISS")
+	proxy.ServeHTTP(recorder, c.Request)
+
+	if recorder.overflow {
+		return
+	}
+	ttl, ok := cache.ResponseTTL(recorder.Status(), recorder.Header(), recorder.body.Bytes(), time.Duration(route.Cache.TTL)*time.Second)
+	if !ok {
+		return
+	}
+
+	// 客户端可能已断开，写缓存不使用请求的context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := gc.cache.Set(ctx, key, recorder.Status(), recorder.Header(), recorder.body.Bytes(), ttl); err != nil {
+		tracing.Printf(c.Request.Context(), "Failed to store cached response for %s: %v", c.Request.URL.Path, err)
+	}
+}
+
+// writeCachedResponse 输出缓存的响应
+func writeCachedResponse(c *gin.Context, entry *cache.Entry) {
+	for name, values := range entry.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("X-Cache", "HIT")
+	c.Header("Age", strconv.Itoa(entry.Age()))
+	c.Header("Content-Length", strconv.Itoa(len(entry.Body)))
+	c.Status(entry.Status)
+	c.Writer.Write(entry.Body)
+}
+
+// responseRecorder 在转发响应的同时记录响应体，超过缓存上限后停止记录
+type responseRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+// Write 写入客户端并记录响应体
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.record(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// WriteString 写入客户端并记录响应体
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.record([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+// record 记录响应体
+func (r *responseRecorder) record(data []byte) {
+	if r.overflow {
+		return
+	}
+	if r.body.Len()+len(data) > cache.MaxBodySize {
+		r.overflow = true
+		r.body.Reset()
+		return
+	}
+	r.body.Write(data)
+}
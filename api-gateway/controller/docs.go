@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"blog/shared/tracing"
+	"net/http"
+
+	"github.com/Dearlimg/Goutils/pkg/app"
+	"github.com/gin-gonic/gin"
+)
+
+// docLink 文档列表中的一项
+type docLink struct {
+	Service string `json:"service"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+}
+
+// ListDocs 列出可用的接口文档
+func (gc *GatewayController) ListDocs(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	links := []docLink{}
+	if gc.specs != nil {
+		for _, service := range gc.specs.Services() {
+			links = append(links, docLink{
+				Service: service,
+				Title:   gc.specs.Title(service),
+				URL:     "/api/v1/docs/" + service,
+			})
+		}
+	}
+
+	rly.Reply(nil, links)
+}
+
+// GetDoc 返回服务的OpenAPI文档，路径按当前路由表换算为经网关访问的路径
+func (gc *GatewayController) GetDoc(c *gin.Context) {
+	if gc.specs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API documentation not available"})
+		return
+	}
+
+	doc, err := gc.specs.Document(c.Param("service"), gc.routes)
+	if err != nil {
+		tracing.Printf(c.Request.Context(), "Failed to build API documentation: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown service"})
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
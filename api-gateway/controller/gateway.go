@@ -1,172 +1,195 @@
 package controller
 
 import (
+	"blog/api-gateway/apispec"
+	"blog/api-gateway/breaker"
+	"blog/api-gateway/cache"
+	"blog/api-gateway/canary"
 	"blog/api-gateway/config"
+	"blog/api-gateway/discovery"
 	"blog/api-gateway/middleware"
-	"bytes"
-	"io"
-	"log"
+	"blog/api-gateway/routes"
+	"blog/shared/auth"
+	"blog/shared/health"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"time"
 
-	"github.com/Dearlimg/Goutils/pkg/app"
 	"github.com/gin-gonic/gin"
 )
 
 // GatewayController API网关控制器
 type GatewayController struct {
-	config *config.Config
-	client *http.Client
+	config    *config.Config
+	client    *http.Client
+	transport http.RoundTripper
+	discovery *discovery.Discovery
+	breakers  *breaker.Group
+	routes    *routes.Table
+	canary    *canary.Router
+	signer    *serviceauth.Signer
+	cache     *cache.Cache
+	specs     *apispec.Registry
+	// trustedProxies 可信的前置代理，只保留来自这些地址的 X-Forwarded-For
+	trustedProxies []*net.IPNet
 }
 
 // NewGatewayController 创建API网关控制器
-func NewGatewayController(cfg *config.Config) *GatewayController {
+// signer 为转发的请求签发服务令牌；responseCache 为nil时不缓存响应；specs 为nil时不校验请求，也不提供接口文档
+func NewGatewayController(cfg *config.Config, serviceDiscovery *discovery.Discovery, routeTable *routes.Table, canaryRouter *canary.Router, signer *serviceauth.Signer, responseCache *cache.Cache, specs *apispec.Registry) *GatewayController {
 	return &GatewayController{
 		config: cfg,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		transport: newProxyTransport(),
+		discovery: serviceDiscovery,
+		breakers: breaker.NewGroup(breaker.Settings{
+			FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+			OpenTimeout:      time.Duration(cfg.CircuitBreaker.OpenTimeout) * time.Second,
+			HalfOpenRequests: cfg.CircuitBreaker.HalfOpenRequests,
+		}),
+		routes:         routeTable,
+		canary:         canaryRouter,
+		signer:         signer,
+		cache:          responseCache,
+		specs:          specs,
+		trustedProxies: parseTrustedProxies(cfg.Server.TrustedProxies),
 	}
 }
 
 // ProxyRequest 代理请求到微服务
 func (gc *GatewayController) ProxyRequest(c *gin.Context) {
-	// 获取目标服务
-	targetService := c.Param("service")
-	targetPath := c.Param("path")
-
-	// 构建目标URL
-	var targetURL string
-	switch targetService {
-	case "users":
-		targetURL = "http://" + gc.config.Services.UserService.Host + ":" + gc.config.Services.UserService.Port + "/api/v1/users" + targetPath
-	case "wallets":
-		targetURL = "http://" + gc.config.Services.WalletService.Host + ":" + gc.config.Services.WalletService.Port + "/api/v1/wallets" + targetPath
-	case "comments":
-		targetURL = "http://" + gc.config.Services.CommentService.Host + ":" + gc.config.Services.CommentService.Port + "/api/v1/comments" + targetPath
-	case "products", "orders", "shop":
-		targetURL = "http://" + gc.config.Services.ShopService.Host + ":" + gc.config.Services.ShopService.Port + "/api/v1" + targetPath
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown service"})
+	route, err := gc.routes.Match(c.Request.Method, c.Request.URL.Path)
+	if errors.Is(err, routes.ErrMethodNotAllowed) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
 		return
 	}
-
-	// 读取请求体
-	var bodyBytes []byte
-	if c.Request.Body != nil {
-		bodyBytes, _ = io.ReadAll(c.Request.Body)
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	}
-
-	// 创建代理请求
-	req, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown service"})
 		return
 	}
 
-	// 复制请求头
-	for key, values := range c.Request.Header {
-		for _, value := range values {
-			req.Header.Add(key, value)
+	// 按上游服务的接口文档校验参数和请求体，不合法的请求不再转发
+	upstreamPath := routes.UpstreamPath(route, c.Request.URL.Path)
+	if gc.specs != nil {
+		if fields := gc.specs.Validate(c.Request, route.Service, upstreamPath); len(fields) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Request validation failed", "fields": fields})
+			return
 		}
 	}
 
-	// 发送请求
-	resp, err := gc.client.Do(req)
-	if err != nil {
-		log.Printf("Failed to proxy request to %s: %v", targetURL, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to proxy request"})
+	// 缓存幂等请求的小请求体，以便失败时重试
+	if err := bufferRetryBody(c.Request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 		return
 	}
-	defer resp.Body.Close()
 
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response"})
-		return
+	// 路由超时覆盖整个请求，包括重试和响应体传输
+	if route.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(route.Timeout)*time.Second)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
 	}
 
-	// 复制响应头
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
-		}
-	}
+	// 按灰度规则选择上游版本，认证通过的用户按用户ID固定分配
+	version := gc.canary.Select(route.Service, c.GetString(middleware.ContextUserIDKey), c.GetHeader(canary.HeaderCanary))
 
-	// 返回响应
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
-}
-
-// HealthCheck 健康检查
-func (gc *GatewayController) HealthCheck(c *gin.Context) {
-	rly := app.NewResponse(c)
-
-	// 检查各个微服务的健康状态
-	services := map[string]string{
-		"user-service":    "http://" + gc.config.Services.UserService.Host + ":" + gc.config.Services.UserService.Port + "/health",
-		"wallet-service":  "http://" + gc.config.Services.WalletService.Host + ":" + gc.config.Services.WalletService.Port + "/health",
-		"comment-service": "http://" + gc.config.Services.CommentService.Host + ":" + gc.config.Services.CommentService.Port + "/health",
-		"shop-service":    "http://" + gc.config.Services.ShopService.Host + ":" + gc.config.Services.ShopService.Port + "/api/v1/health",
-	}
-
-	status := make(map[string]string)
-	for serviceName, healthURL := range services {
-		resp, err := gc.client.Get(healthURL)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			status[serviceName] = "unhealthy"
-		} else {
-			status[serviceName] = "healthy"
-		}
-		if resp != nil {
-			resp.Body.Close()
-		}
+	// 流式转发请求和响应，实例选择、熔断和重试在传输层完成
+	if gc.cacheable(c, route) {
+		gc.serveCached(c, route, version, upstreamPath)
+		return
 	}
-
-	rly.Reply(nil, gin.H{
-		"gateway":  "healthy",
-		"services": status,
-	})
+	gc.newReverseProxy(route.Service, version, upstreamPath).ServeHTTP(c.Writer, c.Request)
 }
 
 // Server HTTP服务器
 type Server struct {
-	router *gin.Engine
-	port   string
+	router     *gin.Engine
+	port       string
+	httpServer *http.Server
 }
 
-// NewServer 创建HTTP服务器
-func NewServer(port string, gatewayController *GatewayController, corsMiddleware *middleware.CorsMiddleware, authMiddleware *middleware.AuthMiddleware) *Server {
+// NewServer 创建HTTP服务器，trustedProxies 为网关前可信代理的IP或CIDR，客户端地址只从这些代理的转发头中获取
+func NewServer(port string, trustedProxies []string, gatewayController *GatewayController, adminController *AdminController, corsMiddleware *middleware.CorsMiddleware, maintenanceMiddleware *middleware.MaintenanceMiddleware, authMiddleware *middleware.AuthMiddleware, rateLimitMiddleware *middleware.RateLimitMiddleware, healthChecker *health.Checker) (*Server, error) {
 	router := gin.New()
-	router.Use(gin.Recovery(), gin.Logger())
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+	router.Use(gin.Recovery())
+
+	// 网关自身的存活和就绪探针，注册在其余中间件之前，不经过认证、限流和访问日志
+	healthChecker.RegisterRoutes(router)
 
-	// 添加中间件
+	// 添加中间件，链路信息最先恢复，之后的日志都带有请求ID
+	router.Use(tracing.Middleware(), tracing.Logger())
 	router.Use(corsMiddleware.Handle())
+	router.Use(maintenanceMiddleware.Handle())
+
+	// 接口文档公开访问，注册在认证和限流之前
+	docs := router.Group("/api/v1/docs")
+	{
+		docs.GET("", gatewayController.ListDocs)
+		docs.GET("/:service", gatewayController.GetDoc)
+	}
+
 	router.Use(authMiddleware.Handle())
+	router.Use(rateLimitMiddleware.Handle())
 
 	// API路由
 	api := router.Group("/api/v1")
 	{
-		// 健康检查
+		// 微服务健康状态汇总
 		api.GET("/health", gatewayController.HealthCheck)
 
-		// 代理路由
-		api.Any("/:service/*path", gatewayController.ProxyRequest)
+		// 当前用户的首页概览，聚合多个服务的数据
+		api.GET("/me/overview", gatewayController.Overview)
 	}
 
+	// 网关管理接口，要求网关管理权限
+	admin := router.Group(middleware.AdminPathPrefix, middleware.RequirePermission(auth.PermGatewayAdmin))
+	{
+		admin.GET("/routes", adminController.ListRoutes)
+		admin.GET("/instances", adminController.ListInstances)
+		admin.PUT("/instances/:service/:id/drain", adminController.DrainInstance)
+		admin.DELETE("/instances/:service/:id/drain", adminController.RestoreInstance)
+		admin.GET("/breakers", adminController.ListBreakers)
+		admin.GET("/ratelimit", adminController.RateLimitState)
+		admin.GET("/maintenance", adminController.ListMaintenance)
+		admin.PUT("/maintenance", adminController.EnableMaintenance)
+		admin.DELETE("/maintenance", adminController.DisableMaintenance)
+	}
+
+	// 其余请求按路由表转发
+	router.NoRoute(gatewayController.ProxyRequest)
+
 	return &Server{
 		router: router,
 		port:   port,
-	}
+		httpServer: &http.Server{
+			Addr:              ":" + port,
+			Handler:           router,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}, nil
 }
 
 // Start 启动服务器
+// 调用Shutdown后返回nil
 func (s *Server) Start() error {
-	return s.router.Run(":" + s.port)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Shutdown 关闭服务器
-func (s *Server) Shutdown() {
-	// 这里可以添加优雅关闭逻辑
+// Shutdown 优雅关闭：停止接受新连接，等待处理中的请求完成，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"blog/shared/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dearlimg/Goutils/pkg/app"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultHealthCheckTimeout 未配置时单个实例检查的超时时间
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// instanceHealth 单个实例的就绪检查结果
+type instanceHealth struct {
+	Endpoint  string `json:"endpoint"`
+	Status    string `json:"status"` // healthy, unhealthy
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// serviceHealth 服务的就绪检查结果，任一实例就绪即视为健康
+type serviceHealth struct {
+	Status    string           `json:"status"`
+	Instances []instanceHealth `json:"instances"`
+}
+
+// HealthCheck 并发检查路由表中所有服务各实例的就绪状态
+func (gc *GatewayController) HealthCheck(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	timeout := time.Duration(gc.config.HealthCheck.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	services := make(map[string]*serviceHealth)
+	for _, service := range gc.routedServices() {
+		result := &serviceHealth{Status: "unhealthy", Instances: []instanceHealth{}}
+		services[service] = result
+
+		for _, endpoint := range gc.discovery.Endpoints(service) {
+			wg.Add(1)
+			go func(result *serviceHealth, endpoint string) {
+				defer wg.Done()
+				instance := gc.checkInstance(c.Request.Context(), endpoint, timeout)
+
+				mu.Lock()
+				defer mu.Unlock()
+				result.Instances = append(result.Instances, instance)
+				if instance.Status == "healthy" {
+					result.Status = "healthy"
+				}
+			}(result, endpoint)
+		}
+	}
+	wg.Wait()
+
+	for _, result := range services {
+		sort.Slice(result.Instances, func(i, j int) bool {
+			return result.Instances[i].Endpoint < result.Instances[j].Endpoint
+		})
+	}
+
+	rly.Reply(nil, gin.H{
+		"gateway":          "healthy",
+		"services":         services,
+		"circuit_breakers": gc.breakers.Snapshots(),
+	})
+}
+
+// checkInstance 检查单个实例的就绪状态
+func (gc *GatewayController) checkInstance(ctx context.Context, endpoint string, timeout time.Duration) instanceHealth {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := instanceHealth{Endpoint: endpoint, Status: "unhealthy"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+endpoint+"/health/ready", nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := gc.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Error = fmt.Sprintf("timed out after %v", timeout)
+		} else {
+			result.Error = err.Error()
+		}
+		result.LatencyMS = time.Since(start).Milliseconds()
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		result.Error = readinessFailure(ctx, endpoint, resp)
+		result.LatencyMS = time.Since(start).Milliseconds()
+		return result
+	}
+
+	result.Status = "healthy"
+	result.LatencyMS = time.Since(start).Milliseconds()
+	return result
+}
+
+// readinessFailure 从就绪检查的响应中提取失败的依赖
+// 响应中只返回依赖的名称，失败原因可能包含内部地址等信息，只记录在日志中
+func readinessFailure(ctx context.Context, endpoint string, resp *http.Response) string {
+	var body struct {
+		Checks map[string]struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"checks"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Checks) == 0 {
+		return fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	var failures []string
+	for name, check := range body.Checks {
+		if check.Status != "up" {
+			failures = append(failures, name)
+			tracing.Printf(ctx, "Instance %s dependency %s not ready: %s", endpoint, name, check.Error)
+		}
+	}
+	sort.Strings(failures)
+	return fmt.Sprintf("not ready (%s)", strings.Join(failures, ", "))
+}
+
+// routedServices 返回路由表中的所有上游服务
+func (gc *GatewayController) routedServices() []string {
+	seen := make(map[string]bool)
+	var services []string
+	for _, route := range gc.routes.Routes() {
+		if !seen[route.Service] {
+			seen[route.Service] = true
+			services = append(services, route.Service)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
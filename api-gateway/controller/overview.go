@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"blog/api-gateway/breaker"
+	"blog/api-gateway/canary"
+	"blog/api-gateway/middleware"
+	"blog/api-gateway/routes"
+	"blog/shared/auth"
+	"blog/shared/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Dearlimg/Goutils/pkg/app"
+	"github.com/Dearlimg/Goutils/pkg/app/errcode"
+	"github.com/gin-gonic/gin"
+)
+
+// 聚合接口未配置时的默认值
+const (
+	defaultAggregationTimeout = 3 * time.Second
+	defaultRecentLimit        = 5
+)
+
+// maxSectionBodySize 单个上游响应允许读取的最大大小
+const maxSectionBodySize = 1 << 20
+
+// overviewSection 概览中的一个数据块
+type overviewSection struct {
+	name string
+	path string // 网关对外的路径，按路由表转发，与客户端直接访问时一致
+	list bool   // 列表数据只保留最近的若干条，上游已按创建时间倒序返回
+}
+
+// overviewSections 当前用户概览包含的数据块
+func overviewSections(userID string) []overviewSection {
+	return []overviewSection{
+		{name: "profile", path: "/api/v1/users/" + userID},
+		{name: "wallet", path: "/api/v1/wallets/" + userID},
+		{name: "recent_orders", path: "/api/v1/shop/users/" + userID + "/orders", list: true},
+		{name: "recent_comments", path: "/api/v1/comments/user/" + userID, list: true},
+	}
+}
+
+// Overview 当前用户的首页概览
+//
+// 并发调用用户、钱包、商城和评论服务，合并为一个响应；
+// 部分上游失败时对应数据块为null，并在errors中说明原因，全部失败时返回错误
+func (gc *GatewayController) Overview(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	userID := c.GetString(middleware.ContextUserIDKey)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid token"})
+		return
+	}
+
+	timeout := time.Duration(gc.config.Aggregation.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultAggregationTimeout
+	}
+	limit := gc.config.Aggregation.RecentLimit
+	if limit <= 0 {
+		limit = defaultRecentLimit
+	}
+
+	sections := overviewSections(userID)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	data := make(map[string]interface{}, len(sections)+2)
+	sectionErrors := make(map[string]string)
+	for _, section := range sections {
+		wg.Add(1)
+		go func(section overviewSection) {
+			defer wg.Done()
+			result, err := gc.fetchSection(c.Request, section, timeout, limit)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				tracing.Printf(c.Request.Context(), "Failed to load overview section %s: %v", section.name, err)
+				data[section.name] = nil
+				sectionErrors[section.name] = sectionErrorMessage(err)
+				return
+			}
+			data[section.name] = result
+		}(section)
+	}
+	wg.Wait()
+
+	if len(sectionErrors) == len(sections) {
+		rly.Reply(errcode.ErrServer.WithDetails("all upstream services failed"))
+		return
+	}
+
+	data["partial"] = len(sectionErrors) > 0
+	data["errors"] = sectionErrors
+	rly.Reply(nil, data)
+}
+
+// upstreamError 上游返回了非200状态或非0业务码
+type upstreamError struct {
+	status  int
+	code    int
+	message string
+}
+
+// Error 实现 error
+func (e *upstreamError) Error() string {
+	if e.status != http.StatusOK {
+		return fmt.Sprintf("upstream responded %d", e.status)
+	}
+	return fmt.Sprintf("upstream error %d: %s", e.code, e.message)
+}
+
+// fetchSection 按路由表调用上游，返回业务数据
+// 经过与普通转发相同的实例选择、熔断和重试，并携带当前用户的身份头和链路信息
+func (gc *GatewayController) fetchSection(in *http.Request, section overviewSection, timeout time.Duration, limit int) (json.RawMessage, error) {
+	route, err := gc.routes.Match(http.MethodGet, section.path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(in.Context(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+route.Service+routes.UpstreamPath(route, section.path), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range auth.IdentityHeaders {
+		if value := in.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	version := gc.canary.Select(route.Service, in.Header.Get(auth.HeaderUserID), in.Header.Get(canary.HeaderCanary))
+	resp, err := gc.newUpstreamTransport(route.Service, version).RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &upstreamError{status: resp.StatusCode}
+	}
+
+	var body struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSectionBodySize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if body.Code != 0 {
+		return nil, &upstreamError{status: http.StatusOK, code: body.Code, message: body.Msg}
+	}
+
+	if !section.list {
+		return body.Data, nil
+	}
+	return recentItems(body.Data, limit)
+}
+
+// recentItems 截取列表的前limit条，空数据返回空列表
+func recentItems(data json.RawMessage, limit int) (json.RawMessage, error) {
+	var items []json.RawMessage
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to decode list: %v", err)
+		}
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	return json.Marshal(items)
+}
+
+// sectionErrorMessage 返回给客户端的数据块错误，不暴露上游地址等内部信息
+func sectionErrorMessage(err error) string {
+	var upstream *upstreamError
+	switch {
+	case errors.Is(err, breaker.ErrOpen) || errors.Is(err, errNoInstance):
+		return "service unavailable"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "upstream timeout"
+	case errors.Is(err, routes.ErrNotFound) || errors.Is(err, routes.ErrMethodNotAllowed):
+		return "no route"
+	case errors.As(err, &upstream):
+		if upstream.status != http.StatusOK {
+			return fmt.Sprintf("upstream responded %d", upstream.status)
+		}
+		return upstream.message
+	default:
+		return "upstream request failed"
+	}
+}
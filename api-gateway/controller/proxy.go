@@ -0,0 +1,363 @@
+package controller
+
+import (
+	"blog/api-gateway/breaker"
+	"blog/api-gateway/config"
+	"blog/api-gateway/discovery"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryBodySize 可重试请求允许缓存的最大请求体大小
+const maxRetryBodySize = 1 << 20
+
+// errNoInstance 没有可用上游实例
+var errNoInstance = errors.New("no available upstream instance")
+
+// newProxyTransport 创建代理使用的连接池
+// 不设置整体超时，以支持SSE、WebSocket等长连接；仅限制建连和等待响应头的时间
+func newProxyTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   50,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newReverseProxy 创建转发到指定上游服务的反向代理
+//
+// httputil.ReverseProxy 负责：
+//   - 剔除 Connection、Keep-Alive、Upgrade 等逐跳头（WebSocket升级请求除外）
+//   - 以流的方式转发请求体和响应体，不在内存中缓存
+//   - 对 text/event-stream 和未知长度的分块响应立即刷新
+//   - 使用客户端请求的 context，客户端断开时取消上游请求
+//
+// 实例选择、熔断和重试由 upstreamTransport 完成，version 为空表示不限版本
+func (gc *GatewayController) newReverseProxy(service, version, upstreamPath string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = service
+			pr.Out.URL.Path = upstreamPath
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = ""
+
+			// 上一跳是可信代理（如Nginx）时保留其写入的链路，否则丢弃客户端传入的值，再追加连接地址
+			if gc.trustsProxy(pr.In.RemoteAddr) {
+				pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			}
+			pr.SetXForwarded()
+
+			// 上游以网关的Span作为父节点
+			tracing.Inject(pr.In.Context(), pr.Out.Header)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// 网关已在响应头中写入请求ID，去掉上游的同名头避免重复
+			resp.Header.Del(tracing.HeaderRequestID)
+			return nil
+		},
+		Transport:     gc.newUpstreamTransport(service, version),
+		FlushInterval: 100 * time.Millisecond,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.Canceled) {
+				// 客户端已断开，无需响应
+				return
+			}
+
+			status, message := http.StatusBadGateway, "Failed to proxy request"
+			switch {
+			case errors.Is(err, breaker.ErrOpen) || errors.Is(err, errNoInstance):
+				// 快速失败，提示客户端稍后重试
+				status, message = http.StatusServiceUnavailable, "Service unavailable"
+				w.Header().Set("Retry-After", "5")
+			case errors.Is(err, context.DeadlineExceeded):
+				status, message = http.StatusGatewayTimeout, "Upstream timeout"
+			}
+
+			tracing.Printf(r.Context(), "Failed to proxy request to %s%s: %v", service, upstreamPath, err)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":"` + message + `"}`))
+		},
+	}
+}
+
+// parseTrustedProxies 解析可信代理的IP或CIDR
+// 无效的地址已由 NewServer 报错，这里直接跳过
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+	return nets
+}
+
+// trustsProxy 判断请求的上一跳是否为可信代理
+func (gc *GatewayController) trustsProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range gc.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newUpstreamTransport 创建转发到指定上游服务的传输层
+func (gc *GatewayController) newUpstreamTransport(service, version string) *upstreamTransport {
+	return &upstreamTransport{
+		base:      gc.transport,
+		service:   service,
+		version:   version,
+		discovery: gc.discovery,
+		signer:    gc.signer,
+		breaker:   gc.breakers.Get(service),
+		retry:     gc.config.Retry,
+	}
+}
+
+// upstreamTransport 为每次尝试选择上游实例，并执行熔断和重试
+type upstreamTransport struct {
+	base      http.RoundTripper
+	service   string
+	version   string
+	discovery *discovery.Discovery
+	signer    *serviceauth.Signer
+	breaker   *breaker.Breaker
+	retry     config.RetryConfig
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isRetryable(req) && t.retry.MaxRetries > 0 {
+		attempts += t.retry.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := t.backoff(req.Context(), attempt); err != nil {
+				return nil, err
+			}
+		}
+
+		endpoint, release, err := t.discovery.PickVersion(t.service, t.version)
+		if err != nil {
+			return nil, errNoInstance
+		}
+
+		done, err := t.breaker.Allow()
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		out := req.Clone(req.Context())
+		out.URL.Host = endpoint
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				done(true)
+				release()
+				return nil, err
+			}
+			out.Body = body
+		}
+		// 每次尝试单独签发，令牌有效期很短，重试时不能沿用
+		if err := t.signer.Sign(out, t.service); err != nil {
+			done(true)
+			release()
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(out)
+		if err != nil {
+			release()
+			if errors.Is(req.Context().Err(), context.Canceled) {
+				// 客户端取消不计入上游失败
+				done(true)
+				return nil, err
+			}
+			if req.Context().Err() != nil {
+				// 路由超时，不再重试
+				done(false)
+				return nil, err
+			}
+			done(false)
+			lastErr = err
+
+			// 超时不重试，避免成倍放大延迟
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, err
+			}
+			continue
+		}
+
+		done(resp.StatusCode < http.StatusInternalServerError)
+
+		if isRetryableStatus(resp.StatusCode) && attempt < attempts-1 {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			release()
+			lastErr = errors.New("upstream responded " + strconv.Itoa(resp.StatusCode))
+			continue
+		}
+
+		resp.Body = wrapReleaseBody(resp.Body, release)
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// backoff 按指数退避加随机抖动等待，客户端取消时提前返回
+func (t *upstreamTransport) backoff(ctx context.Context, attempt int) error {
+	base := time.Duration(t.retry.BaseBackoff) * time.Millisecond
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	maxBackoff := time.Duration(t.retry.MaxBackoff) * time.Millisecond
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+
+	delay := base << (attempt - 1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	// 等待时间在 [delay/2, delay) 之间随机，避免多个请求同时重试
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isIdempotent 判断HTTP方法是否幂等
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryable 幂等且请求体可重放的请求才允许重试
+func isRetryable(req *http.Request) bool {
+	if !isIdempotent(req.Method) {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isRetryableStatus 判断上游响应状态是否值得重试
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// bufferRetryBody 缓存幂等请求的小请求体，使其可以在重试时重放
+func bufferRetryBody(req *http.Request) error {
+	if !isIdempotent(req.Method) || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.ContentLength <= 0 || req.ContentLength > maxRetryBodySize {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return nil
+}
+
+// releaseBody 响应体关闭时释放实例的连接计数
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close 关闭响应体并释放实例
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// releaseReadWriteBody WebSocket升级后的双向连接，ReverseProxy要求响应体实现io.Writer
+type releaseReadWriteBody struct {
+	io.ReadWriteCloser
+	release func()
+}
+
+// Close 关闭连接并释放实例
+func (b *releaseReadWriteBody) Close() error {
+	err := b.ReadWriteCloser.Close()
+	b.release()
+	return err
+}
+
+// wrapReleaseBody 包装响应体，保持其是否可写的特性
+func wrapReleaseBody(body io.ReadCloser, release func()) io.ReadCloser {
+	var once sync.Once
+	releaseOnce := func() { once.Do(release) }
+
+	if rwc, ok := body.(io.ReadWriteCloser); ok {
+		return &releaseReadWriteBody{ReadWriteCloser: rwc, release: releaseOnce}
+	}
+	return &releaseBody{ReadCloser: body, release: releaseOnce}
+}
@@ -0,0 +1,63 @@
+package discovery
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// 负载均衡策略
+const (
+	StrategyRoundRobin       = "round_robin"
+	StrategyLeastConnections = "least_connections"
+)
+
+// Balancer 负载均衡器接口
+type Balancer interface {
+	// Pick 从可用实例中选择一个，instances 保证非空
+	// service 用于区分负载均衡状态，按版本分流时为 服务名@版本
+	Pick(service string, instances []*Instance) *Instance
+}
+
+// NewBalancer 根据策略名称创建负载均衡器，未知策略使用轮询
+func NewBalancer(strategy string) Balancer {
+	switch strategy {
+	case StrategyLeastConnections:
+		return &leastConnectionsBalancer{}
+	default:
+		return &roundRobinBalancer{counters: make(map[string]*uint64)}
+	}
+}
+
+// roundRobinBalancer 轮询负载均衡
+type roundRobinBalancer struct {
+	mu       sync.Mutex
+	counters map[string]*uint64
+}
+
+// Pick 按服务维度依次选择实例
+func (b *roundRobinBalancer) Pick(service string, instances []*Instance) *Instance {
+	b.mu.Lock()
+	counter, ok := b.counters[service]
+	if !ok {
+		counter = new(uint64)
+		b.counters[service] = counter
+	}
+	b.mu.Unlock()
+
+	n := atomic.AddUint64(counter, 1)
+	return instances[(n-1)%uint64(len(instances))]
+}
+
+// leastConnectionsBalancer 最少连接负载均衡
+type leastConnectionsBalancer struct{}
+
+// Pick 选择当前进行中请求数最少的实例
+func (b *leastConnectionsBalancer) Pick(service string, instances []*Instance) *Instance {
+	best := instances[0]
+	for _, instance := range instances[1:] {
+		if instance.ActiveRequests() < best.ActiveRequests() {
+			best = instance
+		}
+	}
+	return best
+}
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"blog/shared/registry"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInstanceNotFound 注册中心中没有指定的实例
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// ErrNoRegistry 没有连接注册中心，无法摘除实例
+var ErrNoRegistry = errors.New("service registry unavailable")
+
+// Instance 可路由的上游实例
+type Instance struct {
+	registry.ServiceRegistration
+	active int64
+}
+
+// ActiveRequests 返回实例当前进行中的请求数
+func (i *Instance) ActiveRequests() int64 {
+	return atomic.LoadInt64(&i.active)
+}
+
+// Discovery 基于注册中心的服务发现
+// 定期从Redis注册中心拉取实例列表，注册中心不可用或没有实例时回退到静态配置
+type Discovery struct {
+	registry *registry.ServiceRegistry
+	balancer Balancer
+	static   map[string]string
+
+	mu        sync.RWMutex
+	instances map[string][]*Instance
+	drained   map[string]bool // 服务名/实例ID
+}
+
+// NewDiscovery 创建服务发现，serviceRegistry 可以为nil
+func NewDiscovery(serviceRegistry *registry.ServiceRegistry, balancer Balancer, static map[string]string) *Discovery {
+	d := &Discovery{
+		registry:  serviceRegistry,
+		balancer:  balancer,
+		static:    static,
+		instances: make(map[string][]*Instance),
+	}
+	d.Refresh()
+	return d
+}
+
+// Start 按间隔刷新实例列表，直到stop被关闭
+func (d *Discovery) Start(interval time.Duration, stop <-chan struct{}) {
+	if d.registry == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.Refresh()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Refresh 从注册中心重新加载所有服务的实例
+func (d *Discovery) Refresh() {
+	if d.registry == nil {
+		return
+	}
+
+	registrations, err := d.registry.GetAllServices()
+	if err != nil {
+		// 保留上一次的实例列表
+		log.Printf("Failed to refresh service instances: %v", err)
+		return
+	}
+	drained, err := d.registry.DrainedInstances()
+	if err != nil {
+		log.Printf("Failed to refresh drained instances: %v", err)
+		d.mu.RLock()
+		drained = d.drained
+		d.mu.RUnlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 保留已存在实例的连接计数
+	existing := make(map[string]*Instance)
+	for _, instances := range d.instances {
+		for _, instance := range instances {
+			existing[instance.ServiceName+"/"+instance.ID] = instance
+		}
+	}
+
+	instances := make(map[string][]*Instance)
+	for _, reg := range registrations {
+		instance, ok := existing[reg.ServiceName+"/"+reg.ID]
+		if ok {
+			instance.ServiceRegistration = reg
+		} else {
+			instance = &Instance{ServiceRegistration: reg}
+		}
+		instances[reg.ServiceName] = append(instances[reg.ServiceName], instance)
+	}
+	d.instances = instances
+	d.drained = drained
+}
+
+// Instances 返回服务当前的实例列表，包括被摘除的实例
+func (d *Discovery) Instances(service string) []*Instance {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.instances[service]
+}
+
+// Drained 判断实例是否已被摘除流量
+func (d *Discovery) Drained(service, instanceID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.drained[service+"/"+instanceID]
+}
+
+// SetDrained 摘除或恢复实例的流量，写入注册中心后立即刷新，其他网关副本在下次刷新时生效
+func (d *Discovery) SetDrained(service, instanceID string, drained bool) error {
+	if d.registry == nil {
+		return ErrNoRegistry
+	}
+	if drained && !d.hasInstance(service, instanceID) {
+		return ErrInstanceNotFound
+	}
+	if err := d.registry.SetDrained(service, instanceID, drained); err != nil {
+		return err
+	}
+	d.Refresh()
+	return nil
+}
+
+// hasInstance 判断服务是否有指定的实例
+func (d *Discovery) hasInstance(service, instanceID string) bool {
+	for _, instance := range d.Instances(service) {
+		if instance.ID == instanceID {
+			return true
+		}
+	}
+	return false
+}
+
+// routable 返回服务未被摘除的实例
+func (d *Discovery) routable(service string) []*Instance {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	instances := d.instances[service]
+	if len(d.drained) == 0 {
+		return instances
+	}
+	routable := make([]*Instance, 0, len(instances))
+	for _, instance := range instances {
+		if !d.drained[service+"/"+instance.ID] {
+			routable = append(routable, instance)
+		}
+	}
+	return routable
+}
+
+// Static 返回服务静态配置的回退地址
+func (d *Discovery) Static(service string) (string, bool) {
+	endpoint, ok := d.static[service]
+	return endpoint, ok
+}
+
+// Endpoints 返回服务所有实例的地址，没有实例时返回静态配置的地址
+func (d *Discovery) Endpoints(service string) []string {
+	instances := d.Instances(service)
+	if len(instances) == 0 {
+		if endpoint, ok := d.static[service]; ok {
+			return []string{endpoint}
+		}
+		return nil
+	}
+
+	endpoints := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		endpoints = append(endpoints, instance.Endpoint())
+	}
+	return endpoints
+}
+
+// Pick 为服务选择一个实例，返回实例地址和请求结束后必须调用的释放函数
+func (d *Discovery) Pick(service string) (string, func(), error) {
+	return d.PickVersion(service, "")
+}
+
+// PickVersion 在指定版本的实例中选择一个，version 为空表示不限版本
+// 该版本没有存活实例时回退到服务的全部实例，避免分流配置先于部署生效时请求失败
+// 实例全部被摘除时返回错误，不回退到静态地址
+func (d *Discovery) PickVersion(service, version string) (string, func(), error) {
+	if len(d.Instances(service)) == 0 {
+		if endpoint, ok := d.static[service]; ok {
+			return endpoint, func() {}, nil
+		}
+		return "", nil, fmt.Errorf("no available instance for service: %s", service)
+	}
+	instances := d.routable(service)
+	if len(instances) == 0 {
+		return "", nil, fmt.Errorf("all instances of %s are drained", service)
+	}
+
+	// 各版本的负载均衡状态相互独立
+	key := service
+	if version != "" {
+		if matched := filterVersion(instances, version); len(matched) > 0 {
+			instances = matched
+			key = service + "@" + version
+		}
+	}
+
+	instance := d.balancer.Pick(key, instances)
+	atomic.AddInt64(&instance.active, 1)
+	return instance.Endpoint(), func() {
+		atomic.AddInt64(&instance.active, -1)
+	}, nil
+}
+
+// filterVersion 返回指定版本的实例
+func filterVersion(instances []*Instance, version string) []*Instance {
+	var matched []*Instance
+	for _, instance := range instances {
+		if instance.Version() == version {
+			matched = append(matched, instance)
+		}
+	}
+	return matched
+}
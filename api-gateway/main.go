@@ -1,28 +1,176 @@
 package main
 
 import (
+	"blog/api-gateway/apispec"
+	"blog/api-gateway/cache"
+	"blog/api-gateway/canary"
 	"blog/api-gateway/config"
 	"blog/api-gateway/controller"
+	"blog/api-gateway/discovery"
 	"blog/api-gateway/middleware"
+	"blog/api-gateway/routes"
+	"blog/shared/auth"
+	"blog/shared/health"
+	"blog/shared/jwks"
+	"blog/shared/kafka"
+	"blog/shared/registry"
+	"blog/shared/serviceauth"
+	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
 )
 
+// shutdownTimeout 关闭时等待处理中请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// 初始化配置
 	cfg := config.LoadConfig()
 
+	// 初始化服务发现（注册中心不可用时回退到静态配置）
+	serviceRegistry, err := registry.NewServiceRegistry(cfg.Registry.Addr, cfg.Registry.Password, cfg.Registry.DB)
+	if err != nil {
+		log.Printf("Failed to connect to service registry: %v, using static service config", err)
+	} else {
+		defer serviceRegistry.Close()
+	}
+
+	staticServices := map[string]string{
+		"user-service":    cfg.Services.UserService.Host + ":" + cfg.Services.UserService.Port,
+		"wallet-service":  cfg.Services.WalletService.Host + ":" + cfg.Services.WalletService.Port,
+		"comment-service": cfg.Services.CommentService.Host + ":" + cfg.Services.CommentService.Port,
+		"shop-service":    cfg.Services.ShopService.Host + ":" + cfg.Services.ShopService.Port,
+	}
+	serviceDiscovery := discovery.NewDiscovery(serviceRegistry, discovery.NewBalancer(cfg.LoadBalancer.Strategy), staticServices)
+
+	refreshInterval := time.Duration(cfg.LoadBalancer.RefreshInterval) * time.Second
+	if refreshInterval <= 0 {
+		refreshInterval = 5 * time.Second
+	}
+	stopDiscovery := make(chan struct{})
+	defer close(stopDiscovery)
+	serviceDiscovery.Start(refreshInterval, stopDiscovery)
+
+	// 初始化路由表、跨域策略和灰度规则，并监听配置中心的变更
+	routeTable, err := routes.NewTable(cfg.Routes)
+	if err != nil {
+		log.Fatalf("Invalid route table: %v", err)
+	}
+	corsMiddleware, err := middleware.NewCorsMiddleware(cfg.Cors)
+	if err != nil {
+		log.Fatalf("Invalid CORS policy: %v", err)
+	}
+	canaryRouter, err := canary.NewRouter(cfg.Canary)
+	if err != nil {
+		log.Fatalf("Invalid canary rules: %v", err)
+	}
+	stopWatch, err := config.WatchConfig(func(newCfg *config.Config) {
+		if err := routeTable.Update(newCfg.Routes); err != nil {
+			log.Printf("Ignoring invalid route table update: %v", err)
+		} else {
+			log.Printf("Route table updated: %d routes", len(newCfg.Routes))
+		}
+		if err := corsMiddleware.Update(newCfg.Cors); err != nil {
+			log.Printf("Ignoring invalid CORS policy update: %v", err)
+		} else {
+			log.Printf("CORS policy updated: %d allowed origins", len(newCfg.Cors.AllowedOrigins))
+		}
+		if err := canaryRouter.Update(newCfg.Canary); err != nil {
+			log.Printf("Ignoring invalid canary rules update: %v", err)
+		} else {
+			log.Printf("Canary rules updated: %d services", len(newCfg.Canary))
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to watch config center: %v, route table, CORS policy and canary rules will not be reloaded", err)
+	} else {
+		defer stopWatch()
+	}
+
+	// 初始化Redis（限流计数和响应缓存在多个网关副本间共享）
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	defer redisClient.Close()
+
+	// 登出后的访问令牌记录在Redis的吊销列表中，启动时Redis不可用也始终检查：
+	// 检查失败时需要认证的请求返回503，Redis恢复后go-redis自动重连
+	revocations := auth.NewRevocationList(redisClient)
+
+	// 启动时Redis不可用也保留客户端：限流和响应缓存在每个请求上访问失败时放行，Redis恢复后自动生效
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := redisClient.Ping(pingCtx).Err(); err != nil {
+		log.Printf("Failed to connect to Redis: %v, rate limiting and response cache are bypassed and authenticated requests fail until Redis recovers", err)
+	}
+	cancelPing()
+
+	// 初始化响应缓存，缓存失效依赖Kafka事件
+	responseCache := cache.NewCache(redisClient)
+	consumer, err := kafka.NewConsumer(cfg.Kafka.Brokers)
+	if err != nil {
+		log.Printf("Failed to create Kafka consumer: %v, cached responses expire by TTL only", err)
+	} else {
+		defer consumer.Close()
+		stopInvalidator := make(chan struct{})
+		defer close(stopInvalidator)
+		cache.NewInvalidator(responseCache, consumer, routeTable).Start(30*time.Second, stopInvalidator)
+	}
+
+	// 加载各服务的接口文档，用于校验请求和对外提供文档
+	specs, err := apispec.NewRegistry()
+	if err != nil {
+		log.Printf("Failed to load API specs: %v, request validation and docs disabled", err)
+		specs = nil
+	}
+
+	// 转发到下游服务的请求携带网关签发的服务令牌
+	signer, err := serviceauth.NewSigner("api-gateway", cfg.ServiceAuth)
+	if err != nil {
+		log.Fatalf("Invalid service auth config: %v", err)
+	}
+
 	// 初始化控制器
-	gatewayController := controller.NewGatewayController(cfg)
+	gatewayController := controller.NewGatewayController(cfg, serviceDiscovery, routeTable, canaryRouter, signer, responseCache, specs)
+
+	// 验签公钥从用户服务的JWKS接口获取，定期刷新，遇到未知kid时立即重新获取
+	jwksCache := jwks.NewCache(func(ctx context.Context) (*jwks.Set, error) {
+		endpoint, done, err := serviceDiscovery.Pick(cfg.JWT.JWKSService)
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+		return jwks.Fetch(ctx, http.DefaultClient, "http://"+endpoint+jwks.Path)
+	})
+	stopJWKS := make(chan struct{})
+	defer close(stopJWKS)
+	jwksCache.Start(time.Duration(cfg.JWT.RefreshInterval)*time.Second, stopJWKS)
 
 	// 初始化中间件
-	corsMiddleware := middleware.NewCorsMiddleware()
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWT.Secret)
+	authMiddleware := middleware.NewAuthMiddleware(jwksCache, routeTable, revocations)
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(redisClient, cfg.RateLimit)
+
+	// 维护规则保存在Redis中，与实例列表按相同的间隔在网关副本间同步
+	maintenanceMiddleware := middleware.NewMaintenanceMiddleware(redisClient)
+	stopMaintenance := make(chan struct{})
+	defer close(stopMaintenance)
+	maintenanceMiddleware.Start(refreshInterval, stopMaintenance)
+
+	adminController := controller.NewAdminController(gatewayController, rateLimitMiddleware, maintenanceMiddleware)
 
 	// 启动HTTP服务器
-	server := controller.NewServer(cfg.Server.Port, gatewayController, corsMiddleware, authMiddleware)
+	healthChecker := health.NewChecker("api-gateway")
+	server, err := controller.NewServer(cfg.Server.Port, cfg.Server.TrustedProxies, gatewayController, adminController, corsMiddleware, maintenanceMiddleware, authMiddleware, rateLimitMiddleware, healthChecker)
+	if err != nil {
+		log.Fatalf("Failed to create API Gateway server: %v", err)
+	}
 
 	log.Printf("API Gateway starting on port %s", cfg.Server.Port)
 
@@ -39,5 +187,14 @@ func main() {
 	<-quit
 
 	log.Println("API Gateway shutting down...")
-	server.Shutdown()
+
+	// 就绪检查返回503，前置负载均衡不再转发新请求；等待处理中的请求完成
+	healthChecker.SetShuttingDown()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("API Gateway forced to shutdown: %v", err)
+	}
+
+	// 返回后按注册的相反顺序执行defer：停止维护规则同步和公钥集刷新、停止缓存失效消费者、关闭Redis、停止配置监听和服务发现
 }
@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"blog/api-gateway/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorsMiddleware CORS中间件，策略来自配置中心，支持运行时整体替换
+type CorsMiddleware struct {
+	mu     sync.RWMutex
+	policy *corsPolicy
+}
+
+// corsPolicy 预先解析的跨域策略
+type corsPolicy struct {
+	anyOrigin     bool
+	origins       []originPattern
+	credentials   bool
+	exposeHeaders string
+	maxAge        string
+	rule          corsRule
+	routes        []routeCorsRule
+}
+
+// corsRule 拼接好的响应头取值
+type corsRule struct {
+	methods      []string
+	allowMethods string
+	allowHeaders string
+}
+
+// routeCorsRule 路由级规则
+type routeCorsRule struct {
+	path string
+	rule corsRule
+}
+
+// originPattern 允许的来源，wildcard为true时host是去掉 *. 后的父域名
+type originPattern struct {
+	scheme   string
+	host     string
+	port     string
+	wildcard bool
+}
+
+// NewCorsMiddleware 创建CORS中间件
+func NewCorsMiddleware(cfg config.CorsConfig) (*CorsMiddleware, error) {
+	c := &CorsMiddleware{}
+	if err := c.Update(cfg); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update 校验并替换跨域策略，校验失败时保留原策略
+func (c *CorsMiddleware) Update(cfg config.CorsConfig) error {
+	policy, err := compileCorsPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.policy = policy
+	c.mu.Unlock()
+	return nil
+}
+
+// Handle CORS处理
+// 只对允许的来源返回CORS响应头；不允许的来源的预检请求返回403，普通请求照常处理，由浏览器拦截响应
+func (c *CorsMiddleware) Handle() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 响应随Origin变化，共享缓存需要按Origin区分
+		ctx.Writer.Header().Add("Vary", "Origin")
+
+		origin := ctx.Request.Header.Get("Origin")
+		if origin == "" {
+			ctx.Next()
+			return
+		}
+
+		c.mu.RLock()
+		policy := c.policy
+		c.mu.RUnlock()
+
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.Request.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			ctx.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			ctx.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !policy.allowOrigin(origin) {
+			if preflight {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		if policy.anyOrigin && !policy.credentials {
+			ctx.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			ctx.Header("Access-Control-Allow-Origin", origin)
+		}
+		if policy.credentials {
+			ctx.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if policy.exposeHeaders != "" {
+				ctx.Header("Access-Control-Expose-Headers", policy.exposeHeaders)
+			}
+			ctx.Next()
+			return
+		}
+
+		rule := policy.match(ctx.Request.URL.Path)
+		if !containsMethod(rule.methods, ctx.Request.Header.Get("Access-Control-Request-Method")) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "method not allowed by CORS policy"})
+			return
+		}
+		ctx.Header("Access-Control-Allow-Methods", rule.allowMethods)
+		if rule.allowHeaders != "" {
+			ctx.Header("Access-Control-Allow-Headers", rule.allowHeaders)
+		}
+		if policy.maxAge != "" {
+			ctx.Header("Access-Control-Max-Age", policy.maxAge)
+		}
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// allowOrigin 判断来源是否在允许列表中
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return false
+	}
+	for _, pattern := range p.origins {
+		if pattern.matches(u.Scheme, u.Hostname(), u.Port()) {
+			return true
+		}
+	}
+	return false
+}
+
+// match 按最长路径前缀匹配路由规则
+func (p *corsPolicy) match(path string) corsRule {
+	rule, matched := p.rule, -1
+	for _, route := range p.routes {
+		if strings.HasPrefix(path, route.path) && len(route.path) > matched {
+			rule, matched = route.rule, len(route.path)
+		}
+	}
+	return rule
+}
+
+// matches 协议和端口必须相同；通配符只匹配子域名，不匹配父域名本身
+func (o originPattern) matches(scheme, host, port string) bool {
+	if scheme != o.scheme || port != o.port {
+		return false
+	}
+	if !o.wildcard {
+		return host == o.host
+	}
+	return strings.HasSuffix(host, "."+o.host) && len(host) > len(o.host)+1
+}
+
+// compileCorsPolicy 校验配置并预先拼接响应头
+func compileCorsPolicy(cfg config.CorsConfig) (*corsPolicy, error) {
+	if cfg.MaxAge < 0 {
+		return nil, errors.New("cors: max_age must not be negative")
+	}
+
+	policy := &corsPolicy{
+		credentials:   cfg.AllowCredentials,
+		exposeHeaders: strings.Join(cfg.ExposeHeaders, ", "),
+	}
+	if cfg.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				return nil, errors.New("cors: allowed_origins must not contain * when allow_credentials is enabled")
+			}
+			policy.anyOrigin = true
+			continue
+		}
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		policy.origins = append(policy.origins, pattern)
+	}
+
+	rule, err := compileCorsRule(cfg.Default)
+	if err != nil {
+		return nil, fmt.Errorf("cors default: %v", err)
+	}
+	policy.rule = rule
+
+	for i, route := range cfg.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("cors route %d: path must start with /: %q", i, route.Path)
+		}
+		rule, err := compileCorsRule(route.CorsRule)
+		if err != nil {
+			return nil, fmt.Errorf("cors route %d (%s): %v", i, route.Path, err)
+		}
+		policy.routes = append(policy.routes, routeCorsRule{path: route.Path, rule: rule})
+	}
+	return policy, nil
+}
+
+// compileCorsRule 方法统一为大写，预检请求不需要声明OPTIONS
+func compileCorsRule(rule config.CorsRule) (corsRule, error) {
+	if len(rule.AllowMethods) == 0 {
+		return corsRule{}, errors.New("allow_methods is required")
+	}
+
+	methods := make([]string, 0, len(rule.AllowMethods))
+	for _, method := range rule.AllowMethods {
+		methods = append(methods, strings.ToUpper(strings.TrimSpace(method)))
+	}
+	return corsRule{
+		methods:      methods,
+		allowMethods: strings.Join(methods, ", "),
+		allowHeaders: strings.Join(rule.AllowHeaders, ", "),
+	}, nil
+}
+
+// parseOriginPattern 解析 scheme://host[:port]，host 可以以 *. 开头
+func parseOriginPattern(origin string) (originPattern, error) {
+	u, err := url.Parse(strings.ToLower(strings.TrimSpace(origin)))
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return originPattern{}, fmt.Errorf("cors: invalid origin %q, expected scheme://host[:port]", origin)
+	}
+
+	pattern := originPattern{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+	if strings.HasPrefix(pattern.host, "*.") {
+		pattern.wildcard = true
+		pattern.host = strings.TrimPrefix(pattern.host, "*.")
+	}
+	if pattern.host == "" || strings.Contains(pattern.host, "*") {
+		return originPattern{}, fmt.Errorf("cors: invalid origin %q, wildcard is only allowed as the leftmost label", origin)
+	}
+	return pattern, nil
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"blog/api-gateway/config"
+	"testing"
+)
+
+func TestParseOriginPattern(t *testing.T) {
+	tests := []struct {
+		origin  string
+		want    originPattern
+		wantErr bool
+	}{
+		{origin: "https://app.example.com", want: originPattern{scheme: "https", host: "app.example.com"}},
+		{origin: " HTTPS://App.Example.com/ ", want: originPattern{scheme: "https", host: "app.example.com"}},
+		{origin: "http://localhost:3000", want: originPattern{scheme: "http", host: "localhost", port: "3000"}},
+		{origin: "https://*.example.com", want: originPattern{scheme: "https", host: "example.com", wildcard: true}},
+		{origin: "https://*.example.com:8443", want: originPattern{scheme: "https", host: "example.com", port: "8443", wildcard: true}},
+		{origin: "app.example.com", wantErr: true},
+		{origin: "https://", wantErr: true},
+		{origin: "https://app.example.com/path", wantErr: true},
+		{origin: "https://app.example.com?x=1", wantErr: true},
+		{origin: "https://*", wantErr: true},
+		{origin: "https://app.*.example.com", wantErr: true},
+		{origin: "https://*.*.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			got, err := parseOriginPattern(tt.origin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOriginPattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseOriginPattern() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowOrigin(t *testing.T) {
+	policy, err := compileCorsPolicy(config.CorsConfig{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org", "http://localhost:3000"},
+		Default:        config.CorsRule{AllowMethods: []string{"GET"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"https://app.example.com/", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"https://evil.example.com", false},
+		{"https://app.example.com.evil.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://badexample.org", false},
+		{"https://a.example.org:443", false},
+		{"http://localhost:3000", true},
+		{"http://localhost", false},
+		{"http://localhost:3001", false},
+		{"https://app.example.com/path", false},
+		{"null", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := policy.allowOrigin(tt.origin); got != tt.want {
+				t.Errorf("allowOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileCorsPolicy(t *testing.T) {
+	rule := config.CorsRule{AllowMethods: []string{"GET"}}
+	tests := []struct {
+		name    string
+		cfg     config.CorsConfig
+		wantErr bool
+	}{
+		{"any origin", config.CorsConfig{AllowedOrigins: []string{"*"}, Default: rule}, false},
+		{"any origin with credentials", config.CorsConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true, Default: rule}, true},
+		{"invalid origin", config.CorsConfig{AllowedOrigins: []string{"example.com"}, Default: rule}, true},
+		{"missing methods", config.CorsConfig{AllowedOrigins: []string{"https://example.com"}}, true},
+		{"negative max age", config.CorsConfig{MaxAge: -1, Default: rule}, true},
+		{"relative route path", config.CorsConfig{Default: rule, Routes: []config.RouteCorsRule{{Path: "api", CorsRule: rule}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileCorsPolicy(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("compileCorsPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
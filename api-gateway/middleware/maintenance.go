@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// maintenanceKey Redis中保存维护规则的哈希，字段为路径前缀
+const maintenanceKey = "gateway:maintenance"
+
+// AdminPathPrefix 网关管理接口的路径前缀，维护模式下仍可访问
+const AdminPathPrefix = "/api/v1/admin"
+
+// defaultMaintenanceBody 未指定响应体时返回的内容
+const defaultMaintenanceBody = `{"error":"Service under maintenance"}`
+
+// MaintenanceRule 维护规则，匹配的请求直接返回503
+type MaintenanceRule struct {
+	Path        string    `json:"path"`         // 路径前缀，按路径段匹配；/ 表示全站
+	Body        string    `json:"body"`         // 503响应体，为空时返回默认的JSON错误
+	ContentType string    `json:"content_type"` // 响应体类型，默认 application/json
+	RetryAfter  int       `json:"retry_after"`  // Retry-After 秒数，0表示不返回该头
+	Since       time.Time `json:"since"`
+}
+
+// MaintenanceMiddleware 维护模式中间件
+// 规则保存在Redis中，多个网关副本定期同步；Redis不可用时只对当前副本生效
+type MaintenanceMiddleware struct {
+	client *redis.Client
+
+	mu    sync.RWMutex
+	rules map[string]MaintenanceRule
+}
+
+// NewMaintenanceMiddleware 创建维护模式中间件，client 可以为nil
+func NewMaintenanceMiddleware(client *redis.Client) *MaintenanceMiddleware {
+	m := &MaintenanceMiddleware{client: client, rules: make(map[string]MaintenanceRule)}
+	if err := m.Reload(context.Background()); err != nil {
+		log.Printf("Failed to load maintenance rules: %v", err)
+	}
+	return m
+}
+
+// Start 按间隔从Redis同步维护规则，直到stop被关闭
+func (m *MaintenanceMiddleware) Start(interval time.Duration, stop <-chan struct{}) {
+	if m.client == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.Reload(context.Background()); err != nil {
+					log.Printf("Failed to reload maintenance rules: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Reload 从Redis重新加载维护规则，读取失败时保留当前规则
+func (m *MaintenanceMiddleware) Reload(ctx context.Context) error {
+	if m.client == nil {
+		return nil
+	}
+
+	values, err := m.client.HGetAll(ctx, maintenanceKey).Result()
+	if err != nil {
+		return err
+	}
+
+	rules := make(map[string]MaintenanceRule, len(values))
+	for path, value := range values {
+		var rule MaintenanceRule
+		if err := json.Unmarshal([]byte(value), &rule); err != nil {
+			log.Printf("Ignoring invalid maintenance rule for %s: %v", path, err)
+			continue
+		}
+		rules[path] = rule
+	}
+
+	m.mu.Lock()
+	m.rules = rules
+	m.mu.Unlock()
+	return nil
+}
+
+// Rules 返回当前的维护规则，按路径排序
+func (m *MaintenanceMiddleware) Rules() []MaintenanceRule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make([]MaintenanceRule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Path < rules[j].Path
+	})
+	return rules
+}
+
+// Enable 开启路径前缀的维护模式，已存在时覆盖
+func (m *MaintenanceMiddleware) Enable(ctx context.Context, rule MaintenanceRule) error {
+	if !strings.HasPrefix(rule.Path, "/") {
+		return errors.New("path must start with /")
+	}
+	if rule.RetryAfter < 0 {
+		return errors.New("retry_after must not be negative")
+	}
+	if rule.Body != "" && rule.ContentType == "" {
+		rule.ContentType = "application/json; charset=utf-8"
+		if !json.Valid([]byte(rule.Body)) {
+			rule.ContentType = "text/plain; charset=utf-8"
+		}
+	}
+	rule.Since = time.Now()
+
+	if m.client != nil {
+		data, err := json.Marshal(rule)
+		if err != nil {
+			return err
+		}
+		if err := m.client.HSet(ctx, maintenanceKey, rule.Path, data).Err(); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	m.rules[rule.Path] = rule
+	m.mu.Unlock()
+	return nil
+}
+
+// Disable 关闭路径前缀的维护模式，返回规则是否存在
+func (m *MaintenanceMiddleware) Disable(ctx context.Context, path string) (bool, error) {
+	m.mu.RLock()
+	_, existed := m.rules[path]
+	m.mu.RUnlock()
+
+	if m.client != nil {
+		removed, err := m.client.HDel(ctx, maintenanceKey, path).Result()
+		if err != nil {
+			return false, err
+		}
+		existed = existed || removed > 0
+	}
+
+	m.mu.Lock()
+	delete(m.rules, path)
+	m.mu.Unlock()
+	return existed, nil
+}
+
+// Handle 维护模式处理，管理接口不受影响
+func (m *MaintenanceMiddleware) Handle() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		path := ctx.Request.URL.Path
+		if path == AdminPathPrefix || strings.HasPrefix(path, AdminPathPrefix+"/") {
+			ctx.Next()
+			return
+		}
+
+		rule, ok := m.match(path)
+		if !ok {
+			ctx.Next()
+			return
+		}
+
+		body, contentType := rule.Body, rule.ContentType
+		if body == "" {
+			body, contentType = defaultMaintenanceBody, "application/json; charset=utf-8"
+		}
+		if rule.RetryAfter > 0 {
+			ctx.Header("Retry-After", strconv.Itoa(rule.RetryAfter))
+		}
+		ctx.Data(http.StatusServiceUnavailable, contentType, []byte(body))
+		ctx.Abort()
+	}
+}
+
+// match 按最长路径前缀匹配维护规则
+func (m *MaintenanceMiddleware) match(path string) (MaintenanceRule, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var best MaintenanceRule
+	matched := -1
+	for prefix, rule := range m.rules {
+		if len(prefix) <= matched || !matchPathPrefix(path, prefix) {
+			continue
+		}
+		best, matched = rule, len(prefix)
+	}
+	return best, matched >= 0
+}
+
+// matchPathPrefix 按路径段匹配前缀，/api/v1/shop 匹配 /api/v1/shop/1 但不匹配 /api/v1/shopx
+func matchPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
@@ -1,71 +1,50 @@
 package middleware
 
 import (
+	"blog/api-gateway/routes"
+	"blog/shared/auth"
+	"blog/shared/jwks"
+	"blog/shared/tracing"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// CorsMiddleware CORS中间件
-type CorsMiddleware struct{}
+// ContextUserIDKey 认证通过后在gin上下文中保存用户ID（JWT sub）的键
+const ContextUserIDKey = "user_id"
 
-// NewCorsMiddleware 创建CORS中间件
-func NewCorsMiddleware() *CorsMiddleware {
-	return &CorsMiddleware{}
-}
-
-// Handle CORS处理
-func (c *CorsMiddleware) Handle() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		origin := ctx.Request.Header.Get("Origin")
-		if origin != "" {
-			ctx.Header("Access-Control-Allow-Origin", origin)
-		} else {
-			ctx.Header("Access-Control-Allow-Origin", "*")
-		}
-
-		ctx.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		ctx.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		ctx.Header("Access-Control-Allow-Credentials", "true")
-
-		if ctx.Request.Method == "OPTIONS" {
-			ctx.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		ctx.Next()
-	}
-}
-
-// AuthMiddleware 认证中间件
+// AuthMiddleware 认证中间件，是否需要认证由路由表决定
 type AuthMiddleware struct {
-	secret string
+	keys        *jwks.Cache
+	routes      *routes.Table
+	revocations *auth.RevocationList
 }
 
-// NewAuthMiddleware 创建认证中间件
-func NewAuthMiddleware(secret string) *AuthMiddleware {
-	return &AuthMiddleware{secret: secret}
+// NewAuthMiddleware 创建认证中间件，keys 为用户服务公布的验签公钥，revocations 为登出后的访问令牌吊销列表
+func NewAuthMiddleware(keys *jwks.Cache, routeTable *routes.Table, revocations *auth.RevocationList) *AuthMiddleware {
+	return &AuthMiddleware{keys: keys, routes: routeTable, revocations: revocations}
 }
 
 // Handle 认证处理
 func (a *AuthMiddleware) Handle() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		// 跳过认证的路径
-		skipPaths := []string{
-			"/api/v1/users/register",
-			"/api/v1/users/login",
-			"/api/v1/users/verify-email",
-			"/api/v1/users/resend-code",
+		// 删除客户端伪造的身份头，只有认证通过后才由网关注入
+		for _, header := range auth.IdentityHeaders {
+			ctx.Request.Header.Del(header)
 		}
+		// 下游服务按该地址统计登录失败等，不再解析转发头
+		ctx.Request.Header.Set(auth.HeaderClientIP, ctx.ClientIP())
 
-		path := ctx.Request.URL.Path
-		for _, skipPath := range skipPaths {
-			if strings.HasPrefix(path, skipPath) {
-				ctx.Next()
-				return
-			}
+		// 公开路由跳过认证；未匹配的路径仍要求认证
+		route, err := a.routes.Match(ctx.Request.Method, ctx.Request.URL.Path)
+		matched := err == nil
+		if matched && !route.AuthRequired {
+			ctx.Next()
+			return
 		}
 
 		// JWT 验证
@@ -76,17 +55,115 @@ func (a *AuthMiddleware) Handle() gin.HandlerFunc {
 		}
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrTokenSignatureInvalid
-			}
-			return []byte(a.secret), nil
-		})
+		// 按令牌头的kid选择公钥，只接受EdDSA签名；从未取到公钥集时无法判断令牌真伪，返回503
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, a.keys.Keyfunc, jwt.WithValidMethods([]string{jwks.Algorithm}))
+		if errors.Is(err, jwks.ErrKeysUnavailable) {
+			tracing.Printf(ctx.Request.Context(), "Failed to verify token: %v", err)
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication temporarily unavailable"})
+			return
+		}
 		if err != nil || !token.Valid {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
+		// 没有jti的令牌无法吊销，不予接受
+		userID := claimString(claims, "sub")
+		jti := claimString(claims, "jti")
+		if userID == "" || jti == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		// 已登出或刷新令牌被重放的访问令牌；吊销列表不可用时拒绝请求，避免已吊销的令牌被放行
+		revoked, err := a.revocations.IsRevoked(ctx.Request.Context(), jti)
+		if err != nil {
+			tracing.Printf(ctx.Request.Context(), "Failed to check token revocation: %v", err)
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication temporarily unavailable"})
+			return
+		}
+		if revoked {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+
+		// 路由要求验证邮箱时拒绝未验证用户的写请求；没有verified声明的旧令牌视为未验证，刷新后即可获得
+		verified, _ := claims["verified"].(bool)
+		if matched && route.VerifiedRequired && !verified && !auth.IsReadMethod(ctx.Request.Method) {
+			auth.AbortUnverified(ctx)
+			return
+		}
+
+		// 路由要求的权限只认JWT中的permissions声明，角色变化后刷新令牌才会生效
+		permissions := claimStrings(claims, "permissions")
+		if matched && route.Permission != "" && !containsString(permissions, route.Permission) {
+			auth.AbortPermissionDenied(ctx, route.Permission)
+			return
+		}
+
+		// 保存用户ID，供后续中间件（限流等）使用
+		ctx.Set(ContextUserIDKey, userID)
+
+		// 向下游服务注入可信身份头
+		ctx.Request.Header.Set(auth.HeaderUserID, userID)
+		if email := claimString(claims, "email"); email != "" {
+			ctx.Request.Header.Set(auth.HeaderUserEmail, email)
+		}
+		if role := claimString(claims, "role"); role != "" {
+			ctx.Request.Header.Set(auth.HeaderUserRole, role)
+		}
+		ctx.Request.Header.Set(auth.HeaderUserVerified, strconv.FormatBool(verified))
+		if len(permissions) > 0 {
+			ctx.Request.Header.Set(auth.HeaderUserPermissions, strings.Join(permissions, ","))
+		}
+
 		ctx.Next()
 	}
 }
+
+// RequirePermission 要求JWT中带有指定权限，需要放在AuthMiddleware之后
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 身份头已由AuthMiddleware按JWT重新设置，客户端无法伪造
+		if !containsString(auth.ParsePermissions(ctx.Request.Header.Get(auth.HeaderUserPermissions)), permission) {
+			auth.AbortPermissionDenied(ctx, permission)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// claimStrings 读取字符串数组声明，忽略非字符串元素
+func claimStrings(claims jwt.MapClaims, key string) []string {
+	values, _ := claims[key].([]interface{})
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" && !strings.Contains(s, ",") {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// containsString 判断列表中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// claimString 以字符串形式读取声明，兼容数字类型的sub
+func claimString(claims jwt.MapClaims, key string) string {
+	switch v := claims[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"blog/api-gateway/config"
+	"blog/shared/tracing"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindowScript 滑动窗口限流脚本
+// 使用有序集合记录窗口内每个请求的时间戳，时间取自Redis，保证多个网关副本之间一致
+// 返回 {是否允许, 剩余配额, 窗口重置前的毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	return {1, limit - count - 1, window - (now - tonumber(oldest[2]))}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, 0, window - (now - tonumber(oldest[2]))}
+`)
+
+// usageScript 读取窗口内已用的配额，不记录请求，返回 {已用次数, 窗口重置前的毫秒数}
+var usageScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local count = redis.call('ZCOUNT', key, '(' .. (now - window), '+inf')
+if count == 0 then
+	return {0, 0}
+end
+local oldest = redis.call('ZRANGEBYSCORE', key, '(' .. (now - window), '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+return {count, window - (now - tonumber(oldest[2]))}
+`)
+
+// RateLimitUsage 某个身份在一条限流规则上的配额使用情况
+type RateLimitUsage struct {
+	Scope     string   `json:"scope"` // 规则的路径前缀，默认规则为 default
+	Methods   []string `json:"methods,omitempty"`
+	Dimension string   `json:"dimension"` // ip, user
+	Identity  string   `json:"identity"`
+	Limit     int      `json:"limit"`
+	Window    int      `json:"window"`
+	Used      int      `json:"used"`
+	ResetMS   int64    `json:"reset_ms"`
+}
+
+// limitResult 单次限流检查结果
+type limitResult struct {
+	allowed   bool
+	limit     int
+	remaining int
+	reset     time.Duration
+}
+
+// RateLimitMiddleware 基于Redis的限流中间件
+// 需要放在AuthMiddleware之后，以便按JWT中的用户ID限流
+type RateLimitMiddleware struct {
+	client *redis.Client
+	config config.RateLimitConfig
+}
+
+// NewRateLimitMiddleware 创建限流中间件
+func NewRateLimitMiddleware(client *redis.Client, cfg config.RateLimitConfig) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		client: client,
+		config: cfg,
+	}
+}
+
+// Handle 限流处理
+func (r *RateLimitMiddleware) Handle() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if r.client == nil || !r.config.Enabled {
+			ctx.Next()
+			return
+		}
+
+		scope, rule := r.matchRule(ctx.Request.Method, ctx.Request.URL.Path)
+
+		// 先检查IP维度，再检查用户维度，返回最严格的结果
+		var results []limitResult
+		if rule.PerIP.Limit > 0 {
+			results = append(results, r.check(ctx.Request.Context(), "ip", scope, ctx.ClientIP(), rule.PerIP))
+		}
+		if userID := ctx.GetString(ContextUserIDKey); userID != "" && rule.PerUser.Limit > 0 {
+			results = append(results, r.check(ctx.Request.Context(), "user", scope, userID, rule.PerUser))
+		}
+		if len(results) == 0 {
+			ctx.Next()
+			return
+		}
+
+		strictest := results[0]
+		for _, result := range results[1:] {
+			if !result.allowed || (strictest.allowed && result.remaining < strictest.remaining) {
+				strictest = result
+			}
+		}
+
+		resetSeconds := int((strictest.reset + time.Second - 1) / time.Second)
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(strictest.limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(strictest.remaining))
+		ctx.Header("X-RateLimit-Reset", strconv.Itoa(resetSeconds))
+
+		if !strictest.allowed {
+			ctx.Header("Retry-After", strconv.Itoa(resetSeconds))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Enabled 限流是否生效，未启用或Redis不可用时不限流
+func (r *RateLimitMiddleware) Enabled() bool {
+	return r.client != nil && r.config.Enabled
+}
+
+// Config 返回限流配置
+func (r *RateLimitMiddleware) Config() config.RateLimitConfig {
+	return r.config
+}
+
+// Usage 查询IP和用户在各条规则上已用的配额，ip 或 userID 为空时跳过对应维度
+func (r *RateLimitMiddleware) Usage(ctx context.Context, ip, userID string) ([]RateLimitUsage, error) {
+	if r.client == nil {
+		return nil, errors.New("rate limit storage unavailable")
+	}
+
+	type scopedRule struct {
+		scope   string
+		methods []string
+		rule    config.RateLimitRule
+	}
+	rules := []scopedRule{{scope: "default", rule: r.config.Default}}
+	for _, route := range r.config.Routes {
+		rules = append(rules, scopedRule{scope: route.Path, methods: route.Methods, rule: route.RateLimitRule})
+	}
+
+	usage := []RateLimitUsage{}
+	for _, rule := range rules {
+		dimensions := []struct {
+			name     string
+			identity string
+			limit    config.LimitConfig
+		}{
+			{"ip", ip, rule.rule.PerIP},
+			{"user", userID, rule.rule.PerUser},
+		}
+		for _, dimension := range dimensions {
+			if dimension.identity == "" || dimension.limit.Limit <= 0 {
+				continue
+			}
+			window := time.Duration(dimension.limit.Window) * time.Second
+			if window <= 0 {
+				window = time.Minute
+			}
+
+			key := "ratelimit:" + dimension.name + ":" + rule.scope + ":" + dimension.identity
+			values, err := usageScript.Run(ctx, r.client, []string{key}, window.Milliseconds()).Int64Slice()
+			if err != nil || len(values) != 2 {
+				return nil, fmt.Errorf("failed to read rate limit usage for %s: %v", key, err)
+			}
+			usage = append(usage, RateLimitUsage{
+				Scope:     rule.scope,
+				Methods:   rule.methods,
+				Dimension: dimension.name,
+				Identity:  dimension.identity,
+				Limit:     dimension.limit.Limit,
+				Window:    int(window / time.Second),
+				Used:      int(values[0]),
+				ResetMS:   values[1],
+			})
+		}
+	}
+	return usage, nil
+}
+
+// matchRule 按最长路径前缀匹配路由规则，未匹配时使用默认规则
+func (r *RateLimitMiddleware) matchRule(method, path string) (string, config.RateLimitRule) {
+	scope, rule := "default", r.config.Default
+	matched := -1
+	for _, route := range r.config.Routes {
+		if !strings.HasPrefix(path, route.Path) || len(route.Path) <= matched {
+			continue
+		}
+		if len(route.Methods) > 0 && !containsMethod(route.Methods, method) {
+			continue
+		}
+		scope, rule, matched = route.Path, route.RateLimitRule, len(route.Path)
+	}
+	return scope, rule
+}
+
+// check 在指定维度上执行一次滑动窗口检查，Redis不可用时放行
+func (r *RateLimitMiddleware) check(ctx context.Context, dimension, scope, identity string, limit config.LimitConfig) limitResult {
+	window := time.Duration(limit.Window) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	key := "ratelimit:" + dimension + ":" + scope + ":" + identity
+	values, err := slidingWindowScript.Run(ctx, r.client, []string{key}, limit.Limit, window.Milliseconds(), requestMember()).Int64Slice()
+	if err != nil || len(values) != 3 {
+		tracing.Printf(ctx, "Rate limit check failed for %s: %v", key, err)
+		return limitResult{allowed: true, limit: limit.Limit, remaining: limit.Limit, reset: window}
+	}
+
+	return limitResult{
+		allowed:   values[0] == 1,
+		limit:     limit.Limit,
+		remaining: int(values[1]),
+		reset:     time.Duration(values[2]) * time.Millisecond,
+	}
+}
+
+// containsMethod 判断方法列表中是否包含指定方法
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMember 生成有序集合中唯一的成员名
+func requestMember() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"blog/api-gateway/config"
+	"errors"
+	"testing"
+)
+
+func TestTableMatch(t *testing.T) {
+	table, err := NewTable([]config.RouteConfig{
+		{Prefix: "/api/v1/users", Service: "user-service"},
+		{Prefix: "/api/v1/users/admin", Service: "user-service", AuthRequired: true},
+		{Prefix: "/api/v1/products", Service: "shop-service"},
+		{Prefix: "/api/v1/products", Service: "shop-service", Methods: []string{"POST", "PUT", "DELETE"}, AuthRequired: true},
+		{Prefix: "/api/v1/orders", Service: "shop-service", Methods: []string{"GET"}},
+		{Prefix: "/static/", Service: "cdn"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		wantService  string
+		wantAuth     bool
+		wantErr      error
+	}{
+		{"GET", "/api/v1/users", "user-service", false, nil},
+		{"GET", "/api/v1/users/1", "user-service", false, nil},
+		{"GET", "/api/v1/users/admin/lockouts", "user-service", true, nil},
+		{"GET", "/api/v1/usersx", "", false, ErrNotFound},
+		{"GET", "/api/v1/users/administrators", "user-service", false, nil},
+		{"GET", "/api/v1/products/1", "shop-service", false, nil},
+		{"post", "/api/v1/products", "shop-service", true, nil},
+		{"DELETE", "/api/v1/products/1", "shop-service", true, nil},
+		{"GET", "/api/v1/orders/1", "shop-service", false, nil},
+		{"POST", "/api/v1/orders", "", false, ErrMethodNotAllowed},
+		{"GET", "/static/app.js", "cdn", false, nil},
+		{"GET", "/staticx", "", false, ErrNotFound},
+		{"GET", "/", "", false, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			route, err := table.Match(tt.method, tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Match() error = %v, want %v", err, tt.wantErr)
+			}
+			if route.Service != tt.wantService || route.AuthRequired != tt.wantAuth {
+				t.Errorf("Match() = %s (auth %v), want %s (auth %v)", route.Service, route.AuthRequired, tt.wantService, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestUpstreamPath(t *testing.T) {
+	tests := []struct {
+		name            string
+		prefix, rewrite string
+		path, want      string
+	}{
+		{"no rewrite", "/api/v1/users", "", "/api/v1/users/1", "/api/v1/users/1"},
+		{"rewrite prefix", "/api/v1/blog", "/api/v1/posts", "/api/v1/blog/1/comments", "/api/v1/posts/1/comments"},
+		{"rewrite exact path", "/api/v1/blog", "/api/v1/posts", "/api/v1/blog", "/api/v1/posts"},
+		{"rewrite to root", "/legacy", "/", "/legacy/items", "/items"},
+		{"trailing slashes", "/static/", "/assets/", "/static/app.js", "/assets/app.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := config.RouteConfig{Prefix: tt.prefix, Rewrite: tt.rewrite}
+			if got := UpstreamPath(route, tt.path); got != tt.want {
+				t.Errorf("UpstreamPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   config.RouteConfig
+		wantErr bool
+	}{
+		{"valid", config.RouteConfig{Prefix: "/api", Service: "svc"}, false},
+		{"relative prefix", config.RouteConfig{Prefix: "api", Service: "svc"}, true},
+		{"missing service", config.RouteConfig{Prefix: "/api"}, true},
+		{"relative rewrite", config.RouteConfig{Prefix: "/api", Service: "svc", Rewrite: "v2"}, true},
+		{"permission without auth", config.RouteConfig{Prefix: "/api", Service: "svc", Permission: "users:manage"}, true},
+		{"negative timeout", config.RouteConfig{Prefix: "/api", Service: "svc", Timeout: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]config.RouteConfig{tt.route})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if Validate(nil) == nil {
+		t.Error("Validate(nil) accepted an empty route table")
+	}
+}
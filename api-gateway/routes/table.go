@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"blog/api-gateway/config"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotFound 没有匹配的路由
+	ErrNotFound = errors.New("route not found")
+	// ErrMethodNotAllowed 路径匹配但方法不允许
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+// Table 网关路由表，支持运行时整体替换
+type Table struct {
+	mu     sync.RWMutex
+	routes []config.RouteConfig // 按前缀长度降序排列，前缀相同时限定了方法的路由在前
+}
+
+// NewTable 创建路由表
+func NewTable(routes []config.RouteConfig) (*Table, error) {
+	t := &Table{}
+	if err := t.Update(routes); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Update 校验并替换整个路由表，校验失败时保留原路由表
+func (t *Table) Update(routes []config.RouteConfig) error {
+	if err := Validate(routes); err != nil {
+		return err
+	}
+
+	sorted := make([]config.RouteConfig, len(routes))
+	copy(sorted, routes)
+	// 前缀相同时限定了方法的路由优先，如商品的写操作单独配置权限
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].Prefix) != len(sorted[j].Prefix) {
+			return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+		}
+		return len(sorted[i].Methods) > 0 && len(sorted[j].Methods) == 0
+	})
+
+	t.mu.Lock()
+	t.routes = sorted
+	t.mu.Unlock()
+	return nil
+}
+
+// Routes 返回当前路由表的副本
+func (t *Table) Routes() []config.RouteConfig {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	routes := make([]config.RouteConfig, len(t.routes))
+	copy(routes, t.routes)
+	return routes
+}
+
+// Match 按最长路径前缀匹配路由
+// 前缀相同的多条路由按方法区分，限定了方法的路由优先；路径匹配但方法都不允许时返回ErrMethodNotAllowed
+func (t *Table) Match(method, path string) (config.RouteConfig, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pathMatched := false
+	for _, route := range t.routes {
+		if !matchPrefix(path, route.Prefix) {
+			continue
+		}
+		if len(route.Methods) == 0 || containsMethod(route.Methods, method) {
+			return route, nil
+		}
+		pathMatched = true
+	}
+
+	if pathMatched {
+		return config.RouteConfig{}, ErrMethodNotAllowed
+	}
+	return config.RouteConfig{}, ErrNotFound
+}
+
+// UpstreamPath 按路由的重写规则计算上游路径
+func UpstreamPath(route config.RouteConfig, path string) string {
+	if route.Rewrite == "" {
+		return path
+	}
+	return strings.TrimSuffix(route.Rewrite, "/") + strings.TrimPrefix(path, strings.TrimSuffix(route.Prefix, "/"))
+}
+
+// Validate 校验路由表
+func Validate(routes []config.RouteConfig) error {
+	if len(routes) == 0 {
+		return errors.New("route table is empty")
+	}
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Prefix, "/") {
+			return fmt.Errorf("route %d: prefix must start with /: %q", i, route.Prefix)
+		}
+		if route.Service == "" {
+			return fmt.Errorf("route %d (%s): service is required", i, route.Prefix)
+		}
+		if route.Rewrite != "" && !strings.HasPrefix(route.Rewrite, "/") {
+			return fmt.Errorf("route %d (%s): rewrite must start with /: %q", i, route.Prefix, route.Rewrite)
+		}
+		if route.Permission != "" && !route.AuthRequired {
+			return fmt.Errorf("route %d (%s): permission requires auth_required", i, route.Prefix)
+		}
+		if route.Timeout < 0 {
+			return fmt.Errorf("route %d (%s): timeout must not be negative", i, route.Prefix)
+		}
+	}
+	return nil
+}
+
+// matchPrefix 按路径段匹配前缀，/api/v1/users 匹配 /api/v1/users/1 但不匹配 /api/v1/usersx
+func matchPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// containsMethod 判断方法列表中是否包含指定方法
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"blog/shared/config"
+	"blog/shared/serviceauth"
 	"encoding/json"
 	"log"
 	"os"
@@ -9,10 +10,11 @@ import (
 
 // Config 评论服务配置
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Redis    RedisConfig    `json:"redis"`
-	Kafka    KafkaConfig    `json:"kafka"`
+	Server      ServerConfig       `json:"server"`
+	Database    DatabaseConfig     `json:"database"`
+	Redis       RedisConfig        `json:"redis"`
+	Kafka       KafkaConfig        `json:"kafka"`
+	ServiceAuth serviceauth.Config `json:"service_auth"`
 }
 
 // ServerConfig 服务器配置
@@ -67,6 +69,11 @@ func LoadConfig() *Config {
 		return loadDefaultConfig()
 	}
 
+	// 配置中心未配置服务密钥时使用默认密钥
+	if len(cfg.ServiceAuth.Trusted) == 0 {
+		cfg.ServiceAuth = serviceauth.DefaultConfig("comment-service", "api-gateway")
+	}
+
 	log.Printf("Config loaded from Redis config center for comment-service")
 	return &cfg
 }
@@ -124,5 +131,6 @@ func loadDefaultConfig() *Config {
 		Kafka: KafkaConfig{
 			Brokers: []string{kafkaHost + ":" + kafkaPort},
 		},
+		ServiceAuth: serviceauth.DefaultConfig("comment-service", "api-gateway"),
 	}
 }
@@ -2,8 +2,17 @@ package controller
 
 import (
 	"blog/comment-service/logic"
+	"blog/shared/auth"
+	"blog/shared/health"
 	"blog/shared/models"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"blog/shared/validation"
+	"context"
+	"errors"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Dearlimg/Goutils/pkg/app"
 	"github.com/Dearlimg/Goutils/pkg/app/errcode"
@@ -25,12 +34,11 @@ func (cc *CommentController) CreateComment(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req models.CommentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	comment, err := cc.commentLogic.CreateComment(&req)
+	comment, err := cc.commentLogic.CreateComment(c.Request.Context(), &req)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -103,16 +111,27 @@ func (cc *CommentController) UpdateComment(c *gin.Context) {
 		return
 	}
 
+	// 只能修改自己的评论，拥有评论管理权限的版主和管理员除外
+	existing, err := cc.commentLogic.GetComment(uint(id))
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	if !auth.CanAccess(c, existing.UserID) && !auth.HasPermission(c, auth.PermCommentsModerate) {
+		auth.AbortForbidden(c)
+		return
+	}
+
 	var req struct {
-		Content string `json:"content" validate:"required"`
+		Content string `json:"content" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	comment, err := cc.commentLogic.UpdateComment(uint(id), req.Content)
+	comment, err := cc.commentLogic.UpdateComment(c.Request.Context(), uint(id), req.Content)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -132,63 +151,86 @@ func (cc *CommentController) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	err = cc.commentLogic.DeleteComment(uint(id))
+	// 只能修改自己的评论，拥有评论管理权限的版主和管理员除外
+	existing, err := cc.commentLogic.GetComment(uint(id))
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
 	}
 
-	rly.Reply(nil, "Comment deleted successfully")
-}
+	if !auth.CanAccess(c, existing.UserID) && !auth.HasPermission(c, auth.PermCommentsModerate) {
+		auth.AbortForbidden(c)
+		return
+	}
+
+	err = cc.commentLogic.DeleteComment(c.Request.Context(), uint(id))
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
 
-// HealthCheck 健康检查
-func (cc *CommentController) HealthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status":  "healthy",
-		"service": "comment-service",
-	})
+	rly.Reply(nil, "Comment deleted successfully")
 }
 
 // Server HTTP服务器
 type Server struct {
-	router *gin.Engine
-	port   string
+	router     *gin.Engine
+	port       string
+	httpServer *http.Server
 }
 
 // NewServer 创建HTTP服务器
-func NewServer(port string, commentController *CommentController) *Server {
+func NewServer(port string, commentController *CommentController, verifier *serviceauth.Verifier, healthChecker *health.Checker) *Server {
 	router := gin.New()
-	router.Use(gin.Recovery(), gin.Logger())
+	router.Use(gin.Recovery(), tracing.Middleware(), tracing.Logger())
 
 	// 健康检查路由
-	router.GET("/health", commentController.HealthCheck)
+	healthChecker.RegisterRoutes(router)
 
-	// 评论相关路由
+	// 评论相关路由，只接受网关转发的请求；未验证邮箱的用户只能浏览
 	api := router.Group("/api/v1")
+	api.Use(verifier.Require(callerACL()), auth.Middleware(), auth.RequireVerifiedWrites())
 	{
 		comments := api.Group("/comments")
 		{
-			comments.POST("", commentController.CreateComment)
+			comments.POST("", auth.RequireSelfInBody("user_id"), commentController.CreateComment)
 			comments.GET("/:id", commentController.GetComment)
 			comments.GET("", commentController.GetComments)
 			comments.GET("/user/:user_id", commentController.GetCommentsByUser)
-			comments.PUT("/:id", commentController.UpdateComment)
-			comments.DELETE("/:id", commentController.DeleteComment)
+			comments.PUT("/:id", auth.RequireUser(), commentController.UpdateComment)
+			comments.DELETE("/:id", auth.RequireUser(), commentController.DeleteComment)
 		}
 	}
 
 	return &Server{
 		router: router,
 		port:   port,
+		httpServer: &http.Server{
+			Addr:              ":" + port,
+			Handler:           router,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
 	}
 }
 
+// callerACL 各调用方可以访问的接口
+// 所有接口都由网关转发外部请求，其他服务不直接调用
+func callerACL() *serviceauth.ACL {
+	return serviceauth.NewACL().
+		Allow("api-gateway", serviceauth.AnyEndpoint)
+}
+
 // Start 启动服务器
+// 调用Shutdown后返回nil
 func (s *Server) Start() error {
-	return s.router.Run(":" + s.port)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Shutdown 关闭服务器
-func (s *Server) Shutdown() {
-	// 这里可以添加优雅关闭逻辑
+// Shutdown 优雅关闭：停止接受新连接，等待处理中的请求完成，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
@@ -4,8 +4,9 @@ import (
 	"blog/comment-service/repository"
 	"blog/shared/kafka"
 	"blog/shared/models"
+	"blog/shared/tracing"
+	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -26,7 +27,7 @@ func NewCommentLogic(commentRepo repository.CommentRepository, producer *kafka.P
 }
 
 // CreateComment 创建评论
-func (cl *CommentLogic) CreateComment(req *models.CommentRequest) (*models.CommentResponse, error) {
+func (cl *CommentLogic) CreateComment(ctx context.Context, req *models.CommentRequest) (*models.CommentResponse, error) {
 	// 创建评论
 	comment := &models.Comment{
 		UserID:   req.UserID,
@@ -47,9 +48,9 @@ func (cl *CommentLogic) CreateComment(req *models.CommentRequest) (*models.Comme
 		Content:   comment.Content,
 		Action:    "create",
 	}
-	err = cl.producer.SendMessage(kafka.TopicCommentCreate, fmt.Sprintf("%d", comment.ID), event)
+	err = cl.producer.SendMessage(ctx, kafka.TopicCommentCreate, fmt.Sprintf("%d", comment.ID), event)
 	if err != nil {
-		log.Printf("Failed to send comment event: %v", err)
+		tracing.Printf(ctx, "Failed to send comment event: %v", err)
 	}
 
 	// 构建响应
@@ -131,7 +132,7 @@ func (cl *CommentLogic) GetCommentsByUser(userID uint) ([]*models.CommentRespons
 }
 
 // UpdateComment 更新评论
-func (cl *CommentLogic) UpdateComment(id uint, content string) (*models.CommentResponse, error) {
+func (cl *CommentLogic) UpdateComment(ctx context.Context, id uint, content string) (*models.CommentResponse, error) {
 	comment, err := cl.commentRepo.GetCommentByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("comment not found")
@@ -150,9 +151,9 @@ func (cl *CommentLogic) UpdateComment(id uint, content string) (*models.CommentR
 		Content:   comment.Content,
 		Action:    "update",
 	}
-	err = cl.producer.SendMessage(kafka.TopicCommentUpdate, fmt.Sprintf("%d", comment.ID), event)
+	err = cl.producer.SendMessage(ctx, kafka.TopicCommentUpdate, fmt.Sprintf("%d", comment.ID), event)
 	if err != nil {
-		log.Printf("Failed to send comment event: %v", err)
+		tracing.Printf(ctx, "Failed to send comment event: %v", err)
 	}
 
 	response := &models.CommentResponse{
@@ -168,7 +169,7 @@ func (cl *CommentLogic) UpdateComment(id uint, content string) (*models.CommentR
 }
 
 // DeleteComment 删除评论
-func (cl *CommentLogic) DeleteComment(id uint) error {
+func (cl *CommentLogic) DeleteComment(ctx context.Context, id uint) error {
 	comment, err := cl.commentRepo.GetCommentByID(id)
 	if err != nil {
 		return fmt.Errorf("comment not found")
@@ -186,17 +187,17 @@ func (cl *CommentLogic) DeleteComment(id uint) error {
 		Content:   comment.Content,
 		Action:    "delete",
 	}
-	err = cl.producer.SendMessage(kafka.TopicCommentDelete, fmt.Sprintf("%d", comment.ID), event)
+	err = cl.producer.SendMessage(ctx, kafka.TopicCommentDelete, fmt.Sprintf("%d", comment.ID), event)
 	if err != nil {
-		log.Printf("Failed to send comment event: %v", err)
+		tracing.Printf(ctx, "Failed to send comment event: %v", err)
 	}
 
 	return nil
 }
 
 // HandleCommentEvent 处理评论事件
-func (cl *CommentLogic) HandleCommentEvent(msg *sarama.ConsumerMessage) error {
-	log.Printf("Received comment event: %s", string(msg.Value))
+func (cl *CommentLogic) HandleCommentEvent(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	tracing.Printf(ctx, "Received comment event: %s", string(msg.Value))
 	// 这里可以处理来自其他服务的评论事件
 	return nil
 }
@@ -0,0 +1,41 @@
+package logic
+
+import (
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"context"
+	"fmt"
+)
+
+// ExportUserData 导出用户的全部评论，包括已删除的评论
+func (cl *CommentLogic) ExportUserData(ctx context.Context, userID uint) (interface{}, error) {
+	comments, err := cl.commentRepo.ListUserComments(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %v", err)
+	}
+	return map[string]interface{}{"comments": comments}, nil
+}
+
+// EraseUserData 注销账户时清空用户所有评论的内容并标记为已删除，保留评论ID使其他用户的回复不受影响
+func (cl *CommentLogic) EraseUserData(ctx context.Context, userID uint) error {
+	affected, err := cl.commentRepo.AnonymizeUserComments(userID)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize comments: %v", err)
+	}
+	if affected == 0 {
+		return nil
+	}
+	tracing.Printf(ctx, "Anonymized %d comments of user %d", affected, userID)
+
+	// 发送删除事件，使网关缓存的评论列表失效
+	event := &models.CommentEvent{
+		UserID: userID,
+		Action: "delete",
+	}
+	err = cl.producer.SendMessage(ctx, kafka.TopicCommentDelete, fmt.Sprintf("user-%d", userID), event)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send comment event: %v", err)
+	}
+	return nil
+}
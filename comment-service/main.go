@@ -5,19 +5,30 @@ import (
 	"blog/comment-service/controller"
 	"blog/comment-service/logic"
 	"blog/comment-service/repository"
+	"blog/shared/database"
+	"blog/shared/health"
 	"blog/shared/kafka"
+	"blog/shared/registry"
+	"blog/shared/serviceauth"
+	"blog/shared/userdata"
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// shutdownTimeout 关闭时等待处理中请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// 初始化配置
 	cfg := config.LoadConfig()
 
 	// 初始化数据库
 	db := repository.InitDB(cfg.Database.DSN)
+	defer database.Close(db)
 
 	// 初始化Kafka生产者
 	producer, err := kafka.NewProducer(cfg.Kafka.Brokers)
@@ -33,6 +44,19 @@ func main() {
 	}
 	defer consumer.Close()
 
+	// 个人数据请求使用消费者组，处理完成才提交位移，停机期间发送的请求重启后继续处理
+	dataGroup, err := kafka.NewConsumerGroup(cfg.Kafka.Brokers, "comment-service")
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer group: %v", err)
+	}
+	defer dataGroup.Close()
+
+	// 导出的个人数据写入数据库，处理结果中只带导出的ID
+	exportStore, err := userdata.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to init user data exports: %v", err)
+	}
+
 	// 初始化仓库
 	commentRepo := repository.NewCommentRepository(db)
 
@@ -42,8 +66,19 @@ func main() {
 	// 初始化控制器
 	commentController := controller.NewCommentController(commentLogic)
 
+	// 就绪检查：MySQL和Kafka生产者
+	healthChecker := health.NewChecker("comment-service")
+	healthChecker.AddCheck("mysql", health.DBCheck(db))
+	healthChecker.AddCheck("kafka", health.KafkaCheck(producer))
+
+	// 校验网关的服务令牌
+	verifier, err := serviceauth.NewVerifier("comment-service", cfg.ServiceAuth)
+	if err != nil {
+		log.Fatalf("Invalid service auth config: %v", err)
+	}
+
 	// 启动HTTP服务器
-	server := controller.NewServer(cfg.Server.Port, commentController)
+	server := controller.NewServer(cfg.Server.Port, commentController, verifier, healthChecker)
 
 	// 启动Kafka消费者
 	go func() {
@@ -53,6 +88,16 @@ func main() {
 		}
 	}()
 
+	// 个人数据导出和账户注销，处理结果发送给用户服务
+	userdata.Subscribe(dataGroup, userdata.Handler("comment-service", producer, exportStore, commentLogic.ExportUserData, commentLogic.EraseUserData))
+
+	// 注册到服务注册中心，供网关发现
+	deregister, err := registry.RegisterLocal(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, "comment-service", cfg.Server.Port)
+	if err != nil {
+		log.Printf("Failed to register service: %v, continuing without service registry", err)
+		deregister = func() {}
+	}
+
 	log.Printf("Comment service starting on port %s", cfg.Server.Port)
 
 	// 在goroutine中启动服务器
@@ -68,5 +113,18 @@ func main() {
 	<-quit
 
 	log.Println("Comment service shutting down...")
-	server.Shutdown()
+
+	// 先摘除实例：就绪检查返回503并从注册中心注销，等待网关刷新实例列表后不再转发新请求
+	healthChecker.SetShuttingDown()
+	deregister()
+	time.Sleep(registry.DeregisterDelay)
+
+	// 等待处理中的请求完成
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Comment service forced to shutdown: %v", err)
+	}
+
+	// 返回后按注册的相反顺序执行defer：停止Kafka消费者、刷新并关闭生产者、关闭数据库连接池
 }
@@ -19,6 +19,8 @@ type CommentRepository interface {
 	GetAllComments() ([]*models.Comment, error)
 	UpdateComment(comment *models.Comment) error
 	DeleteComment(id uint) error
+	ListUserComments(userID uint) ([]*models.Comment, error)
+	AnonymizeUserComments(userID uint) (int64, error)
 }
 
 // commentRepository 评论仓库实现
@@ -110,3 +112,21 @@ func (r *commentRepository) UpdateComment(comment *models.Comment) error {
 func (r *commentRepository) DeleteComment(id uint) error {
 	return r.db.Model(&models.Comment{}).Where("id = ?", id).Update("status", "deleted").Error
 }
+
+// ListUserComments 获取用户的全部评论，包括已删除的评论，用于导出个人数据
+func (r *commentRepository) ListUserComments(userID uint) ([]*models.Comment, error) {
+	var comments []*models.Comment
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// AnonymizeUserComments 清空用户所有评论的内容并标记为已删除，其他用户的回复保留，返回修改的评论数
+func (r *commentRepository) AnonymizeUserComments(userID uint) (int64, error) {
+	result := r.db.Model(&models.Comment{}).
+		Where("user_id = ? AND (content <> '' OR status <> ?)", userID, "deleted").
+		Updates(map[string]interface{}{"content": "", "status": "deleted"})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"blog/shared/serviceauth"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 网关根据JWT注入的可信身份头
+const (
+	HeaderUserID          = "X-User-ID"
+	HeaderUserEmail       = "X-User-Email"
+	HeaderUserRole        = "X-User-Role"
+	HeaderUserVerified    = "X-User-Verified"    // 邮箱是否已验证，值为 true 或 false
+	HeaderUserPermissions = "X-User-Permissions" // 逗号分隔的权限列表
+	HeaderClientIP        = "X-Client-IP"        // 网关按可信代理解析出的客户端地址，公开路由同样注入
+)
+
+// IdentityHeaders 所有身份头，网关转发前会先删除客户端传入的同名头
+var IdentityHeaders = []string{HeaderUserID, HeaderUserEmail, HeaderUserRole, HeaderUserVerified, HeaderUserPermissions, HeaderClientIP}
+
+// gatewayService 网关在服务间认证中的名称
+const gatewayService = "api-gateway"
+
+// contextIdentityKey gin上下文中保存身份的键
+const contextIdentityKey = "auth.identity"
+
+// Identity 经过网关认证的用户身份
+type Identity struct {
+	UserID      uint
+	Email       string
+	Role        string
+	Verified    bool     // 邮箱是否已验证
+	Permissions []string // 角色拥有的权限，由用户服务签发令牌时写入
+}
+
+// IsAdmin 是否为管理员
+func (i *Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+// HasPermission 是否拥有指定权限
+func (i *Identity) HasPermission(permission string) bool {
+	for _, p := range i.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess 是否可以访问指定用户的资源
+func (i *Identity) CanAccess(userID uint) bool {
+	return i.UserID == userID || i.IsAdmin()
+}
+
+// FromContext 获取当前请求的身份，未认证时返回false
+func FromContext(c *gin.Context) (*Identity, bool) {
+	value, ok := c.Get(contextIdentityKey)
+	if !ok {
+		return nil, false
+	}
+	identity, ok := value.(*Identity)
+	return identity, ok
+}
+
+// Middleware 从网关注入的身份头中解析身份，不做访问控制
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.GetHeader(HeaderUserID), 10, 32)
+		if err == nil && userID > 0 {
+			verified, _ := strconv.ParseBool(c.GetHeader(HeaderUserVerified))
+			c.Set(contextIdentityKey, &Identity{
+				UserID:      uint(userID),
+				Email:       c.GetHeader(HeaderUserEmail),
+				Role:        c.GetHeader(HeaderUserRole),
+				Verified:    verified,
+				Permissions: ParsePermissions(c.GetHeader(HeaderUserPermissions)),
+			})
+		}
+		c.Next()
+	}
+}
+
+// ClientIP 返回客户端地址：请求通过了网关的服务令牌校验时取网关写入的 X-Client-IP，否则取连接地址
+// 服务只把网关当作代理，客户端传入的 X-Forwarded-For 不会被采用
+func ClientIP(c *gin.Context) string {
+	if caller, ok := serviceauth.CallerFromContext(c); ok && caller == gatewayService {
+		if ip := c.GetHeader(HeaderClientIP); ip != "" {
+			return ip
+		}
+	}
+	return c.RemoteIP()
+}
+
+// RequireUser 要求请求已认证
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := FromContext(c); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 要求当前用户拥有指定权限
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := FromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		if !identity.HasPermission(permission) {
+			AbortPermissionDenied(c, permission)
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission 当前请求的用户是否拥有指定权限
+func HasPermission(c *gin.Context, permission string) bool {
+	identity, ok := FromContext(c)
+	return ok && identity.HasPermission(permission)
+}
+
+// AbortPermissionDenied 以403终止缺少权限的请求
+func AbortPermissionDenied(c *gin.Context, permission string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission required: " + permission})
+}
+
+// RequireVerifiedWrites 要求写请求（GET、HEAD、OPTIONS以外）的用户已验证邮箱，读请求不受限制
+func RequireVerifiedWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsReadMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		identity, ok := FromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		if !identity.Verified {
+			AbortUnverified(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsReadMethod 是否为不修改数据的请求方法
+func IsReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// AbortUnverified 以403终止未验证邮箱的用户的写请求
+func AbortUnverified(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "email verification required"})
+}
+
+// RequireSelf 要求路径参数中的用户ID与当前用户一致（管理员除外）
+func RequireSelf(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := FromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		userID, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil || !identity.CanAccess(uint(userID)) {
+			AbortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfInBody 要求JSON请求体中指定字段的用户ID与当前用户一致（管理员除外）
+// 读取后会恢复请求体，后续处理函数可以正常绑定
+func RequireSelfInBody(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := FromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+			return
+		}
+
+		var userID uint
+		if raw, ok := fields[field]; ok {
+			if err := json.Unmarshal(raw, &userID); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + field})
+				return
+			}
+		}
+
+		if !identity.CanAccess(userID) {
+			AbortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CanAccess 当前请求的用户是否可以访问指定用户的资源
+func CanAccess(c *gin.Context, userID uint) bool {
+	identity, ok := FromContext(c)
+	return ok && identity.CanAccess(userID)
+}
+
+// AbortForbidden 以403终止请求
+func AbortForbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access to other user's resource denied"})
+}
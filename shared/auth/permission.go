@@ -0,0 +1,29 @@
+package auth
+
+import "strings"
+
+// 用户角色，角色拥有的权限由用户服务维护并写入访问令牌
+const (
+	RoleUser      = "user"      // 普通用户，只能访问自己的资源
+	RoleModerator = "moderator" // 版主，可以管理他人的评论
+	RoleAdmin     = "admin"     // 管理员，拥有全部权限并可以访问任意用户的资源
+)
+
+// 权限，网关路由规则和各服务按权限而不是角色做访问控制
+const (
+	PermProductsWrite    = "products:write"    // 创建、修改和删除商品
+	PermCommentsModerate = "comments:moderate" // 修改和删除任意用户的评论
+	PermUsersManage      = "users:manage"      // 分配角色、查询角色变更记录和解除登录锁定
+	PermGatewayAdmin     = "gateway:admin"     // 网关管理接口
+)
+
+// ParsePermissions 解析逗号分隔的权限列表，忽略空项
+func ParsePermissions(value string) []string {
+	var permissions []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
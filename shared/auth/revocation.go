@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// revokedKeyPrefix 已吊销访问令牌的键前缀，键名为 auth:revoked:<jti>
+const revokedKeyPrefix = "auth:revoked:"
+
+// RevocationList 按jti记录已吊销的访问令牌，用户服务写入，网关认证时检查
+// 记录在令牌过期后自动删除，列表大小不超过有效期内被吊销的令牌数
+type RevocationList struct {
+	client *redis.Client
+}
+
+// NewRevocationList 创建吊销列表
+func NewRevocationList(client *redis.Client) *RevocationList {
+	return &RevocationList{client: client}
+}
+
+// Revoke 吊销令牌直到其过期，已过期的令牌无需记录
+func (r *RevocationList) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return r.client.Set(ctx, revokedKeyPrefix+jti, 1, ttl).Err()
+}
+
+// IsRevoked 判断令牌是否已被吊销
+func (r *RevocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := r.client.Exists(ctx, revokedKeyPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -70,6 +70,12 @@ func (cc *ConfigCenter) SetConfig(service string, config map[string]interface{})
 		return fmt.Errorf("failed to set config: %v", err)
 	}
 
+	// 通知通过WatchConfig监听该服务的实例
+	err = cc.client.Publish(ctx, key, data).Err()
+	if err != nil {
+		log.Printf("Failed to publish config update for service %s: %v", service, err)
+	}
+
 	log.Printf("Config updated for service: %s", service)
 	return nil
 }
@@ -144,12 +150,13 @@ func (cc *ConfigCenter) WatchConfig(service string, callback func(*ConfigData))
 	pubsub := cc.client.Subscribe(ctx, key)
 	defer pubsub.Close()
 
-	// 先获取当前配置
+	// 先获取当前配置，尚未配置时继续等待SetConfig发布
 	config, err := cc.GetConfig(service)
 	if err != nil {
-		return fmt.Errorf("failed to get initial config: %v", err)
+		log.Printf("Failed to get initial config for service %s: %v", service, err)
+	} else {
+		callback(config)
 	}
-	callback(config)
 
 	// 监听变化
 	for {
@@ -175,6 +182,7 @@ func (cc *ConfigCenter) Close() error {
 }
 
 // InitializeDefaultConfigs 初始化默认配置
+// 服务间认证密钥不写入默认配置，各服务从环境变量 SERVICE_AUTH_KEY_<服务名> 读取
 func (cc *ConfigCenter) InitializeDefaultConfigs() error {
 	// API网关配置
 	apiGatewayConfig := map[string]interface{}{
@@ -200,7 +208,147 @@ func (cc *ConfigCenter) InitializeDefaultConfigs() error {
 			},
 		},
 		"jwt": map[string]interface{}{
-			"secret": "sta_go_jwt_secret",
+			"jwks_service":     "user-service",
+			"refresh_interval": 300,
+		},
+		"registry": map[string]interface{}{
+			"addr":     "47.118.19.28:6379",
+			"password": "sta_go",
+			"db":       0,
+		},
+		"load_balancer": map[string]interface{}{
+			"strategy":         "round_robin",
+			"refresh_interval": 5,
+		},
+		"redis": map[string]interface{}{
+			"addr":     "47.118.19.28:6379",
+			"password": "sta_go",
+			"db":       0,
+		},
+		"rate_limit": map[string]interface{}{
+			"enabled": true,
+			"default": map[string]interface{}{
+				"per_ip":   map[string]interface{}{"limit": 300, "window": 60},
+				"per_user": map[string]interface{}{"limit": 120, "window": 60},
+			},
+			"routes": []map[string]interface{}{
+				{
+					"path":    "/api/v1/users/login",
+					"methods": []string{"POST"},
+					"per_ip":  map[string]interface{}{"limit": 10, "window": 60},
+				},
+				{
+					"path":    "/api/v1/users/register",
+					"methods": []string{"POST"},
+					"per_ip":  map[string]interface{}{"limit": 5, "window": 60},
+				},
+				{
+					"path":    "/api/v1/users/refresh",
+					"methods": []string{"POST"},
+					"per_ip":  map[string]interface{}{"limit": 30, "window": 60},
+				},
+				{
+					"path":    "/api/v1/users/forgot-password",
+					"methods": []string{"POST"},
+					"per_ip":  map[string]interface{}{"limit": 5, "window": 60},
+				},
+				{
+					"path":    "/api/v1/users/reset-password",
+					"methods": []string{"POST"},
+					"per_ip":  map[string]interface{}{"limit": 10, "window": 60},
+				},
+				{
+					"path":     "/api/v1/wallets/transfer",
+					"methods":  []string{"POST"},
+					"per_ip":   map[string]interface{}{"limit": 30, "window": 60},
+					"per_user": map[string]interface{}{"limit": 10, "window": 60},
+				},
+				{
+					"path":     "/api/v1/users/me/export",
+					"methods":  []string{"POST"},
+					"per_user": map[string]interface{}{"limit": 3, "window": 3600},
+				},
+			},
+		},
+		"circuit_breaker": map[string]interface{}{
+			"failure_threshold":  5,
+			"open_timeout":       30,
+			"half_open_requests": 1,
+		},
+		"retry": map[string]interface{}{
+			"max_retries":  2,
+			"base_backoff": 100,
+			"max_backoff":  1000,
+		},
+		"routes": []map[string]interface{}{
+			{"prefix": "/api/v1/users", "service": "user-service", "auth_required": true, "timeout": 30},
+			{"prefix": "/api/v1/users/register", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/login", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/refresh", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/logout", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/forgot-password", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/reset-password", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/verify-email", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/resend-code", "methods": []string{"POST"}, "service": "user-service", "auth_required": false, "timeout": 30},
+			{"prefix": "/api/v1/users/admin", "service": "user-service", "auth_required": true, "permission": "users:manage", "timeout": 30},
+			{
+				"prefix": "/.well-known/jwks.json", "methods": []string{"GET"}, "service": "user-service", "auth_required": false, "timeout": 10,
+				"cache": map[string]interface{}{
+					"ttl":           60,
+					"vary_headers":  []string{},
+					"invalidate_on": []string{},
+				},
+			},
+			{"prefix": "/api/v1/wallets", "service": "wallet-service", "auth_required": true, "verified_required": true, "timeout": 30},
+			{
+				"prefix": "/api/v1/comments", "service": "comment-service", "auth_required": true, "verified_required": true, "timeout": 30,
+				"cache": map[string]interface{}{
+					"ttl":           30,
+					"vary_headers":  []string{},
+					"invalidate_on": []string{"comment.create", "comment.update", "comment.delete"},
+				},
+			},
+			{
+				"prefix": "/api/v1/products", "service": "shop-service", "auth_required": true, "verified_required": true, "timeout": 30,
+				"cache": map[string]interface{}{
+					"ttl":           60,
+					"vary_headers":  []string{},
+					"invalidate_on": []string{"product.create", "product.update", "product.delete"},
+				},
+			},
+			{
+				"prefix": "/api/v1/products", "methods": []string{"POST", "PUT", "PATCH", "DELETE"}, "service": "shop-service",
+				"auth_required": true, "verified_required": true, "permission": "products:write", "timeout": 30,
+			},
+			{"prefix": "/api/v1/orders", "service": "shop-service", "auth_required": true, "verified_required": true, "timeout": 30},
+			{"prefix": "/api/v1/shop", "service": "shop-service", "rewrite": "/api/v1", "auth_required": true, "verified_required": true, "timeout": 30},
+			{
+				"prefix": "/api/v1/shop/products", "methods": []string{"POST", "PUT", "PATCH", "DELETE"}, "service": "shop-service", "rewrite": "/api/v1/products",
+				"auth_required": true, "verified_required": true, "permission": "products:write", "timeout": 30,
+			},
+		},
+		"kafka": map[string]interface{}{
+			"brokers": []string{"localhost:9092"},
+		},
+		"health_check": map[string]interface{}{
+			"timeout": 2000,
+		},
+		"aggregation": map[string]interface{}{
+			"timeout":      3000,
+			"recent_limit": 5,
+		},
+		"cors": map[string]interface{}{
+			"allowed_origins":   []string{"http://localhost:3000", "http://localhost:5173", "http://localhost:8080"},
+			"allow_credentials": true,
+			"expose_headers":    []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
+			"max_age":           600,
+			"default": map[string]interface{}{
+				"allow_methods": []string{"GET", "POST", "PUT", "DELETE"},
+				"allow_headers": []string{"Content-Type", "Authorization", "X-Request-ID", "X-Canary", "traceparent", "tracestate"},
+			},
+			"routes": []map[string]interface{}{
+				{"path": "/api/v1/docs", "allow_methods": []string{"GET"}, "allow_headers": []string{"Accept"}},
+			},
 		},
 	}
 
@@ -230,7 +378,37 @@ func (cc *ConfigCenter) InitializeDefaultConfigs() error {
 			"is_ssl":   true,
 		},
 		"jwt": map[string]interface{}{
-			"secret": "sta_go_jwt_secret",
+			"access_ttl":            900,
+			"refresh_ttl":           2592000,
+			"key_rotation_interval": 2592000,
+		},
+		"password_reset": map[string]interface{}{
+			"url": "http://localhost:3000/reset-password",
+			"ttl": 1800,
+		},
+		"login_guard": map[string]interface{}{
+			"window":               900,
+			"free_attempts":        3,
+			"base_delay":           1,
+			"max_delay":            30,
+			"email_lock_threshold": 10,
+			"ip_lock_threshold":    50,
+			"lock_duration":        900,
+		},
+		"verification": map[string]interface{}{
+			"code_ttl":        600,
+			"max_attempts":    5,
+			"resend_cooldown": 60,
+			"daily_limit":     10,
+		},
+		"roles": map[string]interface{}{
+			"bootstrap_admins": []string{},
+		},
+		"data_requests": map[string]interface{}{
+			"services":       []string{"wallet-service", "comment-service", "shop-service"},
+			"retry_interval": 300,
+			"max_attempts":   5,
+			"export_ttl":     604800,
 		},
 	}
 
@@ -287,6 +465,9 @@ func (cc *ConfigCenter) InitializeDefaultConfigs() error {
 			"host": "wallet-service",
 			"port": "8002",
 		},
+		"kafka": map[string]interface{}{
+			"brokers": []string{"localhost:9092"},
+		},
 	}
 
 	// 设置所有配置
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// Close 关闭数据库连接池，服务退出时调用，失败只记录日志
+func Close(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("Failed to get database handle: %v", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+}
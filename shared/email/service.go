@@ -3,8 +3,10 @@ package email
 import (
 	"crypto/tls"
 	"fmt"
+	"html"
 	"log"
 	"net/smtp"
+	"time"
 
 	"github.com/jordan-wright/email"
 )
@@ -31,19 +33,19 @@ func NewEmailService(config *EmailConfig) *EmailService {
 }
 
 // SendVerificationEmail 发送验证邮件
-func (es *EmailService) SendVerificationEmail(to, verificationCode string) error {
+func (es *EmailService) SendVerificationEmail(to, verificationCode string, expiresInMinutes int) error {
 	subject := "邮箱验证码"
 	body := fmt.Sprintf(`
 		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
 			<h2 style="color: #333;">邮箱验证</h2>
 			<p>您好！</p>
 			<p>您的验证码是：<strong style="color: #007bff; font-size: 24px;">%s</strong></p>
-			<p>验证码有效期为10分钟，请及时使用。</p>
+			<p>验证码有效期为%d分钟，请及时使用，输错次数过多后验证码失效。</p>
 			<p>如果这不是您的操作，请忽略此邮件。</p>
 			<hr style="margin: 20px 0; border: none; border-top: 1px solid #eee;">
 			<p style="color: #666; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
 		</div>
-	`, verificationCode)
+	`, verificationCode, expiresInMinutes)
 
 	return es.sendEmail(to, subject, body)
 }
@@ -70,6 +72,62 @@ func (es *EmailService) SendWelcomeEmail(to, username string) error {
 	return es.sendEmail(to, subject, body)
 }
 
+// SendPasswordResetEmail 发送密码重置邮件，resetLink 为带重置令牌的页面地址
+func (es *EmailService) SendPasswordResetEmail(to, resetLink string, expiresInMinutes int) error {
+	subject := "重置密码"
+	link := html.EscapeString(resetLink)
+	body := fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+			<h2 style="color: #333;">重置密码</h2>
+			<p>您好！</p>
+			<p>我们收到了重置您账户密码的请求，请点击下面的链接设置新密码：</p>
+			<p><a href="%s" style="color: #007bff;">%s</a></p>
+			<p>链接有效期为%d分钟，且只能使用一次。重置后所有设备上的登录都将失效。</p>
+			<p>如果这不是您的操作，请忽略此邮件，您的密码不会被修改。</p>
+			<hr style="margin: 20px 0; border: none; border-top: 1px solid #eee;">
+			<p style="color: #666; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
+		</div>
+	`, link, link, expiresInMinutes)
+
+	return es.sendEmail(to, subject, body)
+}
+
+// SendAccountLockedEmail 发送账户锁定通知邮件，lockedUntil 为自动解锁时间
+func (es *EmailService) SendAccountLockedEmail(to string, failures int, lockedUntil time.Time) error {
+	subject := "账户已临时锁定"
+	body := fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+			<h2 style="color: #333;">账户已临时锁定</h2>
+			<p>您好！</p>
+			<p>您的账户连续%d次登录失败，为保护账户安全，已暂时禁止登录。</p>
+			<p><strong>自动解锁时间：</strong>%s</p>
+			<p>如果这不是您的操作，说明有人正在尝试登录您的账户，建议立即通过“忘记密码”重置密码，重置后账户会立即解锁。</p>
+			<hr style="margin: 20px 0; border: none; border-top: 1px solid #eee;">
+			<p style="color: #666; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
+		</div>
+	`, failures, lockedUntil.Format("2006-01-02 15:04:05 MST"))
+
+	return es.sendEmail(to, subject, body)
+}
+
+// SendEmailChangedEmail 新邮箱通过验证、登录邮箱替换后通知原邮箱，newEmail 为新的登录邮箱
+func (es *EmailService) SendEmailChangedEmail(to, newEmail string) error {
+	subject := "登录邮箱已修改"
+	body := fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+			<h2 style="color: #333;">登录邮箱已修改</h2>
+			<p>您好！</p>
+			<p>您账户的登录邮箱已修改为：<strong>%s</strong>，之后将不再向本邮箱发送账户通知。</p>
+			<p>所有设备上的登录已失效，请使用新邮箱重新登录。</p>
+			<p>如果这不是您的操作，说明您的账户可能已被他人登录，请立即联系管理员。</p>
+			<hr style="margin: 20px 0; border: none; border-top: 1px solid #eee;">
+			<p style="color: #666; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
+		</div>
+	`, html.EscapeString(newEmail))
+
+	return es.sendEmail(to, subject, body)
+}
+
 // sendEmail 发送邮件
 func (es *EmailService) sendEmail(to, subject, body string) error {
 	e := email.NewEmail()
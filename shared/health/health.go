@@ -0,0 +1,178 @@
+package health
+
+import (
+	"blog/shared/kafka"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// DefaultTimeout 单个依赖检查的默认超时时间
+const DefaultTimeout = 2 * time.Second
+
+// Check 依赖检查函数，返回nil表示依赖可用
+type Check func(ctx context.Context) error
+
+// Result 单个依赖的检查结果
+type Result struct {
+	Status    string `json:"status"` // up, down
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Checker 服务的存活和就绪检查
+//
+// 存活检查只表示进程能够处理请求；就绪检查并发检查所有依赖，
+// 任一依赖不可用时返回503，负载均衡和注册中心据此摘除实例
+type Checker struct {
+	service      string
+	timeout      time.Duration
+	names        []string
+	checks       map[string]Check
+	shuttingDown int32
+}
+
+// NewChecker 创建健康检查
+func NewChecker(service string) *Checker {
+	return &Checker{
+		service: service,
+		timeout: DefaultTimeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// AddCheck 添加依赖检查
+func (h *Checker) AddCheck(name string, check Check) {
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// SetShuttingDown 标记服务正在关闭，之后就绪检查始终返回503
+func (h *Checker) SetShuttingDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// RegisterRoutes 注册健康检查路由
+// /health 保留为存活检查，兼容已有的探针配置
+func (h *Checker) RegisterRoutes(router gin.IRoutes) {
+	router.GET("/health", h.Live)
+	router.GET("/health/live", h.Live)
+	router.GET("/health/ready", h.Ready)
+}
+
+// Live 存活检查
+func (h *Checker) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "alive",
+		"service": h.service,
+	})
+}
+
+// Ready 就绪检查
+func (h *Checker) Ready(c *gin.Context) {
+	if atomic.LoadInt32(&h.shuttingDown) == 1 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "shutting_down",
+			"service": h.service,
+		})
+		return
+	}
+
+	ready, results := h.Run(c.Request.Context())
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":  status,
+		"service": h.service,
+		"checks":  results,
+	})
+}
+
+// Run 并发执行所有依赖检查，每个检查单独计时和超时
+func (h *Checker) Run(ctx context.Context) (bool, map[string]Result) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ready := true
+	results := make(map[string]Result, len(h.names))
+
+	for _, name := range h.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := runCheck(ctx, check, h.timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result.Status != "up" {
+				ready = false
+			}
+		}(name, h.checks[name])
+	}
+	wg.Wait()
+
+	return ready, results
+}
+
+// runCheck 在超时时间内执行检查，检查函数不响应context时也能按时返回
+func runCheck(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %v", timeout)
+	}
+
+	result := Result{Status: "up", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// DBCheck MySQL连接检查
+func DBCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// RedisCheck Redis连接检查
+func RedisCheck(client *redis.Client) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// KafkaCheck Kafka生产者检查
+func KafkaCheck(producer *kafka.Producer) Check {
+	return func(ctx context.Context) error {
+		return producer.Ping()
+	}
+}
@@ -0,0 +1,157 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrKeyNotFound 令牌的kid不在公钥集中，可能是伪造的令牌或签名密钥已撤下
+	ErrKeyNotFound = errors.New("signing key not found")
+	// ErrKeysUnavailable 还没有取到公钥集，无法验证任何令牌
+	ErrKeysUnavailable = errors.New("signing keys unavailable")
+)
+
+// 遇到未知kid时重新获取公钥集的最短间隔和超时，避免伪造的kid放大对用户服务的请求
+const (
+	minRefetchInterval = 10 * time.Second
+	fetchTimeout       = 5 * time.Second
+)
+
+// FetchFunc 获取最新的公钥集
+type FetchFunc func(ctx context.Context) (*Set, error)
+
+// Cache 验证方缓存的公钥集
+// 定期整体替换，已从公钥集撤下的密钥随之失效；
+// 令牌使用未知kid时立即重新获取一次，新密钥启用后不必等到下次刷新
+type Cache struct {
+	fetch FetchFunc
+
+	mu        sync.RWMutex
+	keys      map[string]ed25519.PublicKey
+	fetchedAt time.Time // 最近一次获取的时间，无论成功与否
+
+	refetchMu sync.Mutex
+}
+
+// NewCache 创建公钥集缓存并立即获取一次，获取失败时在第一次验签时重试
+func NewCache(fetch FetchFunc) *Cache {
+	c := &Cache{fetch: fetch, keys: make(map[string]ed25519.PublicKey)}
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	if err := c.Refresh(ctx); err != nil {
+		log.Printf("Failed to fetch JWKS: %v", err)
+	}
+	return c
+}
+
+// Start 按间隔刷新公钥集，直到stop被关闭
+func (c *Cache) Start(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+				if err := c.Refresh(ctx); err != nil {
+					log.Printf("Failed to refresh JWKS: %v", err)
+				}
+				cancel()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Refresh 重新获取公钥集，失败时保留当前公钥；无法解析的密钥跳过
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	set, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Alg != "" && key.Alg != Algorithm {
+			continue
+		}
+		publicKey, err := key.PublicKey()
+		if err != nil {
+			log.Printf("Skipping JWK %s: %v", key.Kid, err)
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
+
+// Keyfunc 按令牌头的kid查找验签公钥，供jwt.Parse使用
+// 解析时还应通过 jwt.WithValidMethods 限定算法为 Algorithm
+func (c *Cache) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrKeyNotFound
+	}
+
+	if key, ok := c.lookup(kid); ok {
+		return key, nil
+	}
+	c.refetch()
+	if key, ok := c.lookup(kid); ok {
+		return key, nil
+	}
+	if c.empty() {
+		return nil, ErrKeysUnavailable
+	}
+	return nil, ErrKeyNotFound
+}
+
+// lookup 查找缓存中的公钥
+func (c *Cache) lookup(kid string) (ed25519.PublicKey, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+// empty 缓存中没有任何公钥
+func (c *Cache) empty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.keys) == 0
+}
+
+// refetch 距上次获取超过最短间隔时重新获取公钥集，并发的请求只获取一次
+func (c *Cache) refetch() {
+	c.refetchMu.Lock()
+	defer c.refetchMu.Unlock()
+
+	c.mu.RLock()
+	recent := time.Since(c.fetchedAt) < minRefetchInterval
+	c.mu.RUnlock()
+	if recent {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	if err := c.Refresh(ctx); err != nil {
+		log.Printf("Failed to refetch JWKS: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package jwks 访问令牌验签公钥集（JSON Web Key Set）
+//
+// 用户服务用Ed25519私钥签发访问令牌，令牌头的kid标识所用密钥，
+// 对应的公钥以JWKS格式公布在 Path，网关等验证方据此验签，不再共享密钥
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Path 用户服务公布公钥集的路径
+const Path = "/.well-known/jwks.json"
+
+// Algorithm 访问令牌的签名算法
+const Algorithm = "EdDSA"
+
+// maxSetSize 公钥集响应的最大长度
+const maxSetSize = 1 << 20
+
+// ErrUnsupportedKey 不是Ed25519签名公钥
+var ErrUnsupportedKey = errors.New("unsupported JWK")
+
+// Key 单个公钥（RFC 8037 OKP格式）
+type Key struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// Set 公钥集
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewKey 把Ed25519公钥转换为JWK
+func NewKey(kid string, publicKey ed25519.PublicKey) Key {
+	return Key{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		Kid: kid,
+		Use: "sig",
+		Alg: Algorithm,
+	}
+}
+
+// PublicKey 解析JWK中的Ed25519公钥
+func (k Key) PublicKey() (ed25519.PublicKey, error) {
+	if k.Kty != "OKP" || k.Crv != "Ed25519" || (k.Use != "" && k.Use != "sig") {
+		return nil, fmt.Errorf("%w: kty=%s crv=%s use=%s", ErrUnsupportedKey, k.Kty, k.Crv, k.Use)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: invalid x of key %s", ErrUnsupportedKey, k.Kid)
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// Fetch 从url获取公钥集
+func Fetch(ctx context.Context, client *http.Client, url string) (*Set, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %d", url, resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSetSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode key set: %v", err)
+	}
+	return &set, nil
+}
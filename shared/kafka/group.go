@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"blog/shared/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// 消费者组处理消息失败时的重试参数
+const (
+	groupHandleAttempts = 3
+	groupRetryBackoff   = time.Second
+)
+
+// ConsumerGroup Kafka消费者组，同一组的多个副本分摊分区，每条消息只由一个副本处理
+// 消息处理完成后才提交位移，服务重启或停机期间发送的消息从已提交的位移继续消费
+type ConsumerGroup struct {
+	group  sarama.ConsumerGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewConsumerGroup 创建消费者组，组第一次消费某个主题时从最新的消息开始
+func NewConsumerGroup(brokers []string, groupID string) (*ConsumerGroup, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+
+	group, err := sarama.NewConsumerGroup(brokers, groupID, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer group: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ConsumerGroup{group: group, ctx: ctx, cancel: cancel}, nil
+}
+
+// Consume 在后台消费主题直到Close，每个消费者组只调用一次
+// 处理失败的消息按间隔重试，仍然失败时记录日志并提交位移，由发送方按各自的机制重新发送
+func (g *ConsumerGroup) Consume(topics []string, handler Handler) {
+	g.wg.Add(2)
+	go func() {
+		defer g.wg.Done()
+		for err := range g.group.Errors() {
+			log.Printf("Consumer group error: %v", err)
+		}
+	}()
+	go func() {
+		defer g.wg.Done()
+		// 分区再均衡时Consume返回，需要重新加入消费者组
+		for g.ctx.Err() == nil {
+			err := g.group.Consume(g.ctx, topics, &groupHandler{handler: handler})
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			if err != nil {
+				log.Printf("Consumer group error on %v: %v", topics, err)
+				select {
+				case <-time.After(groupRetryBackoff):
+				case <-g.ctx.Done():
+				}
+			}
+		}
+	}()
+}
+
+// Close 停止消费，等待正在处理的消息完成并提交位移后关闭消费者组
+func (g *ConsumerGroup) Close() error {
+	g.cancel()
+	err := g.group.Close()
+	g.wg.Wait()
+	return err
+}
+
+// groupHandler 按分区依次处理消息
+type groupHandler struct {
+	handler Handler
+}
+
+// Setup 分配到分区时调用
+func (h *groupHandler) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+// Cleanup 分区被收回前调用
+func (h *groupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim 处理一个分区的消息，处理结束后才标记位移
+// 重试期间分区被收回时不标记，消息由接手的副本重新处理
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !h.handle(session.Context(), msg) {
+				return nil
+			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handle 处理消息，失败时按间隔重试；会话结束时返回false
+func (h *groupHandler) handle(sessionCtx context.Context, msg *sarama.ConsumerMessage) bool {
+	ctx := tracing.NewContext(sessionCtx, tracing.Extract(consumerHeaders{msg}))
+	for attempt := 1; ; attempt++ {
+		err := h.handler(ctx, msg)
+		if err == nil {
+			return true
+		}
+		if attempt >= groupHandleAttempts {
+			tracing.Printf(ctx, "Error handling message from %s after %d attempts, skipping: %v", msg.Topic, attempt, err)
+			return true
+		}
+		tracing.Printf(ctx, "Error handling message from %s, retrying: %v", msg.Topic, err)
+
+		select {
+		case <-time.After(groupRetryBackoff * time.Duration(attempt)):
+		case <-sessionCtx.Done():
+			return false
+		}
+	}
+}
@@ -1,22 +1,34 @@
 package kafka
 
 import (
+	"blog/shared/tracing"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
 )
 
+// ErrNoProducer Kafka不可用、服务没有创建生产者
+var ErrNoProducer = errors.New("kafka producer not available")
+
 // Producer Kafka生产者
 type Producer struct {
+	client   sarama.Client
 	producer sarama.SyncProducer
 }
 
 // Consumer Kafka消费者
 type Consumer struct {
 	consumer sarama.Consumer
+
+	mu         sync.Mutex
+	partitions []sarama.PartitionConsumer
+	wg         sync.WaitGroup
 }
 
 // Message Kafka消息结构
@@ -27,6 +39,9 @@ type Message struct {
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// Handler 消息处理函数，ctx中带有从消息头恢复的链路信息
+type Handler func(ctx context.Context, msg *sarama.ConsumerMessage) error
+
 // NewProducer 创建Kafka生产者
 func NewProducer(brokers []string) (*Producer, error) {
 	config := sarama.NewConfig()
@@ -34,16 +49,26 @@ func NewProducer(brokers []string) (*Producer, error) {
 	config.Producer.Retry.Max = 5
 	config.Producer.Return.Successes = true
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %v", err)
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create producer: %v", err)
 	}
 
-	return &Producer{producer: producer}, nil
+	return &Producer{client: client, producer: producer}, nil
 }
 
-// SendMessage 发送消息
-func (p *Producer) SendMessage(topic, key string, value interface{}) error {
+// SendMessage 发送消息，ctx中的请求ID和traceparent写入消息头
+// 生产者为nil（Kafka不可用时服务继续运行）时返回ErrNoProducer
+func (p *Producer) SendMessage(ctx context.Context, topic, key string, value interface{}) error {
+	if p == nil {
+		return ErrNoProducer
+	}
 	jsonData, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %v", err)
@@ -54,19 +79,36 @@ func (p *Producer) SendMessage(topic, key string, value interface{}) error {
 		Key:   sarama.StringEncoder(key),
 		Value: sarama.StringEncoder(jsonData),
 	}
+	tracing.Inject(ctx, producerHeaders{msg})
 
 	partition, offset, err := p.producer.SendMessage(msg)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
 
-	log.Printf("Message sent to topic %s, partition %d, offset %d", topic, partition, offset)
+	tracing.Printf(ctx, "Message sent to topic %s, partition %d, offset %d", topic, partition, offset)
 	return nil
 }
 
-// Close 关闭生产者
+// Ping 刷新集群元数据，检查生产者能否连接到Kafka
+func (p *Producer) Ping() error {
+	if p.client.Closed() {
+		return fmt.Errorf("producer is closed")
+	}
+	if err := p.client.RefreshMetadata(); err != nil {
+		return fmt.Errorf("failed to refresh metadata: %v", err)
+	}
+	return nil
+}
+
+// Close 关闭生产者，等待已提交的消息发送完成
 func (p *Producer) Close() error {
-	return p.producer.Close()
+	// 基于client创建的生产者不会关闭client，需要单独关闭
+	if err := p.producer.Close(); err != nil {
+		p.client.Close()
+		return err
+	}
+	return p.client.Close()
 }
 
 // NewConsumer 创建Kafka消费者
@@ -83,7 +125,8 @@ func NewConsumer(brokers []string) (*Consumer, error) {
 }
 
 // ConsumeMessages 消费消息
-func (c *Consumer) ConsumeMessages(topic string, handler func(*sarama.ConsumerMessage) error) error {
+// 处理每条消息前从消息头恢复链路信息，没有链路信息的消息生成新的请求ID
+func (c *Consumer) ConsumeMessages(topic string, handler Handler) error {
 	partitionList, err := c.consumer.Partitions(topic)
 	if err != nil {
 		return fmt.Errorf("failed to get partitions: %v", err)
@@ -95,15 +138,31 @@ func (c *Consumer) ConsumeMessages(topic string, handler func(*sarama.ConsumerMe
 			return fmt.Errorf("failed to consume partition: %v", err)
 		}
 
+		c.mu.Lock()
+		c.partitions = append(c.partitions, pc)
+		c.mu.Unlock()
+
+		c.wg.Add(1)
 		go func(pc sarama.PartitionConsumer) {
-			defer pc.AsyncClose()
-			for {
+			defer c.wg.Done()
+			// 分区消费者关闭后两个通道都会关闭，处理完已取出的消息再退出
+			messages, errors := pc.Messages(), pc.Errors()
+			for messages != nil || errors != nil {
 				select {
-				case msg := <-pc.Messages():
-					if err := handler(msg); err != nil {
-						log.Printf("Error handling message: %v", err)
+				case msg, ok := <-messages:
+					if !ok {
+						messages = nil
+						continue
+					}
+					ctx := tracing.NewContext(context.Background(), tracing.Extract(consumerHeaders{msg}))
+					if err := handler(ctx, msg); err != nil {
+						tracing.Printf(ctx, "Error handling message from %s: %v", msg.Topic, err)
+					}
+				case err, ok := <-errors:
+					if !ok {
+						errors = nil
+						continue
 					}
-				case err := <-pc.Errors():
 					log.Printf("Consumer error: %v", err)
 				}
 			}
@@ -113,18 +172,82 @@ func (c *Consumer) ConsumeMessages(topic string, handler func(*sarama.ConsumerMe
 	return nil
 }
 
-// Close 关闭消费者
+// Close 关闭所有分区消费者，等待正在处理的消息完成后关闭消费者
 func (c *Consumer) Close() error {
+	c.mu.Lock()
+	partitions := c.partitions
+	c.partitions = nil
+	c.mu.Unlock()
+
+	for _, pc := range partitions {
+		pc.AsyncClose()
+	}
+	c.wg.Wait()
+
 	return c.consumer.Close()
 }
 
+// producerHeaders 以待发送消息的消息头作为链路信息载体
+type producerHeaders struct {
+	msg *sarama.ProducerMessage
+}
+
+// Get 读取消息头
+func (h producerHeaders) Get(key string) string {
+	for _, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set 设置消息头，已存在时覆盖
+func (h producerHeaders) Set(key, value string) {
+	for i, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// consumerHeaders 以收到消息的消息头作为链路信息载体，只读
+type consumerHeaders struct {
+	msg *sarama.ConsumerMessage
+}
+
+// Get 读取消息头
+func (h consumerHeaders) Get(key string) string {
+	for _, header := range h.msg.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set 收到的消息不允许修改
+func (h consumerHeaders) Set(key, value string) {}
+
 // Topics 定义Kafka主题
 const (
-	TopicUserRegister    = "user.register"
-	TopicUserLogin       = "user.login"
-	TopicUserEmailVerify = "user.email.verify"
-	TopicWalletPayment   = "wallet.payment"
-	TopicCommentCreate   = "comment.create"
-	TopicCommentUpdate   = "comment.update"
-	TopicCommentDelete   = "comment.delete"
+	TopicUserRegister      = "user.register"
+	TopicUserLogin         = "user.login"
+	TopicUserEmailVerify   = "user.email.verify"
+	TopicUserPasswordReset = "user.password.reset"
+	TopicUserLockout       = "user.lockout"
+	TopicUserRoleChange    = "user.role.change"
+	TopicUserUpdated       = "user.updated"
+	TopicUserDeleted       = "user.deleted"
+	TopicUserExportRequest = "user.export.requested"
+	TopicUserDataProgress  = "user.data.progress"
+	TopicWalletPayment     = "wallet.payment"
+	TopicCommentCreate     = "comment.create"
+	TopicCommentUpdate     = "comment.update"
+	TopicCommentDelete     = "comment.delete"
+	TopicProductCreate     = "product.create"
+	TopicProductUpdate     = "product.update"
+	TopicProductDelete     = "product.delete"
 )
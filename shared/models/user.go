@@ -6,35 +6,176 @@ import (
 
 // User 用户模型
 type User struct {
-	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	Username   string    `json:"username" gorm:"size:50;uniqueIndex;not null"`
-	Email      string    `json:"email" gorm:"size:100;uniqueIndex;not null"`
-	Password   string    `json:"-" gorm:"size:255;not null"`
-	IsVerified bool      `json:"is_verified" gorm:"default:false"`
-	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID           uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Username     string    `json:"username" gorm:"size:50;uniqueIndex;not null"`
+	Email        string    `json:"email" gorm:"size:100;uniqueIndex;not null"`
+	Password     string    `json:"-" gorm:"size:255;not null"`
+	IsVerified   bool      `json:"is_verified" gorm:"default:false"`
+	PendingEmail string    `json:"pending_email,omitempty" gorm:"size:100;index"`     // 待验证的新邮箱，验证通过后替换 Email
+	Role         string    `json:"role" gorm:"size:20;not null;default:'user';index"` // user, moderator, admin
+	DisplayName  string    `json:"display_name" gorm:"size:50"`
+	AvatarURL    string    `json:"avatar_url" gorm:"size:255"`
+	Bio          string    `json:"bio" gorm:"size:500"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 // UserRegisterRequest 用户注册请求
 type UserRegisterRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=20"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Username string `json:"username" binding:"required,min=3,max=20"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
 }
 
 // UserLoginRequest 用户登录请求
 type UserLoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshTokenRequest 刷新令牌请求，登出请求使用相同的格式
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse 访问令牌和刷新令牌
+type TokenResponse struct {
+	Token        string `json:"token"`         // 访问令牌
+	RefreshToken string `json:"refresh_token"` // 刷新令牌，每次刷新后更换
+	ExpiresIn    int    `json:"expires_in"`    // 访问令牌有效期（秒）
+}
+
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求，token 为重置邮件链接中的令牌
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// UpdateProfileRequest 修改个人资料请求，只修改请求中出现的字段，空字符串清空昵称、头像和简介
+type UpdateProfileRequest struct {
+	Username    *string `json:"username" binding:"omitempty,min=3,max=20"`
+	DisplayName *string `json:"display_name" binding:"omitempty,max=50"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,max=255"` // http或https地址
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangeEmailRequest 修改邮箱请求，需要当前密码
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
 }
 
 // UserResponse 用户响应
 type UserResponse struct {
-	ID         uint   `json:"id"`
-	Username   string `json:"username"`
-	Email      string `json:"email"`
-	IsVerified bool   `json:"is_verified"`
-	CreatedAt  string `json:"created_at"`
+	ID           uint   `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	IsVerified   bool   `json:"is_verified"`
+	PendingEmail string `json:"pending_email,omitempty"`
+	Role         string `json:"role"`
+	DisplayName  string `json:"display_name"`
+	AvatarURL    string `json:"avatar_url"`
+	Bio          string `json:"bio"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// PublicUserResponse 其他用户可见的公开资料，不含邮箱和角色
+type PublicUserResponse struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// AssignRoleRequest 管理员分配角色请求
+type AssignRoleRequest struct {
+	Role   string `json:"role" binding:"required,oneof=user moderator admin"`
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// RoleResponse 角色及其拥有的权限
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleAuditLog 角色变更记录，每次分配角色都会写入一条，不修改也不删除
+type RoleAuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	OldRole    string    `json:"old_role" gorm:"size:20;not null"`
+	NewRole    string    `json:"new_role" gorm:"size:20;not null"`
+	OperatorID uint      `json:"operator_id" gorm:"not null;index"` // 执行变更的管理员，0表示按配置初始化
+	Reason     string    `json:"reason" gorm:"size:255"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// DeleteAccountRequest 注销账户请求，需要当前密码
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// 个人数据请求类型
+const (
+	DataRequestExport   = "export"
+	DataRequestDeletion = "deletion"
+)
+
+// 个人数据请求及各服务处理进度的状态
+const (
+	DataRequestPending   = "pending"
+	DataRequestCompleted = "completed"
+	DataRequestFailed    = "failed"
+)
+
+// DataRequest 个人数据请求（导出或注销），由用户服务通过Kafka分发给各服务，按服务记录处理进度
+type DataRequest struct {
+	ID          uint              `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint              `json:"user_id" gorm:"not null;index"`
+	Type        string            `json:"type" gorm:"size:20;not null"`                     // export, deletion
+	Status      string            `json:"status" gorm:"size:20;not null;default:'pending'"` // pending, completed, failed
+	Attempts    int               `json:"attempts" gorm:"default:0"`                        // 已分发的次数
+	LastSentAt  time.Time         `json:"last_sent_at"`
+	CompletedAt *time.Time        `json:"completed_at"`
+	ExpiresAt   *time.Time        `json:"expires_at" gorm:"index"` // 导出文件的下载截止时间，之后删除导出的数据
+	CreatedAt   time.Time         `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time         `json:"updated_at" gorm:"autoUpdateTime"`
+	Tasks       []DataRequestTask `json:"tasks" gorm:"foreignKey:RequestID"`
+}
+
+// DataRequestTask 个人数据请求在某个服务上的处理进度，导出时记录该服务导出的数据
+type DataRequestTask struct {
+	ID        uint      `json:"-" gorm:"primaryKey;autoIncrement"`
+	RequestID uint      `json:"-" gorm:"not null;uniqueIndex:idx_request_service"`
+	Service   string    `json:"service" gorm:"size:50;not null;uniqueIndex:idx_request_service"`
+	Status    string    `json:"status" gorm:"size:20;not null;default:'pending'"` // pending, completed, failed
+	Error     string    `json:"error,omitempty" gorm:"size:255"`                  // 最近一次处理或分发失败的原因
+	ExportID  uint      `json:"-"`                                                // 导出的数据，见 UserDataExport
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// UserDataExport 服务为个人数据导出收集的数据，各服务直接写入共用的数据库
+// 数据可能很大，不经过Kafka，处理结果中只带导出的ID
+type UserDataExport struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	RequestID uint      `gorm:"not null;uniqueIndex:idx_export_request_service"`
+	Service   string    `gorm:"size:50;not null;uniqueIndex:idx_export_request_service"`
+	Data      string    `gorm:"type:longtext"` // 导出的JSON数据
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 }
 
 // EmailVerification 邮箱验证
@@ -73,23 +214,23 @@ type Transaction struct {
 
 // PaymentRequest 支付请求
 type PaymentRequest struct {
-	UserID      uint    `json:"user_id" validate:"required"`
-	Amount      float64 `json:"amount" validate:"required,gt=0"`
-	Description string  `json:"description" validate:"required"`
+	UserID      uint    `json:"user_id" binding:"required"`
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Description string  `json:"description"` // 可选
 }
 
 // ProductPurchaseRequest 商品购买请求
 type ProductPurchaseRequest struct {
-	UserID    uint  `json:"user_id" validate:"required"`
-	ProductID uint  `json:"product_id" validate:"required"`
-	Quantity  int   `json:"quantity" validate:"required,gt=0"`
+	UserID    uint  `json:"user_id" binding:"required"`
+	ProductID uint  `json:"product_id" binding:"required"`
+	Quantity  int   `json:"quantity" binding:"required,gt=0"`
 	OrderID   *uint `json:"order_id"` // 可选，如果有订单ID则关联
 }
 
 // RefundRequest 退款请求
 type RefundRequest struct {
-	TransactionID uint   `json:"transaction_id" validate:"required"`
-	Reason        string `json:"reason" validate:"required"`
+	TransactionID uint   `json:"transaction_id" binding:"required"`
+	Reason        string `json:"reason" binding:"required"`
 }
 
 // Comment 评论模型
@@ -105,8 +246,8 @@ type Comment struct {
 
 // CommentRequest 评论请求
 type CommentRequest struct {
-	UserID   uint   `json:"user_id" validate:"required"`
-	Content  string `json:"content" validate:"required"`
+	UserID   uint   `json:"user_id" binding:"required"`
+	Content  string `json:"content" binding:"required"`
 	ParentID *uint  `json:"parent_id"`
 }
 
@@ -143,6 +284,68 @@ type UserLoginEvent struct {
 	Email  string `json:"email"`
 }
 
+// UserUpdatedEvent 用户资料变更事件，携带变更后的完整资料，其他服务据此更新冗余的用户信息
+type UserUpdatedEvent struct {
+	UserID      uint      `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	Bio         string    `json:"bio"`
+	Changed     []string  `json:"changed"` // 本次变更的字段，如 username、email
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UserPasswordResetEvent 密码重置事件，重置完成、该用户的登录已全部注销后发送
+type UserPasswordResetEvent struct {
+	UserID  uint      `json:"user_id"`
+	Email   string    `json:"email"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// UserLockoutEvent 登录保护锁定事件，因登录失败过多锁定账户或封禁IP，以及管理员解除锁定时发送
+type UserLockoutEvent struct {
+	Action      string     `json:"action"` // lock 或 unlock
+	Scope       string     `json:"scope"`  // email 或 ip
+	Email       string     `json:"email,omitempty"`
+	UserID      uint       `json:"user_id,omitempty"` // 邮箱对应已注册用户时填写
+	IP          string     `json:"ip,omitempty"`
+	Failures    int        `json:"failures,omitempty"`     // 锁定时窗口内的失败次数
+	LockedUntil *time.Time `json:"locked_until,omitempty"` // 锁定截止时间
+	Operator    uint       `json:"operator,omitempty"`     // 解除锁定的管理员
+	OccurredAt  time.Time  `json:"occurred_at"`
+}
+
+// UserRoleChangedEvent 用户角色变更事件，变更记录写入数据库后发送
+type UserRoleChangedEvent struct {
+	UserID     uint      `json:"user_id"`
+	Email      string    `json:"email"`
+	OldRole    string    `json:"old_role"`
+	NewRole    string    `json:"new_role"`
+	OperatorID uint      `json:"operator_id"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// UserDataRequestEvent 个人数据请求事件，通过 user.export.requested 或 user.deleted 发送给各服务
+// 未收到所有服务的处理结果时会重新发送，各服务需要可以重复处理
+type UserDataRequestEvent struct {
+	RequestID   uint      `json:"request_id"`
+	UserID      uint      `json:"user_id"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// UserDataProgressEvent 服务处理个人数据请求的结果，通过 user.data.progress 发送给用户服务
+type UserDataProgressEvent struct {
+	RequestID uint   `json:"request_id"`
+	UserID    uint   `json:"user_id"`
+	Type      string `json:"type"` // export, deletion
+	Service   string `json:"service"`
+	Status    string `json:"status"` // completed, failed
+	Error     string `json:"error,omitempty"`
+	ExportID  uint   `json:"export_id,omitempty"` // 导出时为保存在 UserDataExport 中的数据
+}
+
 // PaymentEvent 支付事件
 type PaymentEvent struct {
 	UserID        uint    `json:"user_id"`
@@ -161,3 +364,9 @@ type CommentEvent struct {
 	Content   string `json:"content"`
 	Action    string `json:"action"` // "create", "update", "delete"
 }
+
+// ProductEvent 商品事件，商品信息或库存变化时发送
+type ProductEvent struct {
+	ProductID uint   `json:"product_id"`
+	Action    string `json:"action"` // "create", "update", "delete"
+}
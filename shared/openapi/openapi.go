@@ -0,0 +1,50 @@
+package openapi
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// specs 各服务的OpenAPI文档，文件名为服务名，路径是服务自身注册的路径
+//
+//go:embed specs/*.yaml
+var specs embed.FS
+
+// Services 返回有文档的服务名，按名称排序
+func Services() []string {
+	entries, err := specs.ReadDir("specs")
+	if err != nil {
+		return nil
+	}
+
+	services := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		services = append(services, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	sort.Strings(services)
+	return services
+}
+
+// Load 加载并校验服务的文档，每次调用返回新的副本，调用方可以修改
+func Load(service string) (*openapi3.T, error) {
+	data, err := specs.ReadFile("specs/" + service + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("no openapi spec for %s", service)
+	}
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse openapi spec for %s: %v", service, err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec for %s: %v", service, err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,32 @@
+package walletpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative wallet/wallet.proto
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewError 返回携带业务错误码的gRPC错误
+func NewError(c codes.Code, code ErrorCode, message string) error {
+	st := status.New(c, message)
+	detailed, err := st.WithDetails(&ErrorDetail{Code: code, Message: message})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// ErrorCodeOf 读取gRPC错误中的业务错误码，没有时返回 ERROR_CODE_UNSPECIFIED
+func ErrorCodeOf(err error) ErrorCode {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ErrorCode_ERROR_CODE_UNSPECIFIED
+	}
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*ErrorDetail); ok {
+			return d.GetCode()
+		}
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
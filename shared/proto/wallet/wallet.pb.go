@@ -0,0 +1,958 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: wallet/wallet.proto
+
+// 钱包服务内部gRPC接口，供商城等服务调用，不经过网关对外暴露
+
+package walletpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ErrorCode 业务错误码，随gRPC状态的details返回
+type ErrorCode int32
+
+const (
+	ErrorCode_ERROR_CODE_UNSPECIFIED           ErrorCode = 0
+	ErrorCode_ERROR_CODE_INVALID_ARGUMENT      ErrorCode = 1
+	ErrorCode_ERROR_CODE_INSUFFICIENT_FUNDS    ErrorCode = 2
+	ErrorCode_ERROR_CODE_SAME_ACCOUNT          ErrorCode = 3
+	ErrorCode_ERROR_CODE_TRANSACTION_NOT_FOUND ErrorCode = 4
+	ErrorCode_ERROR_CODE_ALREADY_REFUNDED      ErrorCode = 5
+	ErrorCode_ERROR_CODE_NOT_REFUNDABLE        ErrorCode = 6
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0: "ERROR_CODE_UNSPECIFIED",
+		1: "ERROR_CODE_INVALID_ARGUMENT",
+		2: "ERROR_CODE_INSUFFICIENT_FUNDS",
+		3: "ERROR_CODE_SAME_ACCOUNT",
+		4: "ERROR_CODE_TRANSACTION_NOT_FOUND",
+		5: "ERROR_CODE_ALREADY_REFUNDED",
+		6: "ERROR_CODE_NOT_REFUNDABLE",
+	}
+	ErrorCode_value = map[string]int32{
+		"ERROR_CODE_UNSPECIFIED":           0,
+		"ERROR_CODE_INVALID_ARGUMENT":      1,
+		"ERROR_CODE_INSUFFICIENT_FUNDS":    2,
+		"ERROR_CODE_SAME_ACCOUNT":          3,
+		"ERROR_CODE_TRANSACTION_NOT_FOUND": 4,
+		"ERROR_CODE_ALREADY_REFUNDED":      5,
+		"ERROR_CODE_NOT_REFUNDABLE":        6,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_wallet_wallet_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_wallet_wallet_proto_enumTypes[0]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{0}
+}
+
+// ErrorDetail 业务错误详情
+type ErrorDetail struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          ErrorCode              `protobuf:"varint,1,opt,name=code,proto3,enum=wallet.v1.ErrorCode" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorDetail) Reset() {
+	*x = ErrorDetail{}
+	mi := &file_wallet_wallet_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorDetail) ProtoMessage() {}
+
+func (x *ErrorDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorDetail.ProtoReflect.Descriptor instead.
+func (*ErrorDetail) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ErrorDetail) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+func (x *ErrorDetail) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Transaction 交易记录，未关联商品或订单时对应ID为0
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId        uint64                 `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	WalletId      uint64                 `protobuf:"varint,3,opt,name=wallet_id,json=walletId,proto3" json:"wallet_id,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Amount        float64                `protobuf:"fixed64,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	Description   string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	ProductId     uint64                 `protobuf:"varint,7,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	OrderId       uint64                 `protobuf:"varint,8,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Quantity      int32                  `protobuf:"varint,9,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Status        string                 `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"` // Unix秒
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_wallet_wallet_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Transaction) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Transaction) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Transaction) GetWalletId() uint64 {
+	if x != nil {
+		return x.WalletId
+	}
+	return 0
+}
+
+func (x *Transaction) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Transaction) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Transaction) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Transaction) GetProductId() uint64 {
+	if x != nil {
+		return x.ProductId
+	}
+	return 0
+}
+
+func (x *Transaction) GetOrderId() uint64 {
+	if x != nil {
+		return x.OrderId
+	}
+	return 0
+}
+
+func (x *Transaction) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *Transaction) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Transaction) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type DeductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Amount        float64                `protobuf:"fixed64,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeductRequest) Reset() {
+	*x = DeductRequest{}
+	mi := &file_wallet_wallet_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeductRequest) ProtoMessage() {}
+
+func (x *DeductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeductRequest.ProtoReflect.Descriptor instead.
+func (*DeductRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeductRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *DeductRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *DeductRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type AddRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Amount        float64                `protobuf:"fixed64,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddRequest) Reset() {
+	*x = AddRequest{}
+	mi := &file_wallet_wallet_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRequest) ProtoMessage() {}
+
+func (x *AddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRequest.ProtoReflect.Descriptor instead.
+func (*AddRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AddRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *AddRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *AddRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+// PurchaseItem 订单中的一个商品
+type PurchaseItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductId     uint64                 `protobuf:"varint,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Quantity      int32                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	UnitPrice     float64                `protobuf:"fixed64,3,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	ProductName   string                 `protobuf:"bytes,4,opt,name=product_name,json=productName,proto3" json:"product_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurchaseItem) Reset() {
+	*x = PurchaseItem{}
+	mi := &file_wallet_wallet_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurchaseItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurchaseItem) ProtoMessage() {}
+
+func (x *PurchaseItem) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurchaseItem.ProtoReflect.Descriptor instead.
+func (*PurchaseItem) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PurchaseItem) GetProductId() uint64 {
+	if x != nil {
+		return x.ProductId
+	}
+	return 0
+}
+
+func (x *PurchaseItem) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *PurchaseItem) GetUnitPrice() float64 {
+	if x != nil {
+		return x.UnitPrice
+	}
+	return 0
+}
+
+func (x *PurchaseItem) GetProductName() string {
+	if x != nil {
+		return x.ProductName
+	}
+	return ""
+}
+
+type PurchaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint64                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	OrderId       uint64                 `protobuf:"varint,2,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Items         []*PurchaseItem        `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurchaseRequest) Reset() {
+	*x = PurchaseRequest{}
+	mi := &file_wallet_wallet_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurchaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurchaseRequest) ProtoMessage() {}
+
+func (x *PurchaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurchaseRequest.ProtoReflect.Descriptor instead.
+func (*PurchaseRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PurchaseRequest) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *PurchaseRequest) GetOrderId() uint64 {
+	if x != nil {
+		return x.OrderId
+	}
+	return 0
+}
+
+func (x *PurchaseRequest) GetItems() []*PurchaseItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type PurchaseReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	TotalAmount   float64                `protobuf:"fixed64,2,opt,name=total_amount,json=totalAmount,proto3" json:"total_amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PurchaseReply) Reset() {
+	*x = PurchaseReply{}
+	mi := &file_wallet_wallet_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PurchaseReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PurchaseReply) ProtoMessage() {}
+
+func (x *PurchaseReply) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PurchaseReply.ProtoReflect.Descriptor instead.
+func (*PurchaseReply) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PurchaseReply) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+func (x *PurchaseReply) GetTotalAmount() float64 {
+	if x != nil {
+		return x.TotalAmount
+	}
+	return 0
+}
+
+type RefundRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Target:
+	//
+	//	*RefundRequest_TransactionId
+	//	*RefundRequest_OrderId
+	Target        isRefundRequest_Target `protobuf_oneof:"target"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefundRequest) Reset() {
+	*x = RefundRequest{}
+	mi := &file_wallet_wallet_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundRequest) ProtoMessage() {}
+
+func (x *RefundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundRequest.ProtoReflect.Descriptor instead.
+func (*RefundRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RefundRequest) GetTarget() isRefundRequest_Target {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *RefundRequest) GetTransactionId() uint64 {
+	if x != nil {
+		if x, ok := x.Target.(*RefundRequest_TransactionId); ok {
+			return x.TransactionId
+		}
+	}
+	return 0
+}
+
+func (x *RefundRequest) GetOrderId() uint64 {
+	if x != nil {
+		if x, ok := x.Target.(*RefundRequest_OrderId); ok {
+			return x.OrderId
+		}
+	}
+	return 0
+}
+
+func (x *RefundRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type isRefundRequest_Target interface {
+	isRefundRequest_Target()
+}
+
+type RefundRequest_TransactionId struct {
+	TransactionId uint64 `protobuf:"varint,1,opt,name=transaction_id,json=transactionId,proto3,oneof"`
+}
+
+type RefundRequest_OrderId struct {
+	OrderId uint64 `protobuf:"varint,2,opt,name=order_id,json=orderId,proto3,oneof"`
+}
+
+func (*RefundRequest_TransactionId) isRefundRequest_Target() {}
+
+func (*RefundRequest_OrderId) isRefundRequest_Target() {}
+
+type RefundReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefundReply) Reset() {
+	*x = RefundReply{}
+	mi := &file_wallet_wallet_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundReply) ProtoMessage() {}
+
+func (x *RefundReply) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundReply.ProtoReflect.Descriptor instead.
+func (*RefundReply) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RefundReply) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type TransferRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FromUserId    uint64                 `protobuf:"varint,1,opt,name=from_user_id,json=fromUserId,proto3" json:"from_user_id,omitempty"`
+	ToUserId      uint64                 `protobuf:"varint,2,opt,name=to_user_id,json=toUserId,proto3" json:"to_user_id,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferRequest) Reset() {
+	*x = TransferRequest{}
+	mi := &file_wallet_wallet_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferRequest) ProtoMessage() {}
+
+func (x *TransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferRequest.ProtoReflect.Descriptor instead.
+func (*TransferRequest) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TransferRequest) GetFromUserId() uint64 {
+	if x != nil {
+		return x.FromUserId
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetToUserId() uint64 {
+	if x != nil {
+		return x.ToUserId
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type TransferReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferReply) Reset() {
+	*x = TransferReply{}
+	mi := &file_wallet_wallet_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferReply) ProtoMessage() {}
+
+func (x *TransferReply) ProtoReflect() protoreflect.Message {
+	mi := &file_wallet_wallet_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferReply.ProtoReflect.Descriptor instead.
+func (*TransferReply) Descriptor() ([]byte, []int) {
+	return file_wallet_wallet_proto_rawDescGZIP(), []int{10}
+}
+
+var File_wallet_wallet_proto protoreflect.FileDescriptor
+
+const file_wallet_wallet_proto_rawDesc = "" +
+	"\n" +
+	"\x13wallet/wallet.proto\x12\twallet.v1\"Q\n" +
+	"\vErrorDetail\x12(\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x14.wallet.v1.ErrorCodeR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xae\x02\n" +
+	"\vTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x04R\x06userId\x12\x1b\n" +
+	"\twallet_id\x18\x03 \x01(\x04R\bwalletId\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\x01R\x06amount\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\a \x01(\x04R\tproductId\x12\x19\n" +
+	"\border_id\x18\b \x01(\x04R\aorderId\x12\x1a\n" +
+	"\bquantity\x18\t \x01(\x05R\bquantity\x12\x16\n" +
+	"\x06status\x18\n" +
+	" \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\v \x01(\x03R\tcreatedAt\"b\n" +
+	"\rDeductRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x01R\x06amount\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\"_\n" +
+	"\n" +
+	"AddRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x01R\x06amount\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\"\x8b\x01\n" +
+	"\fPurchaseItem\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\x04R\tproductId\x12\x1a\n" +
+	"\bquantity\x18\x02 \x01(\x05R\bquantity\x12\x1d\n" +
+	"\n" +
+	"unit_price\x18\x03 \x01(\x01R\tunitPrice\x12!\n" +
+	"\fproduct_name\x18\x04 \x01(\tR\vproductName\"t\n" +
+	"\x0fPurchaseRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x04R\x06userId\x12\x19\n" +
+	"\border_id\x18\x02 \x01(\x04R\aorderId\x12-\n" +
+	"\x05items\x18\x03 \x03(\v2\x17.wallet.v1.PurchaseItemR\x05items\"n\n" +
+	"\rPurchaseReply\x12:\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x16.wallet.v1.TransactionR\ftransactions\x12!\n" +
+	"\ftotal_amount\x18\x02 \x01(\x01R\vtotalAmount\"w\n" +
+	"\rRefundRequest\x12'\n" +
+	"\x0etransaction_id\x18\x01 \x01(\x04H\x00R\rtransactionId\x12\x1b\n" +
+	"\border_id\x18\x02 \x01(\x04H\x00R\aorderId\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reasonB\b\n" +
+	"\x06target\"I\n" +
+	"\vRefundReply\x12:\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x16.wallet.v1.TransactionR\ftransactions\"\x8b\x01\n" +
+	"\x0fTransferRequest\x12 \n" +
+	"\ffrom_user_id\x18\x01 \x01(\x04R\n" +
+	"fromUserId\x12\x1c\n" +
+	"\n" +
+	"to_user_id\x18\x02 \x01(\x04R\btoUserId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\"\x0f\n" +
+	"\rTransferReply*\xee\x01\n" +
+	"\tErrorCode\x12\x1a\n" +
+	"\x16ERROR_CODE_UNSPECIFIED\x10\x00\x12\x1f\n" +
+	"\x1bERROR_CODE_INVALID_ARGUMENT\x10\x01\x12!\n" +
+	"\x1dERROR_CODE_INSUFFICIENT_FUNDS\x10\x02\x12\x1b\n" +
+	"\x17ERROR_CODE_SAME_ACCOUNT\x10\x03\x12$\n" +
+	" ERROR_CODE_TRANSACTION_NOT_FOUND\x10\x04\x12\x1f\n" +
+	"\x1bERROR_CODE_ALREADY_REFUNDED\x10\x05\x12\x1d\n" +
+	"\x19ERROR_CODE_NOT_REFUNDABLE\x10\x062\xc1\x02\n" +
+	"\rWalletService\x12:\n" +
+	"\x06Deduct\x12\x18.wallet.v1.DeductRequest\x1a\x16.wallet.v1.Transaction\x124\n" +
+	"\x03Add\x12\x15.wallet.v1.AddRequest\x1a\x16.wallet.v1.Transaction\x12@\n" +
+	"\bPurchase\x12\x1a.wallet.v1.PurchaseRequest\x1a\x18.wallet.v1.PurchaseReply\x12:\n" +
+	"\x06Refund\x12\x18.wallet.v1.RefundRequest\x1a\x16.wallet.v1.RefundReply\x12@\n" +
+	"\bTransfer\x12\x1a.wallet.v1.TransferRequest\x1a\x18.wallet.v1.TransferReplyB#Z!blog/shared/proto/wallet;walletpbb\x06proto3"
+
+var (
+	file_wallet_wallet_proto_rawDescOnce sync.Once
+	file_wallet_wallet_proto_rawDescData []byte
+)
+
+func file_wallet_wallet_proto_rawDescGZIP() []byte {
+	file_wallet_wallet_proto_rawDescOnce.Do(func() {
+		file_wallet_wallet_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_wallet_wallet_proto_rawDesc), len(file_wallet_wallet_proto_rawDesc)))
+	})
+	return file_wallet_wallet_proto_rawDescData
+}
+
+var file_wallet_wallet_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_wallet_wallet_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_wallet_wallet_proto_goTypes = []any{
+	(ErrorCode)(0),          // 0: wallet.v1.ErrorCode
+	(*ErrorDetail)(nil),     // 1: wallet.v1.ErrorDetail
+	(*Transaction)(nil),     // 2: wallet.v1.Transaction
+	(*DeductRequest)(nil),   // 3: wallet.v1.DeductRequest
+	(*AddRequest)(nil),      // 4: wallet.v1.AddRequest
+	(*PurchaseItem)(nil),    // 5: wallet.v1.PurchaseItem
+	(*PurchaseRequest)(nil), // 6: wallet.v1.PurchaseRequest
+	(*PurchaseReply)(nil),   // 7: wallet.v1.PurchaseReply
+	(*RefundRequest)(nil),   // 8: wallet.v1.RefundRequest
+	(*RefundReply)(nil),     // 9: wallet.v1.RefundReply
+	(*TransferRequest)(nil), // 10: wallet.v1.TransferRequest
+	(*TransferReply)(nil),   // 11: wallet.v1.TransferReply
+}
+var file_wallet_wallet_proto_depIdxs = []int32{
+	0,  // 0: wallet.v1.ErrorDetail.code:type_name -> wallet.v1.ErrorCode
+	5,  // 1: wallet.v1.PurchaseRequest.items:type_name -> wallet.v1.PurchaseItem
+	2,  // 2: wallet.v1.PurchaseReply.transactions:type_name -> wallet.v1.Transaction
+	2,  // 3: wallet.v1.RefundReply.transactions:type_name -> wallet.v1.Transaction
+	3,  // 4: wallet.v1.WalletService.Deduct:input_type -> wallet.v1.DeductRequest
+	4,  // 5: wallet.v1.WalletService.Add:input_type -> wallet.v1.AddRequest
+	6,  // 6: wallet.v1.WalletService.Purchase:input_type -> wallet.v1.PurchaseRequest
+	8,  // 7: wallet.v1.WalletService.Refund:input_type -> wallet.v1.RefundRequest
+	10, // 8: wallet.v1.WalletService.Transfer:input_type -> wallet.v1.TransferRequest
+	2,  // 9: wallet.v1.WalletService.Deduct:output_type -> wallet.v1.Transaction
+	2,  // 10: wallet.v1.WalletService.Add:output_type -> wallet.v1.Transaction
+	7,  // 11: wallet.v1.WalletService.Purchase:output_type -> wallet.v1.PurchaseReply
+	9,  // 12: wallet.v1.WalletService.Refund:output_type -> wallet.v1.RefundReply
+	11, // 13: wallet.v1.WalletService.Transfer:output_type -> wallet.v1.TransferReply
+	9,  // [9:14] is the sub-list for method output_type
+	4,  // [4:9] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_wallet_wallet_proto_init() }
+func file_wallet_wallet_proto_init() {
+	if File_wallet_wallet_proto != nil {
+		return
+	}
+	file_wallet_wallet_proto_msgTypes[7].OneofWrappers = []any{
+		(*RefundRequest_TransactionId)(nil),
+		(*RefundRequest_OrderId)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_wallet_wallet_proto_rawDesc), len(file_wallet_wallet_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_wallet_wallet_proto_goTypes,
+		DependencyIndexes: file_wallet_wallet_proto_depIdxs,
+		EnumInfos:         file_wallet_wallet_proto_enumTypes,
+		MessageInfos:      file_wallet_wallet_proto_msgTypes,
+	}.Build()
+	File_wallet_wallet_proto = out.File
+	file_wallet_wallet_proto_goTypes = nil
+	file_wallet_wallet_proto_depIdxs = nil
+}
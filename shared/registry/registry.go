@@ -1,23 +1,55 @@
-package main
+package registry
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// DefaultTTL 实例注册的存活时间，超过该时间未续期的实例视为下线
+const DefaultTTL = 30 * time.Second
+
+// DefaultHeartbeatInterval 默认心跳间隔
+const DefaultHeartbeatInterval = 10 * time.Second
+
+// DeregisterDelay 注销后等待网关刷新实例列表的时间，与网关默认的刷新间隔一致
+const DeregisterDelay = 5 * time.Second
+
+// LabelVersion 实例版本标签，网关按版本分流
+const LabelVersion = "version"
+
+// DefaultVersion 未设置版本标签的实例所属的版本
+const DefaultVersion = "stable"
+
 // ServiceRegistration 服务注册信息
 type ServiceRegistration struct {
-	ServiceName string    `json:"service_name"`
-	Address     string    `json:"address"`
-	Port        int       `json:"port"`
-	HealthURL   string    `json:"health_url"`
-	Status      string    `json:"status"` // healthy, unhealthy
-	LastCheck   time.Time `json:"last_check"`
+	ID          string            `json:"id"`
+	ServiceName string            `json:"service_name"`
+	Address     string            `json:"address"`
+	Port        int               `json:"port"`
+	HealthURL   string            `json:"health_url"`
+	Status      string            `json:"status"` // healthy, unhealthy
+	LastCheck   time.Time         `json:"last_check"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// Endpoint 返回实例的 host:port 地址
+func (s ServiceRegistration) Endpoint() string {
+	return fmt.Sprintf("%s:%d", s.Address, s.Port)
+}
+
+// Version 返回实例的版本标签，未设置时为 DefaultVersion
+func (s ServiceRegistration) Version() string {
+	if version := s.Labels[LabelVersion]; version != "" {
+		return version
+	}
+	return DefaultVersion
 }
 
 // ServiceRegistry Redis服务注册中心
@@ -47,9 +79,60 @@ func NewServiceRegistry(redisAddr, redisPassword string, db int) (*ServiceRegist
 	}, nil
 }
 
-// RegisterService 注册服务
+// NewLocalRegistration 根据环境变量构建当前实例的注册信息
+// SERVICE_HOST 未设置时使用主机名，容器内即为可被其他服务访问的地址；
+// SERVICE_VERSION 为实例的版本标签，灰度发布时新版本的实例设置为新的版本号
+func NewLocalRegistration(serviceName, port string) (ServiceRegistration, error) {
+	host := os.Getenv("SERVICE_HOST")
+	if host == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return ServiceRegistration{}, fmt.Errorf("failed to resolve hostname: %v", err)
+		}
+		host = hostname
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return ServiceRegistration{}, fmt.Errorf("invalid port %q: %v", port, err)
+	}
+
+	labels := map[string]string{LabelVersion: DefaultVersion}
+	if version := os.Getenv("SERVICE_VERSION"); version != "" {
+		labels[LabelVersion] = version
+	}
+
+	return ServiceRegistration{
+		ServiceName: serviceName,
+		Address:     host,
+		Port:        portNum,
+		HealthURL:   fmt.Sprintf("http://%s:%d/health/ready", host, portNum),
+		Status:      "healthy",
+		Labels:      labels,
+	}, nil
+}
+
+// drainedKey 被摘除流量的实例集合，成员为 服务名/实例ID
+const drainedKey = "drained_instances"
+
+// instanceKey 实例数据的键
+func instanceKey(serviceName, instanceID string) string {
+	return fmt.Sprintf("service:%s:%s", serviceName, instanceID)
+}
+
+// instancesKey 服务实例ID集合的键
+func instancesKey(serviceName string) string {
+	return fmt.Sprintf("services:%s", serviceName)
+}
+
+// RegisterService 注册服务实例
 func (sr *ServiceRegistry) RegisterService(service ServiceRegistration) error {
-	key := fmt.Sprintf("service:%s", service.ServiceName)
+	if service.ID == "" {
+		service.ID = service.Endpoint()
+	}
+	if service.Status == "" {
+		service.Status = "healthy"
+	}
 
 	service.LastCheck = time.Now()
 	serviceData, err := json.Marshal(service)
@@ -57,37 +140,72 @@ func (sr *ServiceRegistry) RegisterService(service ServiceRegistration) error {
 		return err
 	}
 
-	// 设置过期时间为30秒，服务需要定期续期
-	err = sr.client.Set(sr.ctx, key, serviceData, 30*time.Second).Err()
+	// 设置过期时间，服务需要定期续期
+	err = sr.client.Set(sr.ctx, instanceKey(service.ServiceName, service.ID), serviceData, DefaultTTL).Err()
 	if err != nil {
 		return err
 	}
 
-	// 添加到服务列表
-	sr.client.SAdd(sr.ctx, "services", service.ServiceName)
+	// 添加到服务列表和实例列表
+	pipe := sr.client.TxPipeline()
+	pipe.SAdd(sr.ctx, "services", service.ServiceName)
+	pipe.SAdd(sr.ctx, instancesKey(service.ServiceName), service.ID)
+	if _, err := pipe.Exec(sr.ctx); err != nil {
+		return err
+	}
 
-	log.Printf("✅ 服务 %s 已注册到Redis: %s:%d", service.ServiceName, service.Address, service.Port)
+	sr.service = &service
 	return nil
 }
 
-// GetService 获取服务信息
+// GetService 获取服务的任意一个可用实例
 func (sr *ServiceRegistry) GetService(serviceName string) (*ServiceRegistration, error) {
-	key := fmt.Sprintf("service:%s", serviceName)
-	val, err := sr.client.Get(sr.ctx, key).Result()
+	instances, err := sr.GetServiceInstances(serviceName)
 	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
 		return nil, fmt.Errorf("service not found: %s", serviceName)
 	}
+	return &instances[0], nil
+}
 
-	var service ServiceRegistration
-	err = json.Unmarshal([]byte(val), &service)
+// GetServiceInstances 获取服务的所有存活实例
+// 心跳过期的实例会被过滤并从实例列表中清理
+func (sr *ServiceRegistry) GetServiceInstances(serviceName string) ([]ServiceRegistration, error) {
+	ids, err := sr.client.SMembers(sr.ctx, instancesKey(serviceName)).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	return &service, nil
+	var instances []ServiceRegistration
+	for _, id := range ids {
+		val, err := sr.client.Get(sr.ctx, instanceKey(serviceName, id)).Result()
+		if err == redis.Nil {
+			// 注册已过期，清理实例列表
+			sr.client.SRem(sr.ctx, instancesKey(serviceName), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var instance ServiceRegistration
+		if err := json.Unmarshal([]byte(val), &instance); err != nil {
+			log.Printf("Failed to unmarshal instance %s of %s: %v", id, serviceName, err)
+			continue
+		}
+
+		if instance.Status != "healthy" || time.Since(instance.LastCheck) > DefaultTTL {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
 }
 
-// GetAllServices 获取所有服务
+// GetAllServices 获取所有服务的存活实例
 func (sr *ServiceRegistry) GetAllServices() ([]ServiceRegistration, error) {
 	// 获取所有服务名称
 	serviceNames, err := sr.client.SMembers(sr.ctx, "services").Result()
@@ -97,34 +215,92 @@ func (sr *ServiceRegistry) GetAllServices() ([]ServiceRegistration, error) {
 
 	var services []ServiceRegistration
 	for _, name := range serviceNames {
-		service, err := sr.GetService(name)
+		instances, err := sr.GetServiceInstances(name)
 		if err != nil {
 			continue
 		}
-		services = append(services, *service)
+		services = append(services, instances...)
 	}
 
 	return services, nil
 }
 
-// RenewService 续期服务（心跳）
-func (sr *ServiceRegistry) RenewService(serviceName string) error {
-	service, err := sr.GetService(serviceName)
+// RenewService 续期服务实例（心跳）
+func (sr *ServiceRegistry) RenewService(serviceName, instanceID string) error {
+	val, err := sr.client.Get(sr.ctx, instanceKey(serviceName, instanceID)).Result()
 	if err != nil {
+		if err == redis.Nil && sr.service != nil && sr.service.ServiceName == serviceName && sr.service.ID == instanceID {
+			// 注册已过期（例如Redis重启），使用本地信息重新注册
+			return sr.RegisterService(*sr.service)
+		}
+		return fmt.Errorf("service instance not found: %s/%s", serviceName, instanceID)
+	}
+
+	var service ServiceRegistration
+	if err := json.Unmarshal([]byte(val), &service); err != nil {
 		return err
 	}
 
-	service.LastCheck = time.Now()
 	service.Status = "healthy"
-	return sr.RegisterService(*service)
+	return sr.RegisterService(service)
+}
+
+// KeepAlive 注册服务实例并按间隔续期，直到stop被关闭
+func (sr *ServiceRegistry) KeepAlive(service ServiceRegistration, interval time.Duration, stop <-chan struct{}) error {
+	if err := sr.RegisterService(service); err != nil {
+		return err
+	}
+	registered := *sr.service
+	log.Printf("✅ 服务 %s 已注册到Redis: %s", registered.ServiceName, registered.Endpoint())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := sr.RenewService(registered.ServiceName, registered.ID); err != nil {
+					log.Printf("Failed to renew service %s: %v", registered.ServiceName, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// SetDrained 摘除或恢复实例的流量，摘除的实例仍保持注册，网关不再向其转发新请求
+func (sr *ServiceRegistry) SetDrained(serviceName, instanceID string, drained bool) error {
+	member := serviceName + "/" + instanceID
+	if drained {
+		return sr.client.SAdd(sr.ctx, drainedKey, member).Err()
+	}
+	return sr.client.SRem(sr.ctx, drainedKey, member).Err()
 }
 
-// UnregisterService 注销服务
-func (sr *ServiceRegistry) UnregisterService(serviceName string) error {
-	key := fmt.Sprintf("service:%s", serviceName)
-	sr.client.Del(sr.ctx, key)
-	sr.client.SRem(sr.ctx, "services", serviceName)
-	log.Printf("❌ 服务 %s 已注销", serviceName)
+// DrainedInstances 返回被摘除流量的实例，键为 服务名/实例ID
+func (sr *ServiceRegistry) DrainedInstances() (map[string]bool, error) {
+	members, err := sr.client.SMembers(sr.ctx, drainedKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	drained := make(map[string]bool, len(members))
+	for _, member := range members {
+		drained[member] = true
+	}
+	return drained, nil
+}
+
+// UnregisterService 注销服务实例
+// 同时清除摘除标记，实例以相同地址重启后重新接收流量
+func (sr *ServiceRegistry) UnregisterService(serviceName, instanceID string) error {
+	sr.client.Del(sr.ctx, instanceKey(serviceName, instanceID))
+	sr.client.SRem(sr.ctx, instancesKey(serviceName), instanceID)
+	sr.client.SRem(sr.ctx, drainedKey, serviceName+"/"+instanceID)
+	log.Printf("❌ 服务 %s 实例 %s 已注销", serviceName, instanceID)
 	return nil
 }
 
@@ -132,3 +308,30 @@ func (sr *ServiceRegistry) UnregisterService(serviceName string) error {
 func (sr *ServiceRegistry) Close() error {
 	return sr.client.Close()
 }
+
+// RegisterLocal 将当前实例注册到注册中心并保持心跳，返回用于注销实例的函数
+func RegisterLocal(redisAddr, redisPassword string, db int, serviceName, port string) (func(), error) {
+	sr, err := NewServiceRegistry(redisAddr, redisPassword, db)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := NewLocalRegistration(serviceName, port)
+	if err != nil {
+		sr.Close()
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	if err := sr.KeepAlive(service, DefaultHeartbeatInterval, stop); err != nil {
+		sr.Close()
+		return nil, err
+	}
+
+	registered := *sr.service
+	return func() {
+		close(stop)
+		sr.UnregisterService(registered.ServiceName, registered.ID)
+		sr.Close()
+	}, nil
+}
@@ -0,0 +1,67 @@
+package serviceauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataServiceToken gRPC调用携带令牌的metadata键
+const metadataServiceToken = "x-service-token"
+
+// grpcMethod gRPC调用均为HTTP/2 POST，令牌和ACL中的路径为完整方法名
+// ACL中的接口格式为 "POST /wallet.v1.WalletService/Purchase"
+const grpcMethod = http.MethodPost
+
+// UnaryClientInterceptor 为发往 audience 服务的gRPC调用签发令牌
+func (s *Signer) UnaryClientInterceptor(audience string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		token, err := s.Token(audience, grpcMethod, method)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, metadataServiceToken, token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor 要求gRPC调用携带有效的服务令牌，且调用方被ACL允许调用该方法
+func (v *Verifier) UnaryServerInterceptor(acl *ACL) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(metadataServiceToken); len(values) > 0 {
+				token = values[0]
+			}
+		}
+
+		caller, err := v.VerifyToken(token, grpcMethod, info.FullMethod)
+		if err != nil {
+			message := "invalid service token"
+			if errors.Is(err, ErrMissingToken) {
+				message = "service authentication required"
+			}
+			return nil, status.Error(codes.Unauthenticated, message)
+		}
+
+		if !acl.Allowed(caller, grpcMethod, info.FullMethod) {
+			return nil, status.Error(codes.PermissionDenied, "caller "+caller+" is not allowed to call "+info.FullMethod)
+		}
+
+		return handler(context.WithValue(ctx, callerKey{}, caller), req)
+	}
+}
+
+// callerKey context中保存gRPC调用方服务名的键
+type callerKey struct{}
+
+// CallerFromIncomingContext 返回通过认证的gRPC调用方服务名
+func CallerFromIncomingContext(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok && caller != ""
+}
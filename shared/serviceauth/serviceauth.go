@@ -0,0 +1,298 @@
+package serviceauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// HeaderServiceToken 服务间调用携带令牌的请求头
+const HeaderServiceToken = "X-Service-Token"
+
+// AnyEndpoint 允许调用方访问所有接口
+const AnyEndpoint = "*"
+
+// tokenTTL 令牌有效期，每个请求单独签发，只需覆盖传输和重试的时间
+const tokenTTL = 30 * time.Second
+
+// clockSkew 校验时允许的服务器时钟偏差
+const clockSkew = 5 * time.Second
+
+// contextCallerKey gin上下文中保存调用方服务名的键
+const contextCallerKey = "serviceauth.caller"
+
+var (
+	// ErrMissingToken 请求没有携带服务令牌
+	ErrMissingToken = errors.New("missing service token")
+	// ErrInvalidToken 令牌签名、有效期或绑定的请求不匹配
+	ErrInvalidToken = errors.New("invalid service token")
+)
+
+// Config 服务间认证配置，每个服务使用自己的密钥签发令牌
+type Config struct {
+	Key     string            `json:"key"`     // 本服务签发令牌的HMAC密钥
+	Trusted map[string]string `json:"trusted"` // 接受其令牌的调用方服务名到其密钥
+}
+
+// claims 服务令牌的声明，令牌绑定目标服务、请求方法和路径，不能用于其他接口
+// 每个令牌带有唯一的jti，只能使用一次
+type claims struct {
+	jwt.RegisteredClaims
+	Method string `json:"mth"`
+	Path   string `json:"pth"`
+}
+
+// DefaultConfig 配置中心没有服务间认证配置时使用的配置，密钥取自环境变量
+// 环境变量未设置时密钥为空，NewSigner 和 NewVerifier 会返回错误，服务拒绝启动
+func DefaultConfig(service string, trusted ...string) Config {
+	cfg := Config{Key: DefaultKey(service), Trusted: make(map[string]string, len(trusted))}
+	for _, caller := range trusted {
+		cfg.Trusted[caller] = DefaultKey(caller)
+	}
+	return cfg
+}
+
+// DefaultKey 从环境变量读取服务的密钥，环境变量名如 SERVICE_AUTH_KEY_WALLET_SERVICE，未设置时返回空字符串
+func DefaultKey(service string) string {
+	return os.Getenv(KeyEnv(service))
+}
+
+// KeyEnv 保存服务密钥的环境变量名
+func KeyEnv(service string) string {
+	return "SERVICE_AUTH_KEY_" + strings.ToUpper(strings.ReplaceAll(service, "-", "_"))
+}
+
+// Signer 为发往其他服务的请求签发令牌
+type Signer struct {
+	service string
+	key     []byte
+}
+
+// NewSigner 创建签发器，service 为本服务在注册中心中的名称
+func NewSigner(service string, cfg Config) (*Signer, error) {
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("service auth key for %s is not configured, set service_auth.key or %s", service, KeyEnv(service))
+	}
+	return &Signer{service: service, key: []byte(cfg.Key)}, nil
+}
+
+// Sign 为请求签发令牌并写入请求头，audience 为目标服务名
+func (s *Signer) Sign(req *http.Request, audience string) error {
+	signed, err := s.Token(audience, req.Method, req.URL.Path)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderServiceToken, signed)
+	return nil
+}
+
+// Token 签发绑定目标服务、请求方法和路径的一次性令牌
+func (s *Signer) Token(audience, method, path string) (string, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate service token id: %v", err)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    s.service,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+		},
+		Method: method,
+		Path:   path,
+	})
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service token: %v", err)
+	}
+	return signed, nil
+}
+
+// newTokenID 生成令牌的jti
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Verifier 校验其他服务的调用
+type Verifier struct {
+	service string
+	keys    map[string][]byte
+	seen    *seenTokens
+}
+
+// NewVerifier 创建校验器，只接受 cfg.Trusted 中的调用方
+func NewVerifier(service string, cfg Config) (*Verifier, error) {
+	if len(cfg.Trusted) == 0 {
+		return nil, fmt.Errorf("no trusted callers configured for %s", service)
+	}
+
+	keys := make(map[string][]byte, len(cfg.Trusted))
+	for caller, key := range cfg.Trusted {
+		if key == "" {
+			return nil, fmt.Errorf("service auth key for caller %s is empty", caller)
+		}
+		keys[caller] = []byte(key)
+	}
+	return &Verifier{service: service, keys: keys, seen: newSeenTokens()}, nil
+}
+
+// Verify 校验请求的令牌，返回调用方服务名
+func (v *Verifier) Verify(req *http.Request) (string, error) {
+	return v.VerifyToken(req.Header.Get(HeaderServiceToken), req.Method, req.URL.Path)
+}
+
+// VerifyToken 校验令牌是否由受信任的调用方为本服务的该方法和路径签发，返回调用方服务名
+// 令牌只能使用一次，已使用过的jti在有效期内再次出现时视为重放
+func (v *Verifier) VerifyToken(tokenString, method, path string) (string, error) {
+	if tokenString == "" {
+		return "", ErrMissingToken
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, func(t *jwt.Token) (interface{}, error) {
+		// 按签发方选择密钥，未信任的服务没有密钥
+		key, ok := v.keys[c.Issuer]
+		if !ok {
+			return nil, fmt.Errorf("untrusted caller %q", c.Issuer)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(v.service),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if c.Method != method || c.Path != path {
+		return "", fmt.Errorf("%w: token issued for %s %s", ErrInvalidToken, c.Method, c.Path)
+	}
+	if c.ID == "" {
+		return "", fmt.Errorf("%w: missing token id", ErrInvalidToken)
+	}
+	if !v.seen.add(c.Issuer+"/"+c.ID, c.ExpiresAt.Time.Add(clockSkew)) {
+		return "", fmt.Errorf("%w: token %s already used", ErrInvalidToken, c.ID)
+	}
+	return c.Issuer, nil
+}
+
+// seenTokens 记录有效期内已使用的令牌，过期后令牌本身不再有效，可以清理
+// 只在进程内记录，同一服务的多个实例之间不共享；令牌有效期很短，重放窗口有限
+type seenTokens struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	lastPrune time.Time
+}
+
+// newSeenTokens 创建已使用令牌集合
+func newSeenTokens() *seenTokens {
+	return &seenTokens{expires: make(map[string]time.Time), lastPrune: time.Now()}
+}
+
+// add 记录令牌，令牌已使用过时返回false
+func (s *seenTokens) add(id string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) >= tokenTTL {
+		for key, exp := range s.expires {
+			if now.After(exp) {
+				delete(s.expires, key)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	if _, ok := s.expires[id]; ok {
+		return false
+	}
+	s.expires[id] = expiresAt
+	return true
+}
+
+// Require 要求请求携带有效的服务令牌，且调用方被ACL允许访问当前接口
+// 需要注册在路由组上，ACL按gin的路由模板匹配
+func (v *Verifier) Require(acl *ACL) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller, err := v.Verify(c.Request)
+		if err != nil {
+			message := "invalid service token"
+			if errors.Is(err, ErrMissingToken) {
+				message = "service authentication required"
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+			return
+		}
+
+		if !acl.Allowed(caller, c.Request.Method, c.FullPath()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "caller " + caller + " is not allowed to access this endpoint"})
+			return
+		}
+
+		c.Set(contextCallerKey, caller)
+		c.Next()
+	}
+}
+
+// CallerFromContext 返回通过认证的调用方服务名
+func CallerFromContext(c *gin.Context) (string, bool) {
+	caller := c.GetString(contextCallerKey)
+	return caller, caller != ""
+}
+
+// ACL 服务声明的访问控制表，调用方只能访问为其列出的接口
+type ACL struct {
+	rules map[string]map[string]bool // 调用方 -> "METHOD 路由模板"
+}
+
+// NewACL 创建空的访问控制表，未列出的调用方不能访问任何接口
+func NewACL() *ACL {
+	return &ACL{rules: make(map[string]map[string]bool)}
+}
+
+// Allow 允许调用方访问接口，接口格式为 "POST /api/v1/wallets/:user_id/deduct"，AnyEndpoint 表示所有接口
+func (a *ACL) Allow(caller string, endpoints ...string) *ACL {
+	if a.rules[caller] == nil {
+		a.rules[caller] = make(map[string]bool)
+	}
+	for _, endpoint := range endpoints {
+		a.rules[caller][normalizeEndpoint(endpoint)] = true
+	}
+	return a
+}
+
+// Allowed 判断调用方是否可以访问接口，route 为gin的路由模板
+func (a *ACL) Allowed(caller, method, route string) bool {
+	endpoints := a.rules[caller]
+	return endpoints[AnyEndpoint] || endpoints[strings.ToUpper(method)+" "+route]
+}
+
+// normalizeEndpoint 方法统一为大写
+func normalizeEndpoint(endpoint string) string {
+	method, path, ok := strings.Cut(strings.TrimSpace(endpoint), " ")
+	if !ok {
+		return endpoint
+	}
+	return strings.ToUpper(method) + " " + strings.TrimSpace(path)
+}
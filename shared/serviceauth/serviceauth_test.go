@@ -0,0 +1,208 @@
+package serviceauth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	gatewayKey = "gateway-test-key"
+	shopKey    = "shop-test-key"
+)
+
+// signClaims 用指定密钥签发任意声明，构造签发器不会产生的令牌
+func signClaims(t *testing.T, key string, c claims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// validClaims api-gateway 为 wallet-service 的 GET /api/v1/wallets 签发的声明
+func validClaims(id string) claims {
+	now := time.Now()
+	return claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    "api-gateway",
+			Audience:  jwt.ClaimStrings{"wallet-service"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+		},
+		Method: "GET",
+		Path:   "/api/v1/wallets",
+	}
+}
+
+func newTestVerifier(t *testing.T) *Verifier {
+	t.Helper()
+	v, err := NewVerifier("wallet-service", Config{Trusted: map[string]string{"api-gateway": gatewayKey, "shop-service": shopKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestSign(t *testing.T) {
+	signer, err := NewSigner("api-gateway", Config{Key: gatewayKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := newTestVerifier(t)
+
+	req := httptest.NewRequest("GET", "/api/v1/wallets?page=2", nil)
+	if err := signer.Sign(req, "wallet-service"); err != nil {
+		t.Fatal(err)
+	}
+	caller, err := verifier.Verify(req)
+	if err != nil || caller != "api-gateway" {
+		t.Fatalf("Verify() = %q, %v, want api-gateway", caller, err)
+	}
+
+	// 每次签发的jti不同
+	first, _ := signer.Token("wallet-service", "GET", "/")
+	second, _ := signer.Token("wallet-service", "GET", "/")
+	if first == second {
+		t.Error("Token() issued identical tokens")
+	}
+}
+
+func TestNewSignerRequiresKey(t *testing.T) {
+	t.Setenv(KeyEnv("api-gateway"), "")
+	if _, err := NewSigner("api-gateway", DefaultConfig("api-gateway")); err == nil {
+		t.Error("NewSigner() accepted an empty key")
+	}
+	if _, err := NewVerifier("wallet-service", DefaultConfig("wallet-service", "api-gateway")); err == nil {
+		t.Error("NewVerifier() accepted an empty caller key")
+	}
+	if _, err := NewVerifier("wallet-service", Config{}); err == nil {
+		t.Error("NewVerifier() accepted no trusted callers")
+	}
+
+	t.Setenv(KeyEnv("api-gateway"), gatewayKey)
+	if _, err := NewSigner("api-gateway", DefaultConfig("api-gateway")); err != nil {
+		t.Errorf("NewSigner() with %s set: %v", KeyEnv("api-gateway"), err)
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	expired := validClaims("expired")
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-clockSkew - time.Second))
+
+	noExpiry := validClaims("no-expiry")
+	noExpiry.ExpiresAt = nil
+
+	otherAudience := validClaims("other-audience")
+	otherAudience.Audience = jwt.ClaimStrings{"shop-service"}
+
+	untrusted := validClaims("untrusted")
+	untrusted.Issuer = "comment-service"
+
+	shop := validClaims("shop")
+	shop.Issuer = "shop-service"
+
+	tests := []struct {
+		name    string
+		token   string
+		method  string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{"valid", signClaims(t, gatewayKey, validClaims("valid")), "GET", "/api/v1/wallets", "api-gateway", nil},
+		{"other trusted caller", signClaims(t, shopKey, shop), "GET", "/api/v1/wallets", "shop-service", nil},
+		{"missing token", "", "GET", "/api/v1/wallets", "", ErrMissingToken},
+		{"malformed", "not-a-token", "GET", "/api/v1/wallets", "", ErrInvalidToken},
+		{"wrong key", signClaims(t, shopKey, validClaims("wrong-key")), "GET", "/api/v1/wallets", "", ErrInvalidToken},
+		{"untrusted caller", signClaims(t, gatewayKey, untrusted), "GET", "/api/v1/wallets", "", ErrInvalidToken},
+		{"other audience", signClaims(t, gatewayKey, otherAudience), "GET", "/api/v1/wallets", "", ErrInvalidToken},
+		{"other method", signClaims(t, gatewayKey, validClaims("other-method")), "POST", "/api/v1/wallets", "", ErrInvalidToken},
+		{"other path", signClaims(t, gatewayKey, validClaims("other-path")), "GET", "/api/v1/wallets/1", "", ErrInvalidToken},
+		{"expired", signClaims(t, gatewayKey, expired), "GET", "/api/v1/wallets", "", ErrInvalidToken},
+		{"missing expiry", signClaims(t, gatewayKey, noExpiry), "GET", "/api/v1/wallets", "", ErrInvalidToken},
+		{"missing jti", signClaims(t, gatewayKey, validClaims("")), "GET", "/api/v1/wallets", "", ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller, err := newTestVerifier(t).VerifyToken(tt.token, tt.method, tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if caller != tt.want {
+				t.Errorf("VerifyToken() = %q, want %q", caller, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenRejectsReplay(t *testing.T) {
+	verifier := newTestVerifier(t)
+	token := signClaims(t, gatewayKey, validClaims("once"))
+
+	if _, err := verifier.VerifyToken(token, "GET", "/api/v1/wallets"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := verifier.VerifyToken(token, "GET", "/api/v1/wallets"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("replay error = %v, want ErrInvalidToken", err)
+	}
+
+	// 不同调用方可以使用相同的jti
+	shop := validClaims("once")
+	shop.Issuer = "shop-service"
+	if _, err := verifier.VerifyToken(signClaims(t, shopKey, shop), "GET", "/api/v1/wallets"); err != nil {
+		t.Errorf("same jti from another caller: %v", err)
+	}
+}
+
+func TestSeenTokensPrune(t *testing.T) {
+	s := newSeenTokens()
+	s.add("expired", time.Now().Add(-time.Second))
+	s.add("live", time.Now().Add(time.Minute))
+	s.lastPrune = time.Now().Add(-tokenTTL)
+
+	if !s.add("new", time.Now().Add(time.Minute)) {
+		t.Fatal("add() rejected a new token")
+	}
+	if _, ok := s.expires["expired"]; ok {
+		t.Error("expired token was not pruned")
+	}
+	if s.add("live", time.Now().Add(time.Minute)) {
+		t.Error("add() accepted a token still within its lifetime")
+	}
+}
+
+func TestACLAllowed(t *testing.T) {
+	acl := NewACL().
+		Allow("shop-service", "post /api/v1/wallets/:user_id/deduct", " GET  /api/v1/wallets/:user_id ").
+		Allow("api-gateway", AnyEndpoint)
+
+	tests := []struct {
+		caller, method, route string
+		want                  bool
+	}{
+		{"shop-service", "POST", "/api/v1/wallets/:user_id/deduct", true},
+		{"shop-service", "post", "/api/v1/wallets/:user_id/deduct", true},
+		{"shop-service", "GET", "/api/v1/wallets/:user_id", true},
+		{"shop-service", "DELETE", "/api/v1/wallets/:user_id", false},
+		{"shop-service", "POST", "/api/v1/wallets/:user_id/recharge", false},
+		{"api-gateway", "DELETE", "/api/v1/wallets/:user_id", true},
+		{"comment-service", "GET", "/api/v1/wallets/:user_id", false},
+		{"", "GET", "/api/v1/wallets/:user_id", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.caller+" "+tt.method+" "+tt.route, func(t *testing.T) {
+			if got := acl.Allowed(tt.caller, tt.method, tt.route); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
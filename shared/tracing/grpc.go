@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataCarrier 以gRPC metadata作为链路信息的载体，键统一为小写
+type metadataCarrier metadata.MD
+
+// Get 返回键的第一个值
+func (m metadataCarrier) Get(key string) string {
+	if values := metadata.MD(m).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set 设置键的值
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+// UnaryClientInterceptor 把context中的链路信息写入gRPC调用的metadata
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		Inject(ctx, metadataCarrier(md))
+		return invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor 从metadata恢复或生成链路信息，并输出带链路字段的调用日志
+// 相当于HTTP服务的Middleware和Logger
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = NewContext(ctx, Extract(metadataCarrier(md)))
+
+		resp, err := handler(ctx, req)
+
+		Printf(ctx, "gRPC %s %s %v", info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 链路信息使用的头，HTTP请求和Kafka消息共用
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// maxRequestIDLength 接受的外部请求ID最大长度
+const maxRequestIDLength = 128
+
+// Carrier 链路信息的载体，http.Header 直接实现了该接口
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// Span 当前处理单元的链路信息
+//
+// RequestID 在网关生成后原样传递；TraceID 在整条链路中不变；
+// 每个服务收到请求或消息时生成新的 SpanID，并把上游的 SpanID 记为 ParentID
+type Span struct {
+	RequestID string
+	TraceID   string
+	SpanID    string
+	ParentID  string
+	Flags     string
+	State     string
+}
+
+// Traceparent 按W3C Trace Context格式输出，下游以当前SpanID作为父节点
+func (s Span) Traceparent() string {
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + s.Flags
+}
+
+// String 日志中输出的链路字段
+func (s Span) String() string {
+	return "request_id=" + s.RequestID + " trace_id=" + s.TraceID + " span_id=" + s.SpanID
+}
+
+// Extract 从载体中恢复链路信息并开启新的Span
+// 请求ID或traceparent缺失、格式不合法时重新生成
+func Extract(carrier Carrier) Span {
+	span := Span{
+		RequestID: carrier.Get(HeaderRequestID),
+		SpanID:    randomHex(8),
+		Flags:     "01",
+	}
+	if !validRequestID(span.RequestID) {
+		span.RequestID = randomHex(16)
+	}
+
+	if traceID, parentID, flags, ok := parseTraceparent(carrier.Get(HeaderTraceparent)); ok {
+		span.TraceID, span.ParentID, span.Flags = traceID, parentID, flags
+		span.State = carrier.Get(HeaderTracestate)
+	} else {
+		span.TraceID = randomHex(16)
+	}
+	return span
+}
+
+// Inject 把context中的链路信息写入载体，没有链路信息时不写入
+func Inject(ctx context.Context, carrier Carrier) {
+	span, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	carrier.Set(HeaderRequestID, span.RequestID)
+	carrier.Set(HeaderTraceparent, span.Traceparent())
+	if span.State != "" {
+		carrier.Set(HeaderTracestate, span.State)
+	}
+}
+
+// spanKey context中保存链路信息的键
+type spanKey struct{}
+
+// NewContext 返回携带链路信息的context
+func NewContext(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// FromContext 读取context中的链路信息
+func FromContext(ctx context.Context) (Span, bool) {
+	if ctx == nil {
+		return Span{}, false
+	}
+	span, ok := ctx.Value(spanKey{}).(Span)
+	return span, ok
+}
+
+// Detach 返回只保留链路信息的新context，用于请求结束后仍需执行的异步任务
+func Detach(ctx context.Context) context.Context {
+	span, ok := FromContext(ctx)
+	if !ok {
+		return context.Background()
+	}
+	return NewContext(context.Background(), span)
+}
+
+// Printf 输出带链路字段的日志，context中没有链路信息时与log.Printf相同
+func Printf(ctx context.Context, format string, v ...interface{}) {
+	if span, ok := FromContext(ctx); ok {
+		log.Printf("["+span.String()+"] "+format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// Middleware 恢复或生成请求的链路信息，写入请求context，并在响应头中返回请求ID
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := Extract(c.Request.Header)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), span))
+		c.Header(HeaderRequestID, span.RequestID)
+		c.Next()
+	}
+}
+
+// Logger 带链路字段的访问日志，替代gin.Logger，需要注册在Middleware之后
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+
+		c.Next()
+
+		Printf(c.Request.Context(), "%s %s %d %v %s", c.Request.Method, path, c.Writer.Status(), time.Since(start), c.ClientIP())
+	}
+}
+
+// parseTraceparent 解析traceparent，返回trace-id、parent-id和trace-flags
+// 只接受版本00；全零的trace-id和parent-id不合法
+func parseTraceparent(value string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", "", "", false
+	}
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) {
+		return "", "", "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", "", "", false
+	}
+	return traceID, parentID, flags, true
+}
+
+// isHex 判断是否为指定长度的小写十六进制字符串
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRequestID 外部传入的请求ID只允许字母、数字和 -_.:，防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.IndexByte("-_.:", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex 生成n字节随机数的十六进制表示
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// 随机数不可用时退化为时间戳，保证ID非空
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
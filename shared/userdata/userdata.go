@@ -0,0 +1,123 @@
+package userdata
+
+import (
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"gorm.io/gorm"
+)
+
+// ExportFunc 收集本服务保存的用户数据，结果序列化为JSON后放入导出文件
+type ExportFunc func(ctx context.Context, userID uint) (interface{}, error)
+
+// EraseFunc 匿名化或删除本服务保存的用户数据
+// 同一请求在用户服务未收到结果时会重新发送，需要可以重复执行
+type EraseFunc func(ctx context.Context, userID uint) error
+
+// Store 导出数据的存储，各服务和用户服务共用数据库中的 user_data_exports 表
+// 导出的数据可能超过Kafka的消息大小限制，只在处理结果中传递导出的ID
+type Store struct {
+	db *gorm.DB
+}
+
+// NewStore 创建导出数据的存储，表不存在时创建
+func NewStore(db *gorm.DB) (*Store, error) {
+	if err := db.AutoMigrate(&models.UserDataExport{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate user data exports: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Save 保存服务为请求导出的数据，返回导出的ID；同一请求重新发送时覆盖之前的数据
+func (s *Store) Save(requestID uint, service string, data []byte) (uint, error) {
+	export := models.UserDataExport{RequestID: requestID, Service: service}
+	err := s.db.Where("request_id = ? AND service = ?", requestID, service).
+		Assign(models.UserDataExport{Data: string(data)}).
+		FirstOrCreate(&export).Error
+	if err != nil {
+		return 0, err
+	}
+	return export.ID, nil
+}
+
+// Get 按ID获取导出的数据
+func (s *Store) Get(ids []uint) ([]models.UserDataExport, error) {
+	var exports []models.UserDataExport
+	if len(ids) == 0 {
+		return exports, nil
+	}
+	err := s.db.Where("id IN ?", ids).Order("service").Find(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+// Handler 处理 user.export.requested 和 user.deleted 事件，处理结果发送到 user.data.progress
+// 导出的数据写入store，结果中只带导出的ID；处理失败时同样发送结果，用户服务记录失败原因并在重试间隔后重新发送请求
+// 结果发送失败时返回错误，由消费者组重试
+func Handler(service string, producer *kafka.Producer, store *Store, export ExportFunc, erase EraseFunc) kafka.Handler {
+	return func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		var event models.UserDataRequestEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return fmt.Errorf("failed to unmarshal data request event: %v", err)
+		}
+
+		progress := &models.UserDataProgressEvent{
+			RequestID: event.RequestID,
+			UserID:    event.UserID,
+			Service:   service,
+			Status:    models.DataRequestCompleted,
+		}
+		var err error
+		switch msg.Topic {
+		case kafka.TopicUserExportRequest:
+			progress.Type = models.DataRequestExport
+			progress.ExportID, err = exportData(ctx, service, store, export, &event)
+		case kafka.TopicUserDeleted:
+			progress.Type = models.DataRequestDeletion
+			err = erase(ctx, event.UserID)
+		default:
+			return fmt.Errorf("unexpected data request topic: %s", msg.Topic)
+		}
+		if err != nil {
+			progress.Status = models.DataRequestFailed
+			progress.Error = err.Error()
+			progress.ExportID = 0
+		}
+		tracing.Printf(ctx, "Processed %s request %d for user %d: %s", progress.Type, event.RequestID, event.UserID, progress.Status)
+
+		err = producer.SendMessage(ctx, kafka.TopicUserDataProgress, fmt.Sprintf("%d", event.RequestID), progress)
+		if err != nil {
+			return fmt.Errorf("failed to report %s request %d: %v", progress.Type, event.RequestID, err)
+		}
+		return nil
+	}
+}
+
+// exportData 收集用户数据并写入store，返回导出的ID
+func exportData(ctx context.Context, service string, store *Store, export ExportFunc, event *models.UserDataRequestEvent) (uint, error) {
+	data, err := export(ctx, event.UserID)
+	if err != nil {
+		return 0, err
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal user data: %v", err)
+	}
+	id, err := store.Save(event.RequestID, service, encoded)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save user data export: %v", err)
+	}
+	return id, nil
+}
+
+// Subscribe 以消费者组订阅个人数据导出和注销事件，同一服务的多个副本中只有一个处理同一请求
+func Subscribe(group *kafka.ConsumerGroup, handler kafka.Handler) {
+	group.Consume([]string{kafka.TopicUserExportRequest, kafka.TopicUserDeleted}, handler)
+}
@@ -0,0 +1,64 @@
+package userdata
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "exports.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	store, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestStoreSave(t *testing.T) {
+	store := newTestStore(t)
+
+	first, err := store.Save(1, "wallet-service", []byte(`{"transactions":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 请求重新发送时覆盖之前的数据，导出的ID不变
+	again, err := store.Save(1, "wallet-service", []byte(`{"transactions":[1]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("Save() again = %d, want %d", again, first)
+	}
+	other, err := store.Save(1, "comment-service", []byte(`{"comments":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exports, err := store.Get([]uint{first, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exports) != 2 {
+		t.Fatalf("Get() returned %d exports, want 2", len(exports))
+	}
+	if exports[1].Service != "wallet-service" || exports[1].Data != `{"transactions":[1]}` {
+		t.Errorf("Get()[1] = %s %s, want the overwritten wallet-service export", exports[1].Service, exports[1].Data)
+	}
+
+	if exports, err := store.Get(nil); err != nil || len(exports) != 0 {
+		t.Errorf("Get(nil) = %v, %v, want no exports", exports, err)
+	}
+}
@@ -0,0 +1,196 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Dearlimg/Goutils/pkg/app"
+	"github.com/Dearlimg/Goutils/pkg/app/errcode"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 字段错误所在的位置
+const (
+	InBody   = "body"
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// FieldError 字段级的校验错误，服务和网关返回相同的结构
+type FieldError struct {
+	Field   string `json:"field"` // 使用JSON字段名，嵌套字段如 items[0].quantity；整个请求体出错时为空
+	In      string `json:"in"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var registerOnce sync.Once
+
+// BindJSON 解析并校验JSON请求体，按结构体的 binding 标签校验
+// 失败时返回参数错误和字段级错误列表，调用方直接返回即可
+func BindJSON(c *gin.Context, obj interface{}) bool {
+	registerOnce.Do(useJSONFieldNames)
+
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	fields := FieldErrors(err)
+	details := make([]string, 0, len(fields))
+	for _, field := range fields {
+		details = append(details, field.Message)
+	}
+	c.JSON(http.StatusOK, app.State{
+		Code: errcode.ErrParamsNotValid.ECode(),
+		Msg:  errcode.ErrParamsNotValid.WithDetails(details...).Error(),
+		Data: gin.H{"fields": fields},
+	})
+	return false
+}
+
+// FieldErrors 把解析或校验错误转换为字段错误
+func FieldErrors(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			field := fieldPath(fe.Namespace())
+			fields = append(fields, FieldError{
+				Field:   field,
+				In:      InBody,
+				Rule:    fe.Tag(),
+				Message: ruleMessage(field, fe),
+			})
+		}
+		return fields
+	case errors.As(err, &typeErr):
+		return []FieldError{{
+			Field:   typeErr.Field,
+			In:      InBody,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be %s", typeErr.Field, typeName(typeErr.Type)),
+		}}
+	case errors.As(err, &syntaxErr):
+		return []FieldError{{
+			In:      InBody,
+			Rule:    "json",
+			Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset),
+		}}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return []FieldError{{
+			In:      InBody,
+			Rule:    "json",
+			Message: "malformed JSON: unexpected end of input",
+		}}
+	case errors.Is(err, io.EOF):
+		return []FieldError{{
+			In:      InBody,
+			Rule:    "required",
+			Message: "request body is required",
+		}}
+	default:
+		return []FieldError{{
+			In:      InBody,
+			Rule:    "json",
+			Message: err.Error(),
+		}}
+	}
+}
+
+// useJSONFieldNames 校验错误使用JSON字段名，与客户端提交的字段一致
+func useJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+}
+
+// fieldPath 去掉命名空间开头的结构体名，CreateOrderRequest.items[0].quantity 变为 items[0].quantity
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// ruleMessage 常用规则的错误描述
+func ruleMessage(field string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_unless", "required_if", "required_with":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fe.Param())
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, fe.Param())
+	case "startswith":
+		return fmt.Sprintf("%s must start with %s", field, fe.Param())
+	case "min", "max", "len":
+		return lengthMessage(field, fe)
+	default:
+		return fmt.Sprintf("%s failed on the '%s' rule", field, fe.Tag())
+	}
+}
+
+// lengthMessage min/max/len 对字符串是字符数，对列表是元素个数，对数字是取值
+func lengthMessage(field string, fe validator.FieldError) string {
+	bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[fe.Tag()]
+	switch fe.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%s must be %s %s characters long", field, bound, fe.Param())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("%s must contain %s %s items", field, bound, fe.Param())
+	default:
+		return fmt.Sprintf("%s must be %s %s", field, bound, fe.Param())
+	}
+}
+
+// typeName JSON中对应的类型名
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
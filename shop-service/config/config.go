@@ -2,6 +2,7 @@ package config
 
 import (
 	"blog/shared/config"
+	"blog/shared/serviceauth"
 	"encoding/json"
 	"log"
 	"os"
@@ -9,10 +10,12 @@ import (
 
 // Config 商城服务配置
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Redis    RedisConfig    `json:"redis"`
-	Wallet   WalletConfig   `json:"wallet"`
+	Server      ServerConfig       `json:"server"`
+	Database    DatabaseConfig     `json:"database"`
+	Redis       RedisConfig        `json:"redis"`
+	Wallet      WalletConfig       `json:"wallet"`
+	Kafka       KafkaConfig        `json:"kafka"`
+	ServiceAuth serviceauth.Config `json:"service_auth"`
 }
 
 // ServerConfig 服务器配置
@@ -34,8 +37,14 @@ type RedisConfig struct {
 
 // WalletConfig 钱包服务配置
 type WalletConfig struct {
-	Host string `json:"host"`
-	Port string `json:"port"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	GRPCPort string `json:"grpc_port"` // 钱包服务内部gRPC接口端口
+}
+
+// KafkaConfig Kafka配置
+type KafkaConfig struct {
+	Brokers []string `json:"brokers"`
 }
 
 // LoadConfig 从Redis配置中心加载配置
@@ -68,6 +77,16 @@ func LoadConfig() *Config {
 		return LoadDefaultConfig()
 	}
 
+	// 配置中心未配置钱包服务gRPC端口时使用默认端口
+	if cfg.Wallet.GRPCPort == "" {
+		cfg.Wallet.GRPCPort = "9002"
+	}
+
+	// 配置中心未配置服务密钥时使用默认密钥
+	if cfg.ServiceAuth.Key == "" || len(cfg.ServiceAuth.Trusted) == 0 {
+		cfg.ServiceAuth = serviceauth.DefaultConfig("shop-service", "api-gateway")
+	}
+
 	log.Printf("Config loaded from Redis config center for shop-service")
 	return &cfg
 }
@@ -110,6 +129,21 @@ func LoadDefaultConfig() *Config {
 		walletPort = "8002"
 	}
 
+	walletGRPCPort := os.Getenv("WALLET_SERVICE_GRPC_PORT")
+	if walletGRPCPort == "" {
+		walletGRPCPort = "9002"
+	}
+
+	kafkaHost := os.Getenv("KAFKA_HOST")
+	if kafkaHost == "" {
+		kafkaHost = "kafka"
+	}
+
+	kafkaPort := os.Getenv("KAFKA_PORT")
+	if kafkaPort == "" {
+		kafkaPort = "9092"
+	}
+
 	return &Config{
 		Server: ServerConfig{
 			Port: port,
@@ -123,8 +157,13 @@ func LoadDefaultConfig() *Config {
 			DB:       0,
 		},
 		Wallet: WalletConfig{
-			Host: walletHost,
-			Port: walletPort,
+			Host:     walletHost,
+			Port:     walletPort,
+			GRPCPort: walletGRPCPort,
+		},
+		Kafka: KafkaConfig{
+			Brokers: []string{kafkaHost + ":" + kafkaPort},
 		},
+		ServiceAuth: serviceauth.DefaultConfig("shop-service", "api-gateway"),
 	}
 }
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"blog/shared/auth"
+	"blog/shared/validation"
 	"blog/shop-service/logic"
 	"blog/shop-service/models"
 	"strconv"
@@ -47,12 +49,11 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req models.ProductCreateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	product, err := pc.productLogic.CreateProduct(&req)
+	product, err := pc.productLogic.CreateProduct(c.Request.Context(), &req)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -115,12 +116,11 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 	}
 
 	var req models.ProductUpdateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	product, err := pc.productLogic.UpdateProduct(uint(id), &req)
+	product, err := pc.productLogic.UpdateProduct(c.Request.Context(), uint(id), &req)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -140,7 +140,7 @@ func (pc *ProductController) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	err = pc.productLogic.DeleteProduct(uint(id))
+	err = pc.productLogic.DeleteProduct(c.Request.Context(), uint(id))
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -156,12 +156,11 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req models.CreateOrderRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	order, err := oc.orderLogic.CreateOrder(&req)
+	order, err := oc.orderLogic.CreateOrder(c.Request.Context(), &req)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -187,6 +186,11 @@ func (oc *OrderController) GetOrder(c *gin.Context) {
 		return
 	}
 
+	if !auth.CanAccess(c, order.UserID) {
+		auth.AbortForbidden(c)
+		return
+	}
+
 	rly.Reply(nil, order)
 }
 
@@ -221,7 +225,18 @@ func (oc *OrderController) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	err = oc.orderLogic.CancelOrder(uint(id))
+	order, err := oc.orderLogic.GetOrder(uint(id))
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	if !auth.CanAccess(c, order.UserID) {
+		auth.AbortForbidden(c)
+		return
+	}
+
+	err = oc.orderLogic.CancelOrder(c.Request.Context(), uint(id))
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -237,8 +252,7 @@ func (cc *CartController) AddToCart(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req models.AddToCartRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
@@ -291,11 +305,10 @@ func (cc *CartController) UpdateCartItem(c *gin.Context) {
 	}
 
 	var req struct {
-		Quantity int `json:"quantity" validate:"required,gt=0"`
+		Quantity int `json:"quantity" binding:"required,gt=0"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
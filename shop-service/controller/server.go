@@ -1,55 +1,64 @@
 package controller
 
 import (
+	"blog/shared/auth"
+	"blog/shared/health"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
 // Server HTTP服务器
 type Server struct {
-	router *gin.Engine
-	port   string
+	router     *gin.Engine
+	port       string
+	httpServer *http.Server
 }
 
 // NewServer 创建HTTP服务器
-func NewServer(port string, productController *ProductController, orderController *OrderController, cartController *CartController) *Server {
+func NewServer(port string, productController *ProductController, orderController *OrderController, cartController *CartController, verifier *serviceauth.Verifier, healthChecker *health.Checker) *Server {
 	router := gin.New()
-	router.Use(gin.Recovery(), gin.Logger())
+	router.Use(gin.Recovery(), tracing.Middleware(), tracing.Logger())
 
 	// 健康检查路由
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "healthy", "service": "shop-service"})
-	})
+	healthChecker.RegisterRoutes(router)
 
-	// API路由
+	// API路由，只接受网关转发的请求；未验证邮箱的用户只能浏览
 	api := router.Group("/api/v1")
+	api.Use(verifier.Require(callerACL()), auth.Middleware(), auth.RequireVerifiedWrites())
 	{
-		// 商品相关路由
+		// 商品相关路由，创建、修改和删除商品需要商品管理权限
 		products := api.Group("/products")
 		{
-			products.POST("", productController.CreateProduct)
+			products.POST("", auth.RequirePermission(auth.PermProductsWrite), productController.CreateProduct)
 			products.GET("", productController.GetProducts)
 			products.GET("/:id", productController.GetProduct)
-			products.PUT("/:id", productController.UpdateProduct)
-			products.DELETE("/:id", productController.DeleteProduct)
+			products.PUT("/:id", auth.RequirePermission(auth.PermProductsWrite), productController.UpdateProduct)
+			products.DELETE("/:id", auth.RequirePermission(auth.PermProductsWrite), productController.DeleteProduct)
 		}
 
-		// 订单相关路由
+		// 订单相关路由（订单归属在控制器中校验）
 		orders := api.Group("/orders")
 		{
-			orders.POST("", orderController.CreateOrder)
-			orders.GET("/:id", orderController.GetOrder)
-			orders.PUT("/:id/cancel", orderController.CancelOrder)
+			orders.POST("", auth.RequireSelfInBody("user_id"), orderController.CreateOrder)
+			orders.GET("/:id", auth.RequireUser(), orderController.GetOrder)
+			orders.PUT("/:id/cancel", auth.RequireUser(), orderController.CancelOrder)
 		}
 
-		ordersUser := api.Group("/users/:user_id/orders")
+		ordersUser := api.Group("/users/:user_id/orders", auth.RequireSelf("user_id"))
 		{
 			ordersUser.GET("", orderController.GetUserOrders)
 		}
 
 		// 购物车相关路由
-		cart := api.Group("/users/:user_id/cart")
+		cart := api.Group("/users/:user_id/cart", auth.RequireSelf("user_id"))
 		{
-			cart.POST("", cartController.AddToCart)
+			cart.POST("", auth.RequireSelfInBody("user_id"), cartController.AddToCart)
 			cart.GET("", cartController.GetCart)
 			cart.PUT("/:product_id", cartController.UpdateCartItem)
 			cart.DELETE("/:product_id", cartController.RemoveFromCart)
@@ -60,15 +69,32 @@ func NewServer(port string, productController *ProductController, orderControlle
 	return &Server{
 		router: router,
 		port:   port,
+		httpServer: &http.Server{
+			Addr:              ":" + port,
+			Handler:           router,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
 	}
 }
 
+// callerACL 各调用方可以访问的接口
+// 所有接口都由网关转发外部请求，其他服务不直接调用
+func callerACL() *serviceauth.ACL {
+	return serviceauth.NewACL().
+		Allow("api-gateway", serviceauth.AnyEndpoint)
+}
+
 // Start 启动服务器
+// 调用Shutdown后返回nil
 func (s *Server) Start() error {
-	return s.router.Run(":" + s.port)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Shutdown 关闭服务器
-func (s *Server) Shutdown() {
-	// 这里可以添加优雅关闭逻辑
+// Shutdown 优雅关闭：停止接受新连接，等待处理中的请求完成，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
@@ -1,18 +1,31 @@
 package logic
 
 import (
+	"blog/shared/kafka"
+	sharedmodels "blog/shared/models"
+	walletpb "blog/shared/proto/wallet"
+	"blog/shared/tracing"
 	"blog/shop-service/models"
 	"blog/shop-service/repository"
-	"bytes"
-	"encoding/json"
+	"blog/shop-service/wallet"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// ErrInsufficientBalance 钱包余额不足以支付订单
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// errPaymentUncertain 调用钱包服务超时或连接中断，钱包服务可能已经扣款
+var errPaymentUncertain = errors.New("payment outcome unknown")
+
 // ProductLogic 商品业务逻辑
 type ProductLogic struct {
 	productRepo repository.ProductRepository
+	producer    *kafka.Producer
 }
 
 // OrderLogic 订单业务逻辑
@@ -20,7 +33,8 @@ type OrderLogic struct {
 	productRepo repository.ProductRepository
 	orderRepo   repository.OrderRepository
 	cartRepo    repository.CartRepository
-	walletURL   string
+	wallet      *wallet.Client
+	producer    *kafka.Producer
 }
 
 // CartLogic 购物车业务逻辑
@@ -30,17 +44,18 @@ type CartLogic struct {
 }
 
 // NewProductLogic 创建商品业务逻辑
-func NewProductLogic(productRepo repository.ProductRepository) *ProductLogic {
-	return &ProductLogic{productRepo: productRepo}
+func NewProductLogic(productRepo repository.ProductRepository, producer *kafka.Producer) *ProductLogic {
+	return &ProductLogic{productRepo: productRepo, producer: producer}
 }
 
-// NewOrderLogic 创建订单业务逻辑
-func NewOrderLogic(productRepo repository.ProductRepository, orderRepo repository.OrderRepository, cartRepo repository.CartRepository, walletURL string) *OrderLogic {
+// NewOrderLogic 创建订单业务逻辑，walletClient 用于支付和退款
+func NewOrderLogic(productRepo repository.ProductRepository, orderRepo repository.OrderRepository, cartRepo repository.CartRepository, walletClient *wallet.Client, producer *kafka.Producer) *OrderLogic {
 	return &OrderLogic{
 		productRepo: productRepo,
 		orderRepo:   orderRepo,
 		cartRepo:    cartRepo,
-		walletURL:   walletURL,
+		wallet:      walletClient,
+		producer:    producer,
 	}
 }
 
@@ -52,10 +67,22 @@ func NewCartLogic(cartRepo repository.CartRepository, productRepo repository.Pro
 	}
 }
 
+// publishProductEvent 发送商品事件，发送失败只记录日志
+func publishProductEvent(ctx context.Context, producer *kafka.Producer, topic string, productID uint, action string) {
+	event := &sharedmodels.ProductEvent{
+		ProductID: productID,
+		Action:    action,
+	}
+	err := producer.SendMessage(ctx, topic, fmt.Sprintf("%d", productID), event)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send product event: %v", err)
+	}
+}
+
 // ========== Product Logic ==========
 
 // CreateProduct 创建商品
-func (pl *ProductLogic) CreateProduct(req *models.ProductCreateRequest) (*models.Product, error) {
+func (pl *ProductLogic) CreateProduct(ctx context.Context, req *models.ProductCreateRequest) (*models.Product, error) {
 	product := &models.Product{
 		Name:        req.Name,
 		Description: req.Description,
@@ -71,6 +98,8 @@ func (pl *ProductLogic) CreateProduct(req *models.ProductCreateRequest) (*models
 		return nil, fmt.Errorf("failed to create product: %v", err)
 	}
 
+	publishProductEvent(ctx, pl.producer, kafka.TopicProductCreate, product.ID, "create")
+
 	return product, nil
 }
 
@@ -86,7 +115,7 @@ func (pl *ProductLogic) GetProducts(page, pageSize int, category string) ([]*mod
 }
 
 // UpdateProduct 更新商品
-func (pl *ProductLogic) UpdateProduct(id uint, req *models.ProductUpdateRequest) (*models.Product, error) {
+func (pl *ProductLogic) UpdateProduct(ctx context.Context, id uint, req *models.ProductUpdateRequest) (*models.Product, error) {
 	product, err := pl.productRepo.GetProductByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("product not found: %v", err)
@@ -141,18 +170,26 @@ func (pl *ProductLogic) UpdateProduct(id uint, req *models.ProductUpdateRequest)
 		return nil, fmt.Errorf("failed to update product: %v", err)
 	}
 
+	publishProductEvent(ctx, pl.producer, kafka.TopicProductUpdate, id, "update")
+
 	return pl.productRepo.GetProductByID(id)
 }
 
 // DeleteProduct 删除商品（软删除）
-func (pl *ProductLogic) DeleteProduct(id uint) error {
-	return pl.productRepo.DeleteProduct(id)
+func (pl *ProductLogic) DeleteProduct(ctx context.Context, id uint) error {
+	err := pl.productRepo.DeleteProduct(id)
+	if err != nil {
+		return err
+	}
+
+	publishProductEvent(ctx, pl.producer, kafka.TopicProductDelete, id, "delete")
+	return nil
 }
 
 // ========== Order Logic ==========
 
 // CreateOrder 创建订单并支付
-func (ol *OrderLogic) CreateOrder(req *models.CreateOrderRequest) (*models.Order, error) {
+func (ol *OrderLogic) CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
 	// 计算订单总金额并验证商品
 	var totalAmount float64
 	var orderItems []*models.OrderItem
@@ -252,20 +289,33 @@ func (ol *OrderLogic) CreateOrder(req *models.CreateOrderRequest) (*models.Order
 		}
 	}
 
-	// 调用钱包服务支付
-	err = ol.payOrder(order.ID, req.UserID, totalAmount, orderItems)
+	// 调用钱包服务支付，不随客户端断开而取消，避免钱包已扣款而订单被取消
+	payCtx := tracing.Detach(ctx)
+	err = ol.payOrder(payCtx, order.ID, req.UserID, orderItems)
+	if errors.Is(err, errPaymentUncertain) {
+		// 按订单退款确定结果后再取消；退款也失败时订单保持待支付，用户取消订单时会再次退款
+		if refundErr := ol.refundPendingOrder(payCtx, order.ID, "payment outcome unknown"); refundErr != nil {
+			tracing.Printf(ctx, "Payment of order %d is uncertain and refund failed, order left pending: %v", order.ID, refundErr)
+			return nil, fmt.Errorf("payment failed: %w", err)
+		}
+	}
 	if err != nil {
 		// 支付失败，回滚库存
 		for _, item := range orderItems {
 			ol.productRepo.IncreaseStock(item.ProductID, item.Quantity)
 		}
 		ol.orderRepo.UpdateOrderStatus(order.ID, "cancelled")
-		return nil, fmt.Errorf("payment failed: %v", err)
+		return nil, fmt.Errorf("payment failed: %w", err)
 	}
 
 	// 支付成功，更新订单状态
 	ol.orderRepo.UpdateOrderStatus(order.ID, "paid")
 
+	// 库存已变化
+	for _, item := range orderItems {
+		publishProductEvent(ctx, ol.producer, kafka.TopicProductUpdate, item.ProductID, "update")
+	}
+
 	// 如果使用购物车，清空购物车
 	if req.UseCart {
 		ol.cartRepo.ClearCart(req.UserID)
@@ -274,43 +324,50 @@ func (ol *OrderLogic) CreateOrder(req *models.CreateOrderRequest) (*models.Order
 	return order, nil
 }
 
-// payOrder 调用钱包服务支付订单
-func (ol *OrderLogic) payOrder(orderID, userID uint, amount float64, items []*models.OrderItem) error {
-	// 构建支付请求
-	type PaymentReq struct {
-		UserID    uint    `json:"user_id"`
-		ProductID *uint   `json:"product_id"`
-		Quantity  int     `json:"quantity"`
-		UnitPrice float64 `json:"unit_price"`
-		OrderID   *uint   `json:"order_id"`
-	}
-
-	// 如果有多个商品，需要分别支付（或者可以合并支付，这里简化处理）
-	// 实际项目中，应该一次性支付总金额
-	url := fmt.Sprintf("http://%s/api/v1/wallets/%d/deduct", ol.walletURL, userID)
-
-	// 构建请求体（这里简化，实际应该发送所有商品信息）
-	reqBody := map[string]interface{}{
-		"amount":      amount,
-		"description": fmt.Sprintf("Order #%d payment", orderID),
+// payOrder 调用钱包服务支付订单，钱包服务为每个商品生成一条关联订单的交易
+func (ol *OrderLogic) payOrder(ctx context.Context, orderID, userID uint, items []*models.OrderItem) error {
+	purchaseItems := make([]*walletpb.PurchaseItem, 0, len(items))
+	for _, item := range items {
+		purchaseItems = append(purchaseItems, &walletpb.PurchaseItem{
+			ProductId:   uint64(item.ProductID),
+			Quantity:    int32(item.Quantity),
+			UnitPrice:   item.UnitPrice,
+			ProductName: item.ProductName,
+		})
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	_, err := ol.wallet.Purchase(ctx, userID, orderID, purchaseItems)
 	if err != nil {
-		return err
+		if walletpb.ErrorCodeOf(err) == walletpb.ErrorCode_ERROR_CODE_INSUFFICIENT_FUNDS {
+			return ErrInsufficientBalance
+		}
+		if !paymentRejected(err) {
+			return fmt.Errorf("%w: %v", errPaymentUncertain, err)
+		}
+		return fmt.Errorf("wallet service error: %v", err)
 	}
+	return nil
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewReader(jsonData))
-	if err != nil {
-		return err
+// paymentRejected 钱包服务明确拒绝了支付，没有扣款
+// 业务错误和认证失败在扣款前返回；超时、连接中断等其他错误无法确定是否已扣款
+func paymentRejected(err error) bool {
+	if walletpb.ErrorCodeOf(err) != walletpb.ErrorCode_ERROR_CODE_UNSPECIFIED {
+		return true
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("wallet service error: %s", string(body))
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied:
+		return true
 	}
+	return false
+}
 
+// refundPendingOrder 退回待支付订单可能已完成的付款，钱包服务没有该订单的付款时视为成功
+func (ol *OrderLogic) refundPendingOrder(ctx context.Context, orderID uint, reason string) error {
+	err := ol.wallet.RefundOrder(ctx, orderID, reason)
+	if err != nil && walletpb.ErrorCodeOf(err) != walletpb.ErrorCode_ERROR_CODE_TRANSACTION_NOT_FOUND {
+		return err
+	}
 	return nil
 }
 
@@ -325,7 +382,7 @@ func (ol *OrderLogic) GetUserOrders(userID uint) ([]*models.Order, error) {
 }
 
 // CancelOrder 取消订单
-func (ol *OrderLogic) CancelOrder(orderID uint) error {
+func (ol *OrderLogic) CancelOrder(ctx context.Context, orderID uint) error {
 	order, err := ol.orderRepo.GetOrderByID(orderID)
 	if err != nil {
 		return fmt.Errorf("order not found: %v", err)
@@ -335,6 +392,16 @@ func (ol *OrderLogic) CancelOrder(orderID uint) error {
 		return fmt.Errorf("order cannot be cancelled in status: %s", order.Status)
 	}
 
+	// 已支付的订单先退款；待支付的订单可能支付结果不确定，同样按订单退款
+	// 退款失败时订单保持原状态
+	if order.Status == "paid" {
+		if err := ol.wallet.RefundOrder(ctx, orderID, "order cancelled"); err != nil {
+			return fmt.Errorf("refund failed: %v", err)
+		}
+	} else if err := ol.refundPendingOrder(ctx, orderID, "order cancelled"); err != nil {
+		return fmt.Errorf("refund failed: %v", err)
+	}
+
 	// 恢复库存
 	items, err := ol.orderRepo.GetOrderItemsByOrderID(orderID)
 	if err != nil {
@@ -343,6 +410,7 @@ func (ol *OrderLogic) CancelOrder(orderID uint) error {
 
 	for _, item := range items {
 		ol.productRepo.IncreaseStock(item.ProductID, item.Quantity)
+		publishProductEvent(ctx, ol.producer, kafka.TopicProductUpdate, item.ProductID, "update")
 	}
 
 	// 更新订单状态
@@ -0,0 +1,42 @@
+package logic
+
+import (
+	"blog/shared/tracing"
+	"context"
+	"fmt"
+)
+
+// ExportUserData 导出用户的全部订单、订单项和购物车
+func (ol *OrderLogic) ExportUserData(ctx context.Context, userID uint) (interface{}, error) {
+	orders, err := ol.orderRepo.ListUserOrders(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %v", err)
+	}
+	items, err := ol.orderRepo.ListUserOrderItems(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order items: %v", err)
+	}
+	carts, err := ol.cartRepo.GetCartByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %v", err)
+	}
+	return map[string]interface{}{
+		"orders":      orders,
+		"order_items": items,
+		"cart":        carts,
+	}, nil
+}
+
+// EraseUserData 注销账户时清空订单的收货信息并清空购物车；订单金额和订单项属于账务记录，按用户ID保留
+func (ol *OrderLogic) EraseUserData(ctx context.Context, userID uint) error {
+	affected, err := ol.orderRepo.AnonymizeUserOrders(userID)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize orders: %v", err)
+	}
+	err = ol.cartRepo.ClearCart(userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear cart: %v", err)
+	}
+	tracing.Printf(ctx, "Anonymized %d orders and cleared cart of user %d", affected, userID)
+	return nil
+}
@@ -1,34 +1,82 @@
 package main
 
 import (
+	"blog/shared/database"
+	"blog/shared/health"
+	"blog/shared/kafka"
+	"blog/shared/registry"
+	"blog/shared/serviceauth"
+	"blog/shared/userdata"
 	"blog/shop-service/config"
 	"blog/shop-service/controller"
 	"blog/shop-service/logic"
 	"blog/shop-service/repository"
+	"blog/shop-service/wallet"
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// shutdownTimeout 关闭时等待处理中请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// 初始化配置
 	cfg := config.LoadConfig()
 
 	// 初始化数据库
 	db := repository.InitDB(cfg.Database.DSN)
+	defer database.Close(db)
+
+	// 初始化Kafka生产者（可选，商品变化事件用于网关缓存失效，不可用时网关缓存的商品按TTL过期）
+	producer, err := kafka.NewProducer(cfg.Kafka.Brokers)
+	if err != nil {
+		log.Printf("Failed to create Kafka producer: %v, continuing without product events", err)
+		producer = nil
+	} else {
+		defer producer.Close()
+	}
+
+	// 初始化Kafka消费者组（可选，个人数据导出和账户注销，不可用时由用户服务按间隔重新发送）
+	// 处理完成才提交位移，停机期间发送的请求重启后继续处理
+	dataGroup, err := kafka.NewConsumerGroup(cfg.Kafka.Brokers, "shop-service")
+	if err != nil {
+		log.Printf("Failed to create Kafka consumer group: %v, continuing without user data requests", err)
+		dataGroup = nil
+	} else {
+		defer dataGroup.Close()
+	}
+
+	// 导出的个人数据写入数据库，处理结果中只带导出的ID
+	exportStore, err := userdata.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to init user data exports: %v", err)
+	}
 
 	// 初始化仓库
 	productRepo := repository.NewProductRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
 	cartRepo := repository.NewCartRepository(db)
 
-	// 构建钱包服务URL（用于HTTP调用）
-	walletURL := cfg.Wallet.Host + ":" + cfg.Wallet.Port
+	// 调用钱包服务时使用的服务令牌签发器
+	signer, err := serviceauth.NewSigner("shop-service", cfg.ServiceAuth)
+	if err != nil {
+		log.Fatalf("Invalid service auth config: %v", err)
+	}
+
+	// 钱包服务gRPC客户端（用于支付和退款）
+	walletClient, err := wallet.NewClient(cfg.Wallet.Host+":"+cfg.Wallet.GRPCPort, signer)
+	if err != nil {
+		log.Fatalf("Failed to create wallet client: %v", err)
+	}
+	defer walletClient.Close()
 
 	// 初始化业务逻辑
-	productLogic := logic.NewProductLogic(productRepo)
-	orderLogic := logic.NewOrderLogic(productRepo, orderRepo, cartRepo, walletURL)
+	productLogic := logic.NewProductLogic(productRepo, producer)
+	orderLogic := logic.NewOrderLogic(productRepo, orderRepo, cartRepo, walletClient, producer)
 	cartLogic := logic.NewCartLogic(cartRepo, productRepo)
 
 	// 初始化控制器
@@ -36,8 +84,33 @@ func main() {
 	orderController := controller.NewOrderController(orderLogic)
 	cartController := controller.NewCartController(cartLogic)
 
+	// 就绪检查：MySQL和Kafka生产者
+	healthChecker := health.NewChecker("shop-service")
+	healthChecker.AddCheck("mysql", health.DBCheck(db))
+	if producer != nil {
+		healthChecker.AddCheck("kafka", health.KafkaCheck(producer))
+	}
+
+	// 校验网关的服务令牌
+	verifier, err := serviceauth.NewVerifier("shop-service", cfg.ServiceAuth)
+	if err != nil {
+		log.Fatalf("Invalid service auth config: %v", err)
+	}
+
 	// 启动HTTP服务器
-	server := controller.NewServer(cfg.Server.Port, productController, orderController, cartController)
+	server := controller.NewServer(cfg.Server.Port, productController, orderController, cartController, verifier, healthChecker)
+
+	// 启动Kafka消费者组（如果可用），个人数据请求的处理结果发送给用户服务
+	if dataGroup != nil {
+		userdata.Subscribe(dataGroup, userdata.Handler("shop-service", producer, exportStore, orderLogic.ExportUserData, orderLogic.EraseUserData))
+	}
+
+	// 注册到服务注册中心，供网关发现
+	deregister, err := registry.RegisterLocal(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, "shop-service", cfg.Server.Port)
+	if err != nil {
+		log.Printf("Failed to register service: %v, continuing without service registry", err)
+		deregister = func() {}
+	}
 
 	log.Printf("Shop service starting on port %s", cfg.Server.Port)
 
@@ -54,5 +127,18 @@ func main() {
 	<-quit
 
 	log.Println("Shop service shutting down...")
-	server.Shutdown()
+
+	// 先摘除实例：就绪检查返回503并从注册中心注销，等待网关刷新实例列表后不再转发新请求
+	healthChecker.SetShuttingDown()
+	deregister()
+	time.Sleep(registry.DeregisterDelay)
+
+	// 等待处理中的请求完成
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Shop service forced to shutdown: %v", err)
+	}
+
+	// 返回后按注册的相反顺序执行defer：关闭钱包服务连接、停止Kafka消费者、刷新并关闭生产者、关闭数据库连接池
 }
@@ -54,10 +54,10 @@ type Cart struct {
 
 // ProductCreateRequest 创建商品请求
 type ProductCreateRequest struct {
-	Name        string  `json:"name" validate:"required"`
+	Name        string  `json:"name" binding:"required"`
 	Description string  `json:"description"`
-	Price       float64 `json:"price" validate:"required,gt=0"`
-	Stock       int     `json:"stock" validate:"gte=0"`
+	Price       float64 `json:"price" binding:"required,gt=0"`
+	Stock       int     `json:"stock" binding:"gte=0"`
 	ImageURL    string  `json:"image_url"`
 	Category    string  `json:"category"`
 }
@@ -66,37 +66,37 @@ type ProductCreateRequest struct {
 type ProductUpdateRequest struct {
 	Name        *string  `json:"name"`
 	Description *string  `json:"description"`
-	Price       *float64 `json:"price" validate:"omitempty,gt=0"`
-	Stock       *int     `json:"stock" validate:"omitempty,gte=0"`
+	Price       *float64 `json:"price" binding:"omitempty,gt=0"`
+	Stock       *int     `json:"stock" binding:"omitempty,gte=0"`
 	ImageURL    *string  `json:"image_url"`
 	Category    *string  `json:"category"`
-	Status      *string  `json:"status"`
+	Status      *string  `json:"status" binding:"omitempty,oneof=active sold_out offline"`
 }
 
 // CreateOrderRequest 创建订单请求
 type CreateOrderRequest struct {
-	UserID  uint               `json:"user_id" validate:"required"`
-	Items   []OrderItemRequest `json:"items" validate:"required,min=1"`
-	Address string             `json:"address" validate:"required"`
-	Phone   string             `json:"phone" validate:"required"`
+	UserID  uint               `json:"user_id" binding:"required"`
+	Items   []OrderItemRequest `json:"items" binding:"required_unless=UseCart true,omitempty,min=1,dive"` // 使用购物车时可以不传
+	Address string             `json:"address" binding:"required"`
+	Phone   string             `json:"phone" binding:"required"`
 	Remark  string             `json:"remark"`
 	UseCart bool               `json:"use_cart"` // 是否使用购物车
 }
 
 // OrderItemRequest 订单项请求
 type OrderItemRequest struct {
-	ProductID uint `json:"product_id" validate:"required"`
-	Quantity  int  `json:"quantity" validate:"required,gt=0"`
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
 }
 
 // AddToCartRequest 添加到购物车请求
 type AddToCartRequest struct {
-	UserID    uint `json:"user_id" validate:"required"`
-	ProductID uint `json:"product_id" validate:"required"`
-	Quantity  int  `json:"quantity" validate:"required,gt=0"`
+	UserID    uint `json:"user_id" binding:"required"`
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
 }
 
 // UpdateCartRequest 更新购物车请求
 type UpdateCartRequest struct {
-	Quantity int `json:"quantity" validate:"required,gt=0"`
+	Quantity int `json:"quantity" binding:"required,gt=0"`
 }
@@ -27,6 +27,9 @@ type OrderRepository interface {
 	UpdateOrderStatus(id uint, status string) error
 	CreateOrderItem(item *models.OrderItem) error
 	GetOrderItemsByOrderID(orderID uint) ([]*models.OrderItem, error)
+	ListUserOrders(userID uint) ([]*models.Order, error)
+	ListUserOrderItems(userID uint) ([]*models.OrderItem, error)
+	AnonymizeUserOrders(userID uint) (int64, error)
 }
 
 // CartRepository 购物车仓库接口
@@ -180,6 +183,29 @@ func (r *orderRepository) GetOrderItemsByOrderID(orderID uint) ([]*models.OrderI
 	return items, err
 }
 
+// ListUserOrders 获取用户的全部订单，不加载订单项
+func (r *orderRepository) ListUserOrders(userID uint) ([]*models.Order, error) {
+	var orders []*models.Order
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&orders).Error
+	return orders, err
+}
+
+// ListUserOrderItems 获取用户全部订单的订单项
+func (r *orderRepository) ListUserOrderItems(userID uint) ([]*models.OrderItem, error) {
+	var items []*models.OrderItem
+	err := r.db.Where("order_id IN (?)", r.db.Model(&models.Order{}).Select("id").Where("user_id = ?", userID)).
+		Order("id").Find(&items).Error
+	return items, err
+}
+
+// AnonymizeUserOrders 清空用户所有订单的收货地址、电话和备注，返回修改的订单数
+func (r *orderRepository) AnonymizeUserOrders(userID uint) (int64, error) {
+	result := r.db.Model(&models.Order{}).
+		Where("user_id = ? AND (address <> '' OR phone <> '' OR remark <> '')", userID).
+		Updates(map[string]interface{}{"address": "", "phone": "", "remark": ""})
+	return result.RowsAffected, result.Error
+}
+
 // ========== Cart Repository Implementation ==========
 
 func (r *cartRepository) AddToCart(cart *models.Cart) error {
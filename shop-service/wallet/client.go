@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	walletpb "blog/shared/proto/wallet"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// callTimeout 每次调用钱包服务的最长时间，调用方context的截止时间更早时以调用方为准
+const callTimeout = 5 * time.Second
+
+// Client 钱包服务gRPC客户端
+type Client struct {
+	conn   *grpc.ClientConn
+	wallet walletpb.WalletServiceClient
+}
+
+// NewClient 创建钱包服务客户端，addr 为钱包服务gRPC地址
+// 连接在首次调用时建立，每次调用签发服务令牌并传递链路信息
+func NewClient(addr string, signer *serviceauth.Signer) (*Client, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			tracing.UnaryClientInterceptor(),
+			signer.UnaryClientInterceptor("wallet-service"),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet client: %v", err)
+	}
+	return &Client{conn: conn, wallet: walletpb.NewWalletServiceClient(conn)}, nil
+}
+
+// Purchase 支付订单，钱包服务按订单ID去重，同一订单重复调用不会重复扣款
+// 调用方应传入不随客户端断开而取消的ctx，由 callTimeout 限制调用时间
+func (c *Client) Purchase(ctx context.Context, userID, orderID uint, items []*walletpb.PurchaseItem) (*walletpb.PurchaseReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	return c.wallet.Purchase(ctx, &walletpb.PurchaseRequest{
+		UserId:  uint64(userID),
+		OrderId: uint64(orderID),
+		Items:   items,
+	})
+}
+
+// RefundOrder 退回订单的全部付款
+func (c *Client) RefundOrder(ctx context.Context, orderID uint, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	_, err := c.wallet.Refund(ctx, &walletpb.RefundRequest{
+		Target: &walletpb.RefundRequest_OrderId{OrderId: uint64(orderID)},
+		Reason: reason,
+	})
+	return err
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -3,19 +3,27 @@ package config
 import (
 	"blog/shared/config"
 	"blog/shared/email"
+	"blog/shared/serviceauth"
 	"encoding/json"
 	"log"
 	"os"
+	"strings"
 )
 
 // Config 用户服务配置
 type Config struct {
-	Server   ServerConfig      `json:"server"`
-	Database DatabaseConfig    `json:"database"`
-	Redis    RedisConfig       `json:"redis"`
-	Kafka    KafkaConfig       `json:"kafka"`
-	Email    email.EmailConfig `json:"email"`
-	JWT      JWTConfig         `json:"jwt"`
+	Server        ServerConfig        `json:"server"`
+	Database      DatabaseConfig      `json:"database"`
+	Redis         RedisConfig         `json:"redis"`
+	Kafka         KafkaConfig         `json:"kafka"`
+	Email         email.EmailConfig   `json:"email"`
+	JWT           JWTConfig           `json:"jwt"`
+	PasswordReset PasswordResetConfig `json:"password_reset"`
+	LoginGuard    LoginGuardConfig    `json:"login_guard"`
+	Verification  VerificationConfig  `json:"verification"`
+	Roles         RolesConfig         `json:"roles"`
+	DataRequests  DataRequestsConfig  `json:"data_requests"`
+	ServiceAuth   serviceauth.Config  `json:"service_auth"`
 }
 
 // ServerConfig 服务器配置
@@ -42,7 +50,87 @@ type KafkaConfig struct {
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret string `json:"secret"`
+	AccessTTL           int `json:"access_ttl"`            // 访问令牌有效期（秒）
+	RefreshTTL          int `json:"refresh_ttl"`           // 刷新令牌有效期（秒），每次刷新后重新计算
+	KeyRotationInterval int `json:"key_rotation_interval"` // 签名密钥轮换间隔（秒）
+}
+
+// PasswordResetConfig 密码重置配置
+type PasswordResetConfig struct {
+	URL string `json:"url"` // 前端重置密码页面，重置令牌以 token 查询参数附加在后面
+	TTL int    `json:"ttl"` // 重置令牌有效期（秒）
+}
+
+// LoginGuardConfig 登录保护配置，时间均为秒
+type LoginGuardConfig struct {
+	Window             int `json:"window"`               // 失败计数窗口
+	FreeAttempts       int `json:"free_attempts"`        // 不需要等待的失败次数
+	BaseDelay          int `json:"base_delay"`           // 超过免等待次数后的初始等待时间，每次失败翻倍
+	MaxDelay           int `json:"max_delay"`            // 等待时间上限
+	EmailLockThreshold int `json:"email_lock_threshold"` // 同一邮箱失败达到该次数时锁定账户
+	IPLockThreshold    int `json:"ip_lock_threshold"`    // 同一IP失败达到该次数时封禁该IP
+	LockDuration       int `json:"lock_duration"`        // 锁定时长
+}
+
+// VerificationConfig 邮箱验证码配置
+type VerificationConfig struct {
+	CodeTTL        int `json:"code_ttl"`        // 验证码有效期（秒）
+	MaxAttempts    int `json:"max_attempts"`    // 每个验证码允许的尝试次数
+	ResendCooldown int `json:"resend_cooldown"` // 同一邮箱两次发送的最短间隔（秒）
+	DailyLimit     int `json:"daily_limit"`     // 同一邮箱24小时内最多发送的次数
+}
+
+// RolesConfig 角色配置
+type RolesConfig struct {
+	BootstrapAdmins []string `json:"bootstrap_admins"` // 启动时设为管理员的已注册邮箱，用于创建第一个管理员
+}
+
+// DataRequestsConfig 个人数据导出和账户注销配置
+type DataRequestsConfig struct {
+	Services      []string `json:"services"`       // 保存用户数据、需要处理请求的服务
+	RetryInterval int      `json:"retry_interval"` // 未收到所有服务的结果时重新发送请求的间隔（秒）
+	MaxAttempts   int      `json:"max_attempts"`   // 发送请求的最多次数，之后请求标记为失败
+	ExportTTL     int      `json:"export_ttl"`     // 导出文件的下载有效期（秒）
+}
+
+// 令牌有效期和密钥轮换间隔默认值
+const (
+	defaultAccessTTL           = 15 * 60
+	defaultRefreshTTL          = 30 * 24 * 60 * 60
+	defaultKeyRotationInterval = 30 * 24 * 60 * 60
+	defaultPasswordResetTTL    = 30 * 60
+	defaultPasswordResetURL    = "http://localhost:3000/reset-password"
+)
+
+// 邮箱验证码默认值：10分钟有效，每个验证码尝试5次，1分钟内只发送一次，24小时内最多发送10次
+const (
+	defaultVerificationCodeTTL        = 10 * 60
+	defaultVerificationMaxAttempts    = 5
+	defaultVerificationResendCooldown = 60
+	defaultVerificationDailyLimit     = 10
+)
+
+// defaultDataRequestsConfig 个人数据请求默认配置：分发给钱包、评论和商城服务，5分钟未完成时重新发送，最多发送5次，导出文件保留7天
+func defaultDataRequestsConfig() DataRequestsConfig {
+	return DataRequestsConfig{
+		Services:      []string{"wallet-service", "comment-service", "shop-service"},
+		RetryInterval: 5 * 60,
+		MaxAttempts:   5,
+		ExportTTL:     7 * 24 * 60 * 60,
+	}
+}
+
+// defaultLoginGuardConfig 登录保护默认配置：15分钟内同一邮箱失败10次锁定15分钟，同一IP失败50次封禁15分钟
+func defaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		Window:             15 * 60,
+		FreeAttempts:       3,
+		BaseDelay:          1,
+		MaxDelay:           30,
+		EmailLockThreshold: 10,
+		IPLockThreshold:    50,
+		LockDuration:       15 * 60,
+	}
 }
 
 // LoadConfig 从Redis配置中心加载配置
@@ -75,16 +163,92 @@ func LoadConfig() *Config {
 		return loadDefaultConfig()
 	}
 
+	// 配置中心未配置令牌有效期、轮换间隔、密码重置和验证码策略时使用默认值
+	if cfg.JWT.AccessTTL <= 0 {
+		cfg.JWT.AccessTTL = defaultAccessTTL
+	}
+	if cfg.JWT.RefreshTTL <= 0 {
+		cfg.JWT.RefreshTTL = defaultRefreshTTL
+	}
+	if cfg.JWT.KeyRotationInterval <= 0 {
+		cfg.JWT.KeyRotationInterval = defaultKeyRotationInterval
+	}
+	if cfg.PasswordReset.URL == "" {
+		cfg.PasswordReset.URL = defaultPasswordResetURL
+	}
+	if cfg.PasswordReset.TTL <= 0 {
+		cfg.PasswordReset.TTL = defaultPasswordResetTTL
+	}
+	fillLoginGuardDefaults(&cfg.LoginGuard)
+	fillDataRequestsDefaults(&cfg.DataRequests)
+	if cfg.Verification.CodeTTL <= 0 {
+		cfg.Verification.CodeTTL = defaultVerificationCodeTTL
+	}
+	if cfg.Verification.MaxAttempts <= 0 {
+		cfg.Verification.MaxAttempts = defaultVerificationMaxAttempts
+	}
+	if cfg.Verification.ResendCooldown <= 0 {
+		cfg.Verification.ResendCooldown = defaultVerificationResendCooldown
+	}
+	if cfg.Verification.DailyLimit <= 0 {
+		cfg.Verification.DailyLimit = defaultVerificationDailyLimit
+	}
+
+	// 配置中心未配置服务密钥时使用默认密钥
+	if len(cfg.ServiceAuth.Trusted) == 0 {
+		cfg.ServiceAuth = serviceauth.DefaultConfig("user-service", "api-gateway")
+	}
+
 	log.Printf("Config loaded from Redis config center for user-service")
 	return &cfg
 }
 
+// fillLoginGuardDefaults 配置中心未配置的登录保护项使用默认值
+// 锁定阈值不能关闭，否则失败次数不受限制
+func fillLoginGuardDefaults(cfg *LoginGuardConfig) {
+	defaults := defaultLoginGuardConfig()
+	if cfg.Window <= 0 {
+		cfg.Window = defaults.Window
+	}
+	if cfg.FreeAttempts <= 0 {
+		cfg.FreeAttempts = defaults.FreeAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaults.BaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaults.MaxDelay
+	}
+	if cfg.EmailLockThreshold <= 0 {
+		cfg.EmailLockThreshold = defaults.EmailLockThreshold
+	}
+	if cfg.IPLockThreshold <= 0 {
+		cfg.IPLockThreshold = defaults.IPLockThreshold
+	}
+	if cfg.LockDuration <= 0 {
+		cfg.LockDuration = defaults.LockDuration
+	}
+}
+
+// fillDataRequestsDefaults 配置中心未配置的个人数据请求项使用默认值
+func fillDataRequestsDefaults(cfg *DataRequestsConfig) {
+	defaults := defaultDataRequestsConfig()
+	if cfg.Services == nil {
+		cfg.Services = defaults.Services
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaults.RetryInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaults.MaxAttempts
+	}
+	if cfg.ExportTTL <= 0 {
+		cfg.ExportTTL = defaults.ExportTTL
+	}
+}
+
 // loadDefaultConfig 加载默认配置
 func loadDefaultConfig() *Config {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "sta_go_jwt_secret"
-	}
 	// 检查环境变量
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
@@ -121,6 +285,14 @@ func loadDefaultConfig() *Config {
 		kafkaPort = "9092"
 	}
 
+	// 逗号分隔的管理员邮箱
+	var bootstrapAdmins []string
+	for _, email := range strings.Split(os.Getenv("BOOTSTRAP_ADMINS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			bootstrapAdmins = append(bootstrapAdmins, email)
+		}
+	}
+
 	return &Config{
 		Server: ServerConfig{
 			Port: port,
@@ -146,7 +318,25 @@ func loadDefaultConfig() *Config {
 			IsSSL:    true,
 		},
 		JWT: JWTConfig{
-			Secret: jwtSecret,
+			AccessTTL:           defaultAccessTTL,
+			RefreshTTL:          defaultRefreshTTL,
+			KeyRotationInterval: defaultKeyRotationInterval,
+		},
+		PasswordReset: PasswordResetConfig{
+			URL: defaultPasswordResetURL,
+			TTL: defaultPasswordResetTTL,
+		},
+		LoginGuard: defaultLoginGuardConfig(),
+		Verification: VerificationConfig{
+			CodeTTL:        defaultVerificationCodeTTL,
+			MaxAttempts:    defaultVerificationMaxAttempts,
+			ResendCooldown: defaultVerificationResendCooldown,
+			DailyLimit:     defaultVerificationDailyLimit,
+		},
+		Roles: RolesConfig{
+			BootstrapAdmins: bootstrapAdmins,
 		},
+		DataRequests: defaultDataRequestsConfig(),
+		ServiceAuth:  serviceauth.DefaultConfig("user-service", "api-gateway"),
 	}
 }
@@ -1,9 +1,21 @@
 package controller
 
 import (
+	"blog/shared/auth"
+	"blog/shared/health"
+	"blog/shared/jwks"
 	"blog/shared/models"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"blog/shared/validation"
 	"blog/user-service/logic"
+	"blog/user-service/repository"
+	"context"
+	"errors"
+	"math"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Dearlimg/Goutils/pkg/app"
 	"github.com/Dearlimg/Goutils/pkg/app/errcode"
@@ -13,11 +25,12 @@ import (
 // UserController 用户控制器
 type UserController struct {
 	userLogic *logic.UserLogic
+	keyRing   *logic.KeyRing
 }
 
 // NewUserController 创建用户控制器
-func NewUserController(userLogic *logic.UserLogic) *UserController {
-	return &UserController{userLogic: userLogic}
+func NewUserController(userLogic *logic.UserLogic, keyRing *logic.KeyRing) *UserController {
+	return &UserController{userLogic: userLogic, keyRing: keyRing}
 }
 
 // Register 用户注册
@@ -25,12 +38,11 @@ func (uc *UserController) Register(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req models.UserRegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	user, err := uc.userLogic.Register(&req)
+	user, err := uc.userLogic.Register(c.Request.Context(), &req)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -44,43 +56,140 @@ func (uc *UserController) Login(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req models.UserLoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	user, token, err := uc.userLogic.Login(&req)
+	user, tokens, err := uc.userLogic.Login(c.Request.Context(), &req, auth.ClientIP(c))
 	if err != nil {
+		var blocked *logic.LoginBlockedError
+		if errors.As(err, &blocked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			rly.Reply(errcode.ErrTooManyRequests.WithDetails(err.Error()))
+			return
+		}
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
 	}
 
 	rly.Reply(nil, gin.H{
-		"user":  user,
-		"token": token,
+		"user":          user,
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 
+// RefreshToken 刷新令牌
+func (uc *UserController) RefreshToken(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.RefreshTokenRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := uc.userLogic.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidRefreshToken) || errors.Is(err, logic.ErrRefreshTokenReused) {
+			rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+			return
+		}
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, tokens)
+}
+
+// Logout 登出
+func (uc *UserController) Logout(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.RefreshTokenRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	err := uc.userLogic.Logout(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidRefreshToken) {
+			rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+			return
+		}
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, "Logged out successfully")
+}
+
+// ForgotPassword 申请重置密码
+func (uc *UserController) ForgotPassword(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.ForgotPasswordRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	err := uc.userLogic.ForgotPassword(c.Request.Context(), req.Email)
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	// 无论邮箱是否已注册都返回相同的结果
+	rly.Reply(nil, "If the email is registered, a password reset link has been sent")
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.ResetPasswordRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	err := uc.userLogic.ResetPassword(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidResetToken) {
+			rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+			return
+		}
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, "Password reset successfully, please login again")
+}
+
 // VerifyEmail 验证邮箱
 func (uc *UserController) VerifyEmail(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req struct {
-		Email string `json:"email" validate:"required,email"`
-		Code  string `json:"code" validate:"required"`
+		Email string `json:"email" binding:"required,email"`
+		Code  string `json:"code" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	err := uc.userLogic.VerifyEmail(req.Email, req.Code)
+	err := uc.userLogic.VerifyEmail(c.Request.Context(), req.Email, req.Code)
 	if err != nil {
+		if errors.Is(err, logic.ErrInvalidVerificationCode) || errors.Is(err, logic.ErrVerificationAttemptsExceeded) ||
+			errors.Is(err, logic.ErrEmailTaken) {
+			rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+			return
+		}
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
 	}
 
+	// 已签发的访问令牌仍标记为未验证，客户端刷新令牌后即可进行写操作；修改邮箱时所有设备需要使用新邮箱重新登录
 	rly.Reply(nil, "Email verified successfully")
 }
 
@@ -89,16 +198,21 @@ func (uc *UserController) ResendVerificationCode(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req struct {
-		Email string `json:"email" validate:"required,email"`
+		Email string `json:"email" binding:"required,email"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
 	err := uc.userLogic.ResendVerificationCode(req.Email)
 	if err != nil {
+		var throttled *logic.VerificationThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			rly.Reply(errcode.ErrTooManyRequests.WithDetails(err.Error()))
+			return
+		}
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
 	}
@@ -106,7 +220,7 @@ func (uc *UserController) ResendVerificationCode(c *gin.Context) {
 	rly.Reply(nil, "Verification code sent successfully")
 }
 
-// GetUserProfile 获取用户信息
+// GetUserProfile 获取用户信息，查看自己时返回完整资料，查看其他用户时只返回公开资料
 func (uc *UserController) GetUserProfile(c *gin.Context) {
 	rly := app.NewResponse(c)
 
@@ -117,7 +231,17 @@ func (uc *UserController) GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := uc.userLogic.GetUserProfile(uint(userID))
+	if identity, ok := auth.FromContext(c); ok && identity.UserID == uint(userID) {
+		user, err := uc.userLogic.GetUserProfile(uint(userID))
+		if err != nil {
+			rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+			return
+		}
+		rly.Reply(nil, user)
+		return
+	}
+
+	user, err := uc.userLogic.GetPublicProfile(uint(userID))
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -126,53 +250,394 @@ func (uc *UserController) GetUserProfile(c *gin.Context) {
 	rly.Reply(nil, user)
 }
 
-// HealthCheck 健康检查
-func (uc *UserController) HealthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status":  "healthy",
-		"service": "user-service",
+// GetMyProfile 获取当前用户的资料
+func (uc *UserController) GetMyProfile(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	identity, _ := auth.FromContext(c)
+	user, err := uc.userLogic.GetUserProfile(identity.UserID)
+	if err != nil {
+		rly.Reply(errcode.ErrNotFound.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, user)
+}
+
+// UpdateMyProfile 修改当前用户的资料
+func (uc *UserController) UpdateMyProfile(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.UpdateProfileRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	identity, _ := auth.FromContext(c)
+	user, err := uc.userLogic.UpdateProfile(c.Request.Context(), identity.UserID, &req)
+	if err != nil {
+		replyCredentialError(c, rly, err)
+		return
+	}
+
+	rly.Reply(nil, user)
+}
+
+// ChangePassword 使用当前密码修改密码，其他设备上的登录随之失效
+func (uc *UserController) ChangePassword(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.ChangePasswordRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	identity, _ := auth.FromContext(c)
+	tokens, err := uc.userLogic.ChangePassword(c.Request.Context(), identity.UserID, &req, auth.ClientIP(c))
+	if err != nil {
+		replyCredentialError(c, rly, err)
+		return
+	}
+
+	rly.Reply(nil, tokens)
+}
+
+// ChangeEmail 使用当前密码申请修改登录邮箱，新邮箱验证后才生效
+func (uc *UserController) ChangeEmail(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.ChangeEmailRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	identity, _ := auth.FromContext(c)
+	user, err := uc.userLogic.ChangeEmail(c.Request.Context(), identity.UserID, &req, auth.ClientIP(c))
+	if err != nil {
+		replyCredentialError(c, rly, err)
+		return
+	}
+
+	rly.Reply(nil, user)
+}
+
+// DeleteMyAccount 使用当前密码注销账户，返回注销请求及各服务的处理进度
+func (uc *UserController) DeleteMyAccount(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var req models.DeleteAccountRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	identity, _ := auth.FromContext(c)
+	deletion, err := uc.userLogic.DeleteAccount(c.Request.Context(), identity.UserID, &req, auth.ClientIP(c))
+	if err != nil {
+		replyCredentialError(c, rly, err)
+		return
+	}
+
+	rly.Reply(nil, deletion)
+}
+
+// RequestMyExport 申请导出当前用户的个人数据
+func (uc *UserController) RequestMyExport(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	identity, _ := auth.FromContext(c)
+	export, err := uc.userLogic.RequestExport(c.Request.Context(), identity.UserID)
+	if err != nil {
+		replyDataRequestError(rly, err)
+		return
+	}
+
+	rly.Reply(nil, export)
+}
+
+// GetMyExport 查询最近一次导出及各服务的处理进度
+func (uc *UserController) GetMyExport(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	identity, _ := auth.FromContext(c)
+	export, err := uc.userLogic.GetLatestExport(identity.UserID)
+	if err != nil {
+		replyDataRequestError(rly, err)
+		return
+	}
+
+	rly.Reply(nil, export)
+}
+
+// DownloadMyExport 下载最近一次已完成的导出，返回zip文件，不使用统一响应结构
+func (uc *UserController) DownloadMyExport(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	identity, _ := auth.FromContext(c)
+	filename, archive, err := uc.userLogic.DownloadExport(identity.UserID)
+	if err != nil {
+		replyDataRequestError(rly, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// GetDataRequest 管理员查询个人数据请求及各服务的处理进度
+func (uc *UserController) GetDataRequest(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails("invalid data request ID"))
+		return
+	}
+
+	req, err := uc.userLogic.GetDataRequest(uint(id))
+	if err != nil {
+		replyDataRequestError(rly, err)
+		return
+	}
+
+	rly.Reply(nil, req)
+}
+
+// replyDataRequestError 返回个人数据导出和查询的错误
+func replyDataRequestError(rly *app.Response, err error) {
+	switch {
+	case errors.Is(err, logic.ErrDataRequestNotFound), errors.Is(err, logic.ErrUserNotFound):
+		rly.Reply(errcode.ErrNotFound.WithDetails(err.Error()))
+	case errors.Is(err, logic.ErrExportNotReady), errors.Is(err, logic.ErrExportExpired):
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	default:
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+	}
+}
+
+// replyCredentialError 返回修改资料、密码、邮箱和注销账户的错误
+func replyCredentialError(c *gin.Context, rly *app.Response, err error) {
+	var blocked *logic.LoginBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		rly.Reply(errcode.ErrTooManyRequests.WithDetails(err.Error()))
+	case errors.Is(err, logic.ErrUserNotFound):
+		rly.Reply(errcode.ErrNotFound.WithDetails(err.Error()))
+	case errors.Is(err, logic.ErrIncorrectPassword), errors.Is(err, logic.ErrUsernameTaken),
+		errors.Is(err, logic.ErrEmailTaken), errors.Is(err, logic.ErrSameEmail), errors.Is(err, logic.ErrInvalidAvatarURL),
+		errors.Is(err, logic.ErrAdminAccountDeletion):
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	default:
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+	}
+}
+
+// GetLoginLockouts 管理员查询邮箱或IP的登录失败次数和锁定状态
+func (uc *UserController) GetLoginLockouts(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	email, ip := c.Query("email"), c.Query("ip")
+	if email == "" && ip == "" {
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails("email or ip is required"))
+		return
+	}
+
+	statuses, err := uc.userLogic.GetLoginStatus(email, ip)
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, statuses)
+}
+
+// UnlockLogin 管理员解除邮箱或IP的登录锁定
+func (uc *UserController) UnlockLogin(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	email, ip := c.Query("email"), c.Query("ip")
+	if email == "" && ip == "" {
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails("email or ip is required"))
+		return
+	}
+
+	identity, _ := auth.FromContext(c)
+	err := uc.userLogic.UnlockLogin(c.Request.Context(), email, ip, identity.UserID)
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, "Login unlocked successfully")
+}
+
+// ListRoles 列出所有角色及其权限
+func (uc *UserController) ListRoles(c *gin.Context) {
+	rly := app.NewResponse(c)
+	rly.Reply(nil, uc.userLogic.ListRoles())
+}
+
+// AssignRole 管理员为用户分配角色
+func (uc *UserController) AssignRole(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		rly.Reply(errcode.ErrParamsNotValid.WithDetails("invalid user ID"))
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	identity, _ := auth.FromContext(c)
+	user, err := uc.userLogic.AssignRole(c.Request.Context(), uint(userID), &req, identity.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, logic.ErrUserNotFound):
+			rly.Reply(errcode.ErrNotFound.WithDetails(err.Error()))
+		case errors.Is(err, logic.ErrInvalidRole), errors.Is(err, logic.ErrSelfRoleChange), errors.Is(err, repository.ErrRoleConflict):
+			rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+		default:
+			rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		}
+		return
+	}
+
+	rly.Reply(nil, user)
+}
+
+// GetRoleAuditLogs 管理员分页查询角色变更记录，可按 user_id 过滤
+func (uc *UserController) GetRoleAuditLogs(c *gin.Context) {
+	rly := app.NewResponse(c)
+
+	var userID uint64
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		var err error
+		userID, err = strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			rly.Reply(errcode.ErrParamsNotValid.WithDetails("invalid user ID"))
+			return
+		}
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	logs, total, err := uc.userLogic.GetRoleAuditLogs(uint(userID), page, pageSize)
+	if err != nil {
+		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
+		return
+	}
+
+	rly.Reply(nil, gin.H{
+		"logs":      logs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
 	})
 }
 
+// JWKS 公布访问令牌的验签公钥，按JWKS标准格式返回，不使用统一响应结构
+func (uc *UserController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, uc.keyRing.JWKS())
+}
+
 // Server HTTP服务器
 type Server struct {
-	router *gin.Engine
-	port   string
+	router     *gin.Engine
+	port       string
+	httpServer *http.Server
 }
 
 // NewServer 创建HTTP服务器
-func NewServer(port string, userController *UserController) *Server {
+func NewServer(port string, userController *UserController, verifier *serviceauth.Verifier, healthChecker *health.Checker) *Server {
 	router := gin.New()
-	router.Use(gin.Recovery(), gin.Logger())
+	// 不按地址信任任何代理，客户端地址只取通过服务令牌校验的网关写入的 X-Client-IP
+	router.SetTrustedProxies(nil)
+	router.Use(gin.Recovery(), tracing.Middleware(), tracing.Logger())
 
 	// 健康检查路由
-	router.GET("/health", userController.HealthCheck)
+	healthChecker.RegisterRoutes(router)
+
+	// 验签公钥，网关定期获取
+	router.GET(jwks.Path, userController.JWKS)
 
-	// 用户相关路由
+	// 用户相关路由，只接受网关转发的请求
 	api := router.Group("/api/v1")
+	api.Use(verifier.Require(callerACL()))
 	{
 		users := api.Group("/users")
 		{
 			users.POST("/register", userController.Register)
 			users.POST("/login", userController.Login)
+			users.POST("/refresh", userController.RefreshToken)
+			users.POST("/logout", userController.Logout)
+			users.POST("/forgot-password", userController.ForgotPassword)
+			users.POST("/reset-password", userController.ResetPassword)
 			users.POST("/verify-email", userController.VerifyEmail)
 			users.POST("/resend-code", userController.ResendVerificationCode)
-			users.GET("/:id", userController.GetUserProfile)
+			users.GET("/:id", auth.Middleware(), userController.GetUserProfile)
+
+			// 当前用户的资料、密码和邮箱，未验证邮箱的用户也可以修改（如填错了邮箱）；
+			// 个人数据导出和账户注销同样不要求已验证邮箱
+			me := users.Group("/me", auth.Middleware(), auth.RequireUser())
+			{
+				me.GET("", userController.GetMyProfile)
+				me.PUT("", userController.UpdateMyProfile)
+				me.DELETE("", userController.DeleteMyAccount)
+				me.PUT("/password", userController.ChangePassword)
+				me.PUT("/email", userController.ChangeEmail)
+				me.POST("/export", userController.RequestMyExport)
+				me.GET("/export", userController.GetMyExport)
+				me.GET("/export/download", userController.DownloadMyExport)
+			}
+
+			// 管理员解除登录锁定、分配角色和查询个人数据请求，权限由网关根据JWT注入的身份头确定
+			admin := users.Group("/admin", auth.Middleware(), auth.RequirePermission(auth.PermUsersManage))
+			{
+				admin.GET("/lockouts", userController.GetLoginLockouts)
+				admin.DELETE("/lockouts", userController.UnlockLogin)
+				admin.GET("/roles", userController.ListRoles)
+				admin.PUT("/users/:id/role", userController.AssignRole)
+				admin.GET("/role-audit", userController.GetRoleAuditLogs)
+				admin.GET("/data-requests/:id", userController.GetDataRequest)
+			}
 		}
 	}
 
 	return &Server{
 		router: router,
 		port:   port,
+		httpServer: &http.Server{
+			Addr:              ":" + port,
+			Handler:           router,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
 	}
 }
 
+// callerACL 各调用方可以访问的接口
+// 所有接口都由网关转发外部请求，其他服务不直接调用
+func callerACL() *serviceauth.ACL {
+	return serviceauth.NewACL().
+		Allow("api-gateway", serviceauth.AnyEndpoint)
+}
+
 // Start 启动服务器
+// 调用Shutdown后返回nil
 func (s *Server) Start() error {
-	return s.router.Run(":" + s.port)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Shutdown 关闭服务器
-func (s *Server) Shutdown() {
-	// 这里可以添加优雅关闭逻辑
+// Shutdown 优雅关闭：停止接受新连接，等待处理中的请求完成，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
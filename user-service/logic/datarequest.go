@@ -0,0 +1,420 @@
+package logic
+
+import (
+	"archive/zip"
+	"blog/shared/auth"
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDataRequestNotFound 个人数据请求不存在
+	ErrDataRequestNotFound = errors.New("data request not found")
+	// ErrExportNotReady 导出尚未完成或已失败
+	ErrExportNotReady = errors.New("export is not ready")
+	// ErrExportExpired 导出文件已过下载有效期
+	ErrExportExpired = errors.New("export has expired, please request a new one")
+	// ErrAdminAccountDeletion 管理员不能注销自己的账户，需要先由其他管理员取消管理员角色
+	ErrAdminAccountDeletion = errors.New("admins cannot delete their account, ask another admin to change your role first")
+)
+
+// userServiceName 用户服务自身的处理进度，用户资料在创建请求时直接导出或匿名化
+const userServiceName = "user-service"
+
+// maxDueDataRequests 每次检查最多重新发送的请求数
+const maxDueDataRequests = 100
+
+// DataRequestPolicy 个人数据导出和账户注销策略
+type DataRequestPolicy struct {
+	Services      []string      // 保存用户数据、需要处理请求的服务
+	RetryInterval time.Duration // 未收到所有服务的结果时重新发送请求的间隔
+	MaxAttempts   int           // 发送请求的最多次数，之后请求标记为失败
+	ExportTTL     time.Duration // 导出文件的下载有效期
+}
+
+// RequestExport 申请导出个人数据，向各服务发送 user.export.requested 事件，各服务处理完成后可以下载
+// 已有未完成的导出时直接返回该请求
+func (ul *UserLogic) RequestExport(ctx context.Context, userID uint) (*models.DataRequest, error) {
+	latest, err := ul.dataRepo.GetLatestDataRequest(userID, models.DataRequestExport)
+	if err == nil && latest.Status == models.DataRequestPending {
+		return latest, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get data request: %v", err)
+	}
+
+	user, err := ul.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ul.exportUserData(user)
+	if err != nil {
+		return nil, err
+	}
+
+	req := ul.newDataRequest(userID, models.DataRequestExport)
+	err = ul.dataRepo.CreateDataRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data request: %v", err)
+	}
+	tracing.Printf(ctx, "User %d requested data export %d", userID, req.ID)
+
+	// 用户服务的数据与其他服务一样写入导出存储，保存失败时请求直接失败
+	err = ul.saveExport(req.ID, userServiceName, data)
+	if err != nil {
+		if _, failErr := ul.dataRepo.FailDataRequest(req.ID, err.Error()); failErr != nil {
+			tracing.Printf(ctx, "Failed to mark data request %d as failed: %v", req.ID, failErr)
+		}
+		return nil, err
+	}
+
+	ul.sendDataRequestEvent(ctx, req)
+	return ul.finishDataRequest(ctx, req.ID)
+}
+
+// GetLatestExport 获取最近一次导出及各服务的处理进度
+func (ul *UserLogic) GetLatestExport(userID uint) (*models.DataRequest, error) {
+	req, err := ul.dataRepo.GetLatestDataRequest(userID, models.DataRequestExport)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDataRequestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data request: %v", err)
+	}
+	return req, nil
+}
+
+// DownloadExport 把最近一次已完成的导出打包为zip，每个服务的数据为一个JSON文件，返回文件名和内容
+func (ul *UserLogic) DownloadExport(userID uint) (string, []byte, error) {
+	req, err := ul.GetLatestExport(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if req.Status != models.DataRequestCompleted {
+		return "", nil, ErrExportNotReady
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return "", nil, ErrExportExpired
+	}
+
+	var exportIDs []uint
+	for _, task := range req.Tasks {
+		if task.ExportID != 0 {
+			exportIDs = append(exportIDs, task.ExportID)
+		}
+	}
+	exports, err := ul.exports.Get(exportIDs)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get export data: %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifest, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	if err := writeZipFile(zw, "manifest.json", manifest); err != nil {
+		return "", nil, err
+	}
+	for _, export := range exports {
+		var data bytes.Buffer
+		if err := json.Indent(&data, []byte(export.Data), "", "  "); err != nil {
+			return "", nil, fmt.Errorf("invalid export data from %s: %v", export.Service, err)
+		}
+		if err := writeZipFile(zw, export.Service+".json", data.Bytes()); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", nil, fmt.Errorf("failed to create export archive: %v", err)
+	}
+
+	return fmt.Sprintf("user-%d-export-%d.zip", userID, req.ID), buf.Bytes(), nil
+}
+
+// DeleteAccount 使用当前密码注销账户
+// 用户资料立即匿名化且无法再登录，所有设备上的登录失效；向各服务发送 user.deleted 事件，由各服务匿名化或删除各自的数据
+func (ul *UserLogic) DeleteAccount(ctx context.Context, userID uint, req *models.DeleteAccountRequest, clientIP string) (*models.DataRequest, error) {
+	user, err := ul.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ul.verifyCurrentPassword(ctx, user, req.Password, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == auth.RoleAdmin {
+		return nil, ErrAdminAccountDeletion
+	}
+
+	// 密码替换为随机值的摘要，任何密码都无法再登录
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomToken(32)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+	user.Username = fmt.Sprintf("deleted_%d", user.ID)
+	user.Email = fmt.Sprintf("deleted+%d@deleted.invalid", user.ID)
+	user.PendingEmail = ""
+	user.Password = string(hashedPassword)
+	user.IsVerified = false
+	user.Role = auth.RoleUser
+	user.DisplayName = ""
+	user.AvatarURL = ""
+	user.Bio = ""
+
+	deletion := ul.newDataRequest(userID, models.DataRequestDeletion)
+	err = ul.dataRepo.DeleteAccount(user, deletion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete account: %v", err)
+	}
+	tracing.Printf(ctx, "User %d deleted account, data request %d", userID, deletion.ID)
+
+	err = ul.tokens.RevokeUser(ctx, userID)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to revoke sessions of user %d: %v", userID, err)
+	}
+
+	ul.sendDataRequestEvent(ctx, deletion)
+	return ul.finishDataRequest(ctx, deletion.ID)
+}
+
+// GetDataRequest 管理员查询个人数据请求及各服务的处理进度
+func (ul *UserLogic) GetDataRequest(id uint) (*models.DataRequest, error) {
+	req, err := ul.dataRepo.GetDataRequest(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDataRequestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data request: %v", err)
+	}
+	return req, nil
+}
+
+// HandleDataProgress 处理各服务通过 user.data.progress 发送的处理结果，导出的数据已由服务写入导出存储
+// 同一结果可能被多次收到，已完成的进度不再修改；处理失败时只记录原因，等待重新发送
+func (ul *UserLogic) HandleDataProgress(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	var progress models.UserDataProgressEvent
+	if err := json.Unmarshal(msg.Value, &progress); err != nil {
+		return fmt.Errorf("failed to unmarshal data progress event: %v", err)
+	}
+
+	if progress.Status != models.DataRequestCompleted {
+		tracing.Printf(ctx, "Service %s failed to process data request %d: %s", progress.Service, progress.RequestID, progress.Error)
+		return ul.dataRepo.RecordTaskError(progress.RequestID, progress.Service, progress.Error)
+	}
+
+	if progress.Type == models.DataRequestExport && progress.ExportID == 0 {
+		tracing.Printf(ctx, "Service %s completed data request %d without an export", progress.Service, progress.RequestID)
+		return ul.dataRepo.RecordTaskError(progress.RequestID, progress.Service, "export result without export_id")
+	}
+
+	updated, err := ul.dataRepo.CompleteTask(progress.RequestID, progress.Service, progress.ExportID)
+	if err != nil {
+		return fmt.Errorf("failed to complete data request task: %v", err)
+	}
+	if !updated {
+		return nil
+	}
+	tracing.Printf(ctx, "Service %s completed data request %d", progress.Service, progress.RequestID)
+
+	_, err = ul.finishDataRequest(ctx, progress.RequestID)
+	return err
+}
+
+// StartDataRequests 按间隔重新发送未完成的请求并删除过期的导出，直到stop关闭
+func (ul *UserLogic) StartDataRequests(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := ul.MaintainDataRequests(context.Background()); err != nil {
+					log.Printf("Failed to maintain data requests: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// MaintainDataRequests 超过重试间隔仍未完成的请求重新发送，发送次数用完时标记为失败；删除过期的导出
+// 多个副本同时检查时，按发送次数比较后更新，同一请求只由一个副本重新发送
+func (ul *UserLogic) MaintainDataRequests(ctx context.Context) error {
+	now := time.Now()
+	due, err := ul.dataRepo.ListDueDataRequests(now.Add(-ul.dataPolicy.RetryInterval), maxDueDataRequests)
+	if err != nil {
+		return fmt.Errorf("failed to list due data requests: %v", err)
+	}
+
+	for i := range due {
+		req := &due[i]
+		if req.Attempts >= ul.dataPolicy.MaxAttempts {
+			reason := fmt.Sprintf("no response after %d attempts", req.Attempts)
+			failed, err := ul.dataRepo.FailDataRequest(req.ID, reason)
+			if err != nil {
+				tracing.Printf(ctx, "Failed to mark data request %d as failed: %v", req.ID, err)
+			} else if failed {
+				tracing.Printf(ctx, "Data request %d of user %d failed: %s", req.ID, req.UserID, reason)
+			}
+			continue
+		}
+
+		sent, err := ul.dataRepo.MarkDataRequestSent(req.ID, req.Attempts)
+		if err != nil {
+			tracing.Printf(ctx, "Failed to update data request %d: %v", req.ID, err)
+			continue
+		}
+		if sent {
+			ul.sendDataRequestEvent(ctx, req)
+		}
+	}
+
+	deleted, err := ul.dataRepo.DeleteExpiredExports(now)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired exports: %v", err)
+	}
+	if deleted > 0 {
+		tracing.Printf(ctx, "Deleted %d expired data exports", deleted)
+	}
+	return nil
+}
+
+// newDataRequest 创建请求及各服务的处理进度
+// 注销时用户服务的进度直接完成；导出时在用户服务的数据保存后完成
+func (ul *UserLogic) newDataRequest(userID uint, requestType string) *models.DataRequest {
+	ownStatus := models.DataRequestCompleted
+	if requestType == models.DataRequestExport {
+		ownStatus = models.DataRequestPending
+	}
+	tasks := []models.DataRequestTask{{Service: userServiceName, Status: ownStatus}}
+	for _, service := range ul.dataPolicy.Services {
+		if service != userServiceName {
+			tasks = append(tasks, models.DataRequestTask{Service: service, Status: models.DataRequestPending})
+		}
+	}
+	return &models.DataRequest{
+		UserID:     userID,
+		Type:       requestType,
+		Status:     models.DataRequestPending,
+		Attempts:   1,
+		LastSentAt: time.Now(),
+		Tasks:      tasks,
+	}
+}
+
+// finishDataRequest 所有服务都已完成时结束请求，导出请求开始计算下载有效期；返回请求的最新状态
+func (ul *UserLogic) finishDataRequest(ctx context.Context, id uint) (*models.DataRequest, error) {
+	req, err := ul.GetDataRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != models.DataRequestPending {
+		return req, nil
+	}
+	for _, task := range req.Tasks {
+		if task.Status != models.DataRequestCompleted {
+			return req, nil
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.Type == models.DataRequestExport {
+		t := time.Now().Add(ul.dataPolicy.ExportTTL)
+		expiresAt = &t
+	}
+	finished, err := ul.dataRepo.FinishDataRequest(id, models.DataRequestCompleted, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish data request: %v", err)
+	}
+	if finished {
+		tracing.Printf(ctx, "Data request %d of user %d completed", id, req.UserID)
+	}
+	return ul.GetDataRequest(id)
+}
+
+// saveExport 把服务导出的数据写入导出存储，并完成该服务的处理进度
+func (ul *UserLogic) saveExport(requestID uint, service string, data []byte) error {
+	exportID, err := ul.exports.Save(requestID, service, data)
+	if err != nil {
+		return fmt.Errorf("failed to save export data: %v", err)
+	}
+	_, err = ul.dataRepo.CompleteTask(requestID, service, exportID)
+	if err != nil {
+		return fmt.Errorf("failed to complete data request task: %v", err)
+	}
+	return nil
+}
+
+// sendDataRequestEvent 向各服务发送导出或注销事件
+// 发送失败时把原因记录到未完成的处理进度中，查询请求时可见，等待下次检查重新发送
+func (ul *UserLogic) sendDataRequestEvent(ctx context.Context, req *models.DataRequest) {
+	topic := kafka.TopicUserExportRequest
+	if req.Type == models.DataRequestDeletion {
+		topic = kafka.TopicUserDeleted
+	}
+	event := &models.UserDataRequestEvent{
+		RequestID:   req.ID,
+		UserID:      req.UserID,
+		RequestedAt: req.CreatedAt,
+	}
+	err := ul.producer.SendMessage(ctx, topic, fmt.Sprintf("%d", req.UserID), event)
+	if err == nil {
+		return
+	}
+	tracing.Printf(ctx, "Failed to send data request %d: %v", req.ID, err)
+	if err := ul.dataRepo.RecordDispatchError(req.ID, fmt.Sprintf("failed to send request: %v", err)); err != nil {
+		tracing.Printf(ctx, "Failed to record dispatch error of data request %d: %v", req.ID, err)
+	}
+}
+
+// exportUserData 导出用户服务保存的用户资料和角色变更记录
+func (ul *UserLogic) exportUserData(user *models.User) ([]byte, error) {
+	var logs []models.RoleAuditLog
+	for page := 1; ; page++ {
+		batch, total, err := ul.userRepo.ListRoleAuditLogs(user.ID, page, maxAuditPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role audit logs: %v", err)
+		}
+		logs = append(logs, batch...)
+		if len(batch) == 0 || int64(len(logs)) >= total {
+			break
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"user":            newUserResponse(user),
+		"role_audit_logs": logs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user data: %v", err)
+	}
+	return data, nil
+}
+
+// writeZipFile 向zip中写入一个文件
+func writeZipFile(zw *zip.Writer, name string, content []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in export archive: %v", name, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s to export archive: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package logic
+
+import (
+	"blog/user-service/repository"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// LoginPolicy 登录保护策略
+type LoginPolicy struct {
+	Window             time.Duration // 失败计数窗口，从第一次失败开始计算
+	FreeAttempts       int           // 不需要等待的失败次数
+	BaseDelay          time.Duration // 超过免等待次数后第一次失败的等待时间，之后每次翻倍
+	MaxDelay           time.Duration // 等待时间上限
+	EmailLockThreshold int           // 窗口内同一邮箱失败达到该次数时锁定账户
+	IPLockThreshold    int           // 窗口内同一IP失败达到该次数时封禁该IP
+	LockDuration       time.Duration // 锁定时长
+}
+
+// 登录被拒绝的原因
+const (
+	BlockReasonDelay  = "delay"  // 失败次数过多，需要等待
+	BlockReasonLocked = "locked" // 账户已锁定或IP已封禁
+)
+
+// LoginBlockedError 登录被暂时拒绝，RetryAfter 后可以再次尝试
+type LoginBlockedError struct {
+	Scope      string // repository.ScopeEmail 或 repository.ScopeIP
+	Reason     string
+	RetryAfter time.Duration
+}
+
+// Error 实现error接口
+func (e *LoginBlockedError) Error() string {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if e.Reason == BlockReasonLocked {
+		if e.Scope == repository.ScopeIP {
+			return fmt.Sprintf("too many failed login attempts from this IP, try again in %d seconds", seconds)
+		}
+		return fmt.Sprintf("account temporarily locked due to too many failed login attempts, try again in %d seconds", seconds)
+	}
+	return fmt.Sprintf("too many failed login attempts, try again in %d seconds", seconds)
+}
+
+// Lockout 因登录失败产生的锁定
+type Lockout struct {
+	Scope    string
+	Subject  string // 邮箱或IP
+	Failures int
+	Until    time.Time
+}
+
+// LoginStatus 邮箱或IP当前的登录保护状态
+type LoginStatus struct {
+	Scope         string     `json:"scope"`
+	Subject       string     `json:"subject"`
+	Failures      int        `json:"failures"`                  // 窗口内的失败次数
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // 邮箱需要等待时，允许再次尝试的时间
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`    // 锁定截止时间
+}
+
+// LoginGuard 按邮箱和客户端IP统计登录失败
+//
+// 同一邮箱超过免等待次数后，每次失败都要等待更长的时间才能再次尝试；
+// 窗口内失败次数达到阈值时锁定账户或封禁IP一段时间。
+// 每次尝试在校验密码前预先记为失败，并发的请求不能同时通过检查。
+// 登录成功清空该邮箱的计数，IP只撤销本次尝试，之前的计数保留到窗口结束，避免用自己的账户重置IP计数
+type LoginGuard struct {
+	attemptRepo repository.LoginAttemptRepository
+	policy      LoginPolicy
+}
+
+// NewLoginGuard 创建登录保护
+func NewLoginGuard(attemptRepo repository.LoginAttemptRepository, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{attemptRepo: attemptRepo, policy: policy}
+}
+
+// LoginAttempt 已预占的一次登录尝试，校验密码后调用 Fail 或 Succeed
+type LoginAttempt struct {
+	subjects []guardSubject
+	counts   []int // 预占后各维度的失败次数，包括本次尝试
+}
+
+// Begin 校验密码前原子地检查锁定和等待时间，并把本次尝试预先记为失败
+// 并发的请求依次计数，不能同时通过检查；邮箱或IP被锁定或仍需等待时返回 *LoginBlockedError
+func (g *LoginGuard) Begin(email, ip string) (*LoginAttempt, error) {
+	subjects := g.subjects(email, ip)
+	attemptSubjects := make([]repository.AttemptSubject, 0, len(subjects))
+	for _, subject := range subjects {
+		attemptSubject := repository.AttemptSubject{Scope: subject.scope, Subject: subject.value, Threshold: subject.threshold}
+		// 同一IP后面可能有很多用户，IP只在达到阈值时封禁，不要求逐次等待
+		if subject.scope == repository.ScopeEmail {
+			attemptSubject.Delays = g.delays()
+		}
+		attemptSubjects = append(attemptSubjects, attemptSubject)
+	}
+
+	reservation, err := g.attemptRepo.ReserveAttempt(attemptSubjects, g.policy.Window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve login attempt: %v", err)
+	}
+	if reservation.Blocked != nil {
+		reason := BlockReasonDelay
+		if reservation.Reason == repository.AttemptLocked {
+			reason = BlockReasonLocked
+		}
+		return nil, &LoginBlockedError{Scope: reservation.Blocked.Scope, Reason: reason, RetryAfter: reservation.RetryAfter}
+	}
+	return &LoginAttempt{subjects: subjects, counts: reservation.Counts}, nil
+}
+
+// Fail 尝试失败，失败次数在 Begin 时已经记入，达到阈值时锁定，返回本次失败触发的锁定
+func (g *LoginGuard) Fail(attempt *LoginAttempt) ([]*Lockout, error) {
+	var lockouts []*Lockout
+	now := time.Now()
+	for i, subject := range attempt.subjects {
+		count := attempt.counts[i]
+		if subject.threshold <= 0 || count < subject.threshold {
+			continue
+		}
+
+		// 锁定期间不再计数，解锁后重新开始
+		until := now.Add(g.policy.LockDuration)
+		if err := g.attemptRepo.SetLock(subject.scope, subject.value, until); err != nil {
+			return lockouts, fmt.Errorf("failed to lock %s: %v", subject.scope, err)
+		}
+		if err := g.attemptRepo.ClearFailures(subject.scope, subject.value); err != nil {
+			return lockouts, fmt.Errorf("failed to clear login failures: %v", err)
+		}
+		lockouts = append(lockouts, &Lockout{
+			Scope:    subject.scope,
+			Subject:  subject.value,
+			Failures: count,
+			Until:    until,
+		})
+	}
+	return lockouts, nil
+}
+
+// Succeed 尝试成功，清空该邮箱的失败计数，IP只撤销本次预先记入的失败
+func (g *LoginGuard) Succeed(attempt *LoginAttempt) error {
+	for _, subject := range attempt.subjects {
+		var err error
+		if subject.scope == repository.ScopeEmail {
+			err = g.attemptRepo.ClearFailures(subject.scope, subject.value)
+		} else {
+			err = g.attemptRepo.ReleaseAttempt(subject.scope, subject.value)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clear login failures: %v", err)
+		}
+	}
+	return nil
+}
+
+// Status 查询邮箱或IP的登录保护状态
+func (g *LoginGuard) Status(scope, subject string) (*LoginStatus, error) {
+	if scope == repository.ScopeEmail {
+		subject = normalizeEmail(subject)
+	}
+	return g.status(scope, subject, time.Now())
+}
+
+// Unlock 解除锁定并清空失败计数
+func (g *LoginGuard) Unlock(scope, subject string) error {
+	if scope == repository.ScopeEmail {
+		subject = normalizeEmail(subject)
+	}
+	if err := g.attemptRepo.DeleteLock(scope, subject); err != nil {
+		return fmt.Errorf("failed to unlock %s: %v", scope, err)
+	}
+	if err := g.attemptRepo.ClearFailures(scope, subject); err != nil {
+		return fmt.Errorf("failed to clear login failures: %v", err)
+	}
+	return nil
+}
+
+// status 读取锁定和失败记录，计算需要等待到的时间
+func (g *LoginGuard) status(scope, subject string, now time.Time) (*LoginStatus, error) {
+	until, err := g.attemptRepo.GetLock(scope, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login lock: %v", err)
+	}
+	failures, err := g.attemptRepo.GetFailures(scope, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login failures: %v", err)
+	}
+
+	status := &LoginStatus{Scope: scope, Subject: subject, Failures: failures.Count}
+	if until.After(now) {
+		status.LockedUntil = &until
+	}
+	// 同一IP后面可能有很多用户，IP只在达到阈值时封禁，不要求逐次等待
+	if delay := g.delay(failures.Count); delay > 0 && scope == repository.ScopeEmail {
+		next := failures.LastFailure.Add(delay)
+		if next.After(now) {
+			status.NextAttemptAt = &next
+		}
+	}
+	return status, nil
+}
+
+// maxDelaySteps 等待时间没有上限时，预占脚本最多区分的翻倍次数
+const maxDelaySteps = 32
+
+// delays 失败0、1、2……次后需要等待的时间，等待时间不再增长后截止，更多的失败次数取最后一个
+func (g *LoginGuard) delays() []time.Duration {
+	var delays []time.Duration
+	for count := 0; ; count++ {
+		delay := g.delay(count)
+		delays = append(delays, delay)
+		if count <= g.policy.FreeAttempts {
+			continue
+		}
+		if delay == 0 || (g.policy.MaxDelay > 0 && delay >= g.policy.MaxDelay) || count >= g.policy.FreeAttempts+maxDelaySteps {
+			return delays
+		}
+	}
+}
+
+// delay 失败count次后需要等待的时间，与 delays 一致最多翻倍 maxDelaySteps-1 次
+func (g *LoginGuard) delay(count int) time.Duration {
+	extra := count - g.policy.FreeAttempts
+	if extra <= 0 || g.policy.BaseDelay <= 0 {
+		return 0
+	}
+	delay := g.policy.BaseDelay
+	for i := 1; i < extra && i < maxDelaySteps; i++ {
+		if (g.policy.MaxDelay > 0 && delay >= g.policy.MaxDelay) || delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if g.policy.MaxDelay > 0 && delay > g.policy.MaxDelay {
+		delay = g.policy.MaxDelay
+	}
+	return delay
+}
+
+// guardSubject 一个统计维度
+type guardSubject struct {
+	scope     string
+	value     string
+	threshold int
+}
+
+// subjects 需要检查的维度，IP为空时只按邮箱统计
+func (g *LoginGuard) subjects(email, ip string) []guardSubject {
+	subjects := []guardSubject{{scope: repository.ScopeEmail, value: normalizeEmail(email), threshold: g.policy.EmailLockThreshold}}
+	if ip != "" {
+		subjects = append(subjects, guardSubject{scope: repository.ScopeIP, value: ip, threshold: g.policy.IPLockThreshold})
+	}
+	return subjects
+}
+
+// normalizeEmail 统一邮箱大小写，避免通过改变大小写绕过计数
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
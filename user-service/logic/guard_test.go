@@ -0,0 +1,83 @@
+package logic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoginGuardDelay(t *testing.T) {
+	policy := LoginPolicy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+	tests := []struct {
+		name   string
+		policy LoginPolicy
+		count  int
+		want   time.Duration
+	}{
+		{"no failures", policy, 0, 0},
+		{"free attempts", policy, 3, 0},
+		{"first delayed failure", policy, 4, time.Second},
+		{"doubles", policy, 5, 2 * time.Second},
+		{"doubles again", policy, 7, 8 * time.Second},
+		{"last step below cap", policy, 8, 16 * time.Second},
+		{"capped", policy, 9, 30 * time.Second},
+		{"stays capped", policy, 100, 30 * time.Second},
+		{"no base delay", LoginPolicy{FreeAttempts: 3, MaxDelay: 30 * time.Second}, 10, 0},
+		{"no free attempts", LoginPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 1, time.Second},
+		{"no cap doubles", LoginPolicy{BaseDelay: time.Second}, 11, 1024 * time.Second},
+		{"no cap stops at max steps", LoginPolicy{BaseDelay: time.Second}, 1000, time.Second << (maxDelaySteps - 1)},
+		{"no cap does not overflow", LoginPolicy{BaseDelay: time.Hour}, 1000, time.Hour << 21},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewLoginGuard(nil, tt.policy)
+			got := g.delay(tt.count)
+			if got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.count, got, tt.want)
+			}
+			if got < 0 {
+				t.Errorf("delay(%d) overflowed: %v", tt.count, got)
+			}
+		})
+	}
+}
+
+func TestLoginGuardDelays(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		name   string
+		policy LoginPolicy
+		want   []time.Duration
+	}{
+		{"capped", LoginPolicy{FreeAttempts: 2, BaseDelay: s, MaxDelay: 5 * s}, []time.Duration{0, 0, 0, s, 2 * s, 4 * s, 5 * s}},
+		{"cap equals a step", LoginPolicy{FreeAttempts: 1, BaseDelay: s, MaxDelay: 4 * s}, []time.Duration{0, 0, s, 2 * s, 4 * s}},
+		{"no free attempts", LoginPolicy{BaseDelay: s, MaxDelay: s}, []time.Duration{0, s}},
+		{"no base delay", LoginPolicy{FreeAttempts: 2, MaxDelay: 5 * s}, []time.Duration{0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewLoginGuard(nil, tt.policy).delays()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("delays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 没有上限时截止于 maxDelaySteps，之后的失败次数取最后一个等待时间
+	g := NewLoginGuard(nil, LoginPolicy{FreeAttempts: 3, BaseDelay: s})
+	delays := g.delays()
+	if len(delays) != 3+maxDelaySteps+1 {
+		t.Fatalf("len(delays()) = %d, want %d", len(delays), 3+maxDelaySteps+1)
+	}
+	for count, delay := range delays {
+		if delay != g.delay(count) {
+			t.Errorf("delays()[%d] = %v, want delay(%d) = %v", count, delay, count, g.delay(count))
+		}
+	}
+	if last := delays[len(delays)-1]; g.delay(len(delays)+10) != last {
+		t.Errorf("delay beyond the table = %v, want last entry %v", g.delay(len(delays)+10), last)
+	}
+}
@@ -0,0 +1,221 @@
+package logic
+
+import (
+	"blog/shared/jwks"
+	"blog/user-service/repository"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrNoSigningKey 没有已启用的签名密钥
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// 密钥轮换参数
+const (
+	// keyPublishDelay 新密钥公布后到开始签名的时间，应大于各副本重新加载密钥和验证方刷新公钥集的间隔
+	keyPublishDelay = 10 * time.Minute
+	// keyRetireGrace 旧密钥在用它签发的令牌全部过期后继续公布的时间，容忍副本间的时钟偏差
+	keyRetireGrace = time.Minute
+	// keyRotationLockTTL 轮换锁的有效期
+	keyRotationLockTTL = 30 * time.Second
+)
+
+// signingKey 已解析的签名密钥
+type signingKey struct {
+	kid         string
+	activatesAt time.Time
+	privateKey  ed25519.PrivateKey
+}
+
+// KeyRing 访问令牌签名密钥环
+//
+// 密钥保存在Redis中，所有副本共用，签名时使用已启用的密钥中最新的一个。
+// 定期轮换时新密钥先只公布公钥，经过 keyPublishDelay 才用于签名，验证方见到新kid之前已能取到公钥；
+// 被取代的密钥继续公布，直到用它签发的访问令牌全部过期后删除。
+// 没有已启用的密钥时（首次启动，或紧急轮换删除了全部密钥）立即生成并启用新密钥
+type KeyRing struct {
+	keyRepo          repository.KeyRepository
+	accessTTL        time.Duration
+	rotationInterval time.Duration
+
+	mu         sync.RWMutex
+	keys       []*signingKey // 按启用时间升序
+	publicKeys *jwks.Set     // 与keys同时更新的公钥集
+}
+
+// NewKeyRing 创建密钥环并加载密钥，rotationInterval 为0表示不定期轮换
+func NewKeyRing(keyRepo repository.KeyRepository, accessTTL, rotationInterval time.Duration) (*KeyRing, error) {
+	kr := &KeyRing{
+		keyRepo:          keyRepo,
+		accessTTL:        accessTTL,
+		rotationInterval: rotationInterval,
+	}
+	if err := kr.Maintain(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Start 按间隔重新加载、轮换和清理密钥，直到stop被关闭
+// 间隔应小于 keyPublishDelay，保证新密钥启用前每个副本都已加载
+func (kr *KeyRing) Start(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := kr.Maintain(); err != nil {
+					log.Printf("Failed to maintain signing keys: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Maintain 重新加载密钥；没有可用密钥时立即生成，当前密钥到期时生成下一个，删除已过保留期的旧密钥
+func (kr *KeyRing) Maintain() error {
+	if err := kr.reload(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if _, err := kr.signingKey(); errors.Is(err, ErrNoSigningKey) {
+		// 多个副本可能同时生成，启用时间较晚的密钥胜出，另一个按旧密钥到期删除
+		log.Printf("No active signing key, generating one")
+		return kr.rotate(now, now)
+	}
+
+	if kr.rotationDue(now) {
+		acquired, err := kr.keyRepo.AcquireRotationLock(keyRotationLockTTL)
+		if err != nil {
+			return fmt.Errorf("failed to acquire rotation lock: %v", err)
+		}
+		if acquired {
+			if err := kr.rotate(now, now.Add(keyPublishDelay)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return kr.retire(now)
+}
+
+// JWKS 返回已加载的公钥集，包括尚未启用和仍在保留期内的密钥
+// 公钥集随 Maintain 定期重新加载，其他副本生成的密钥在下次加载后公布
+func (kr *KeyRing) JWKS() *jwks.Set {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.publicKeys
+}
+
+// signingKey 返回当前用于签名的密钥
+func (kr *KeyRing) signingKey() (*signingKey, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	if key := kr.current(time.Now()); key != nil {
+		return key, nil
+	}
+	return nil, ErrNoSigningKey
+}
+
+// current 返回已启用的密钥中最新的一个，调用方需持有锁
+func (kr *KeyRing) current(now time.Time) *signingKey {
+	for i := len(kr.keys) - 1; i >= 0; i-- {
+		if !kr.keys[i].activatesAt.After(now) {
+			return kr.keys[i]
+		}
+	}
+	return nil
+}
+
+// rotationDue 最新的密钥（可能尚未启用）已使用满轮换间隔
+func (kr *KeyRing) rotationDue(now time.Time) bool {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	if kr.rotationInterval <= 0 || len(kr.keys) == 0 {
+		return false
+	}
+	return !kr.keys[len(kr.keys)-1].activatesAt.Add(kr.rotationInterval).After(now)
+}
+
+// rotate 生成新密钥，在activatesAt启用
+func (kr *KeyRing) rotate(now, activatesAt time.Time) error {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	key := &repository.SigningKey{
+		Kid:         randomToken(12),
+		Seed:        privateKey.Seed(),
+		CreatedAt:   now,
+		ActivatesAt: activatesAt,
+	}
+	if err := kr.keyRepo.SaveKey(key); err != nil {
+		return fmt.Errorf("failed to save signing key: %v", err)
+	}
+	log.Printf("Generated signing key %s, active from %s", key.Kid, activatesAt.Format(time.RFC3339))
+	return kr.reload()
+}
+
+// retire 删除被取代已超过访问令牌有效期的密钥
+func (kr *KeyRing) retire(now time.Time) error {
+	kr.mu.RLock()
+	var expired []string
+	for i := 0; i+1 < len(kr.keys); i++ {
+		// 下一个密钥启用后不再用该密钥签名
+		if kr.keys[i+1].activatesAt.Add(kr.accessTTL + keyRetireGrace).Before(now) {
+			expired = append(expired, kr.keys[i].kid)
+		}
+	}
+	kr.mu.RUnlock()
+
+	if len(expired) == 0 {
+		return nil
+	}
+	if err := kr.keyRepo.DeleteKeys(expired...); err != nil {
+		return fmt.Errorf("failed to delete retired signing keys: %v", err)
+	}
+	log.Printf("Retired signing keys %v", expired)
+	return kr.reload()
+}
+
+// reload 从Redis加载全部密钥，失败时保留已加载的密钥
+func (kr *KeyRing) reload() error {
+	stored, err := kr.keyRepo.GetKeys()
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %v", err)
+	}
+
+	keys := make([]*signingKey, 0, len(stored))
+	publicKeys := &jwks.Set{Keys: make([]jwks.Key, 0, len(stored))}
+	for _, key := range stored {
+		if len(key.Seed) != ed25519.SeedSize {
+			log.Printf("Skipping invalid signing key %s", key.Kid)
+			continue
+		}
+		privateKey := ed25519.NewKeyFromSeed(key.Seed)
+		keys = append(keys, &signingKey{
+			kid:         key.Kid,
+			activatesAt: key.ActivatesAt,
+			privateKey:  privateKey,
+		})
+		publicKeys.Keys = append(publicKeys.Keys, jwks.NewKey(key.Kid, privateKey.Public().(ed25519.PublicKey)))
+	}
+
+	kr.mu.Lock()
+	kr.keys = keys
+	kr.publicKeys = publicKeys
+	kr.mu.Unlock()
+	return nil
+}
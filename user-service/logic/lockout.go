@@ -0,0 +1,122 @@
+package logic
+
+import (
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"blog/user-service/repository"
+	"context"
+	"time"
+)
+
+// 登录保护事件的动作
+const (
+	LockoutActionLock   = "lock"
+	LockoutActionUnlock = "unlock"
+)
+
+// recordLoginFailure 记录登录失败；触发锁定时发送Kafka事件，账户被锁定时邮件通知用户
+// 记录失败只打印日志，不影响返回给客户端的登录错误
+func (ul *UserLogic) recordLoginFailure(ctx context.Context, attempt *LoginAttempt, user *models.User) {
+	lockouts, err := ul.guard.Fail(attempt)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to record login failure: %v", err)
+	}
+
+	for _, lockout := range lockouts {
+		until := lockout.Until
+		event := &models.UserLockoutEvent{
+			Action:      LockoutActionLock,
+			Scope:       lockout.Scope,
+			Failures:    lockout.Failures,
+			LockedUntil: &until,
+			OccurredAt:  time.Now(),
+		}
+		if lockout.Scope == repository.ScopeIP {
+			event.IP = lockout.Subject
+			tracing.Printf(ctx, "IP %s blocked until %s after %d failed logins", lockout.Subject, until.Format(time.RFC3339), lockout.Failures)
+		} else {
+			event.Email = lockout.Subject
+			if user != nil {
+				event.UserID = user.ID
+			}
+			tracing.Printf(ctx, "Account %s locked until %s after %d failed logins", lockout.Subject, until.Format(time.RFC3339), lockout.Failures)
+		}
+		ul.sendLockoutEvent(ctx, event)
+
+		// 只通知已注册的用户，未注册的邮箱同样计数和锁定，但没有人可以通知
+		if lockout.Scope == repository.ScopeEmail && user != nil {
+			go func(ctx context.Context, to string, failures int, until time.Time) {
+				err := ul.emailSvc.SendAccountLockedEmail(to, failures, until)
+				if err != nil {
+					tracing.Printf(ctx, "Failed to send account locked email: %v", err)
+				}
+			}(tracing.Detach(ctx), user.Email, lockout.Failures, until)
+		}
+	}
+}
+
+// GetLoginStatus 查询邮箱和IP的登录保护状态，供管理员排查，参数为空的维度不查询
+func (ul *UserLogic) GetLoginStatus(email, ip string) ([]*LoginStatus, error) {
+	statuses := make([]*LoginStatus, 0, 2)
+	for _, subject := range lockoutSubjects(email, ip) {
+		status, err := ul.guard.Status(subject[0], subject[1])
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// UnlockLogin 管理员解除邮箱或IP的锁定并清空失败计数，参数为空的维度不处理
+func (ul *UserLogic) UnlockLogin(ctx context.Context, email, ip string, operator uint) error {
+	for _, subject := range lockoutSubjects(email, ip) {
+		scope, value := subject[0], subject[1]
+		if err := ul.guard.Unlock(scope, value); err != nil {
+			return err
+		}
+		tracing.Printf(ctx, "Admin %d unlocked login %s %s", operator, scope, value)
+
+		event := &models.UserLockoutEvent{
+			Action:     LockoutActionUnlock,
+			Scope:      scope,
+			Operator:   operator,
+			OccurredAt: time.Now(),
+		}
+		if scope == repository.ScopeIP {
+			event.IP = value
+		} else {
+			event.Email = normalizeEmail(value)
+			if user, err := ul.userRepo.GetUserByEmail(value); err == nil {
+				event.UserID = user.ID
+			}
+		}
+		ul.sendLockoutEvent(ctx, event)
+	}
+	return nil
+}
+
+// sendLockoutEvent 发送登录保护事件，以邮箱或IP为消息键
+func (ul *UserLogic) sendLockoutEvent(ctx context.Context, event *models.UserLockoutEvent) {
+	key := event.Email
+	if event.Scope == repository.ScopeIP {
+		key = event.IP
+	}
+	err := ul.producer.SendMessage(ctx, kafka.TopicUserLockout, key, event)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send user lockout event: %v", err)
+	}
+}
+
+// lockoutSubjects 按维度列出非空的邮箱和IP
+func lockoutSubjects(email, ip string) [][2]string {
+	var subjects [][2]string
+	if email != "" {
+		subjects = append(subjects, [2]string{repository.ScopeEmail, email})
+	}
+	if ip != "" {
+		subjects = append(subjects, [2]string{repository.ScopeIP, ip})
+	}
+	return subjects
+}
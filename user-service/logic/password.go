@@ -0,0 +1,121 @@
+package logic
+
+import (
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"blog/user-service/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidResetToken 重置令牌不存在、已过期或已使用
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// passwordResetCooldown 同一邮箱两次申请重置密码的最短间隔
+const passwordResetCooldown = time.Minute
+
+// ForgotPassword 申请重置密码，向注册邮箱发送带重置令牌的链接
+// 邮箱未注册或仍在冷却中时同样返回成功，不暴露邮箱是否已注册；邮件异步发送，响应时间不随邮箱是否存在变化
+func (ul *UserLogic) ForgotPassword(ctx context.Context, email string) error {
+	acquired, err := ul.emailRepo.AcquirePasswordResetCooldown(email, passwordResetCooldown)
+	if err != nil {
+		return fmt.Errorf("failed to check reset cooldown: %v", err)
+	}
+	if !acquired {
+		tracing.Printf(ctx, "Password reset requested again within cooldown, ignoring")
+		return nil
+	}
+
+	user, err := ul.userRepo.GetUserByEmail(email)
+	if err != nil {
+		tracing.Printf(ctx, "Password reset requested for unknown email: %v", err)
+		return nil
+	}
+
+	// Redis中只保存令牌摘要，新令牌使该用户之前的令牌失效
+	token := randomToken(32)
+	err = ul.emailRepo.SetPasswordResetToken(user.ID, hashToken(token), ul.resetTTL)
+	if err != nil {
+		return fmt.Errorf("failed to save reset token: %v", err)
+	}
+
+	link := ul.resetLink(token)
+	go func(ctx context.Context) {
+		err := ul.emailSvc.SendPasswordResetEmail(user.Email, link, int(ul.resetTTL/time.Minute))
+		if err != nil {
+			tracing.Printf(ctx, "Failed to send password reset email: %v", err)
+		}
+	}(tracing.Detach(ctx))
+
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，令牌随即失效，该用户所有设备上的登录都被注销
+func (ul *UserLogic) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	userID, err := ul.emailRepo.ConsumePasswordResetToken(hashToken(req.Token))
+	if errors.Is(err, repository.ErrResetTokenNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get reset token: %v", err)
+	}
+
+	user, err := ul.userRepo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	user.Password = string(hashedPassword)
+	err = ul.userRepo.UpdateUser(user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	// 忘记密码可能意味着账户已被他人登录，注销所有刷新令牌并吊销最近签发的访问令牌
+	// 注销失败时请求失败，其他设备上的登录可能仍然有效，需要重新申请重置
+	err = ul.tokens.RevokeUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	// 能收到重置邮件说明是账户本人，解除因登录失败产生的锁定
+	err = ul.guard.Unlock(repository.ScopeEmail, user.Email)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to unlock user %d after password reset: %v", user.ID, err)
+	}
+
+	// 发送Kafka事件
+	event := &models.UserPasswordResetEvent{
+		UserID:  user.ID,
+		Email:   user.Email,
+		ResetAt: time.Now(),
+	}
+	err = ul.producer.SendMessage(ctx, kafka.TopicUserPasswordReset, fmt.Sprintf("%d", user.ID), event)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send password reset event: %v", err)
+	}
+
+	return nil
+}
+
+// resetLink 在重置页面地址后附加重置令牌
+func (ul *UserLogic) resetLink(token string) string {
+	u, err := url.Parse(ul.resetURL)
+	if err != nil {
+		return ul.resetURL + "?token=" + token
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,268 @@
+package logic
+
+import (
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrIncorrectPassword 当前密码错误
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	// ErrUsernameTaken 用户名已被其他用户使用
+	ErrUsernameTaken = errors.New("username already exists")
+	// ErrEmailTaken 邮箱已被其他用户使用
+	ErrEmailTaken = errors.New("email already exists")
+	// ErrSameEmail 新邮箱与当前邮箱相同
+	ErrSameEmail = errors.New("new email is the same as the current email")
+	// ErrInvalidAvatarURL 头像地址不是http或https地址
+	ErrInvalidAvatarURL = errors.New("avatar_url must be an http or https URL")
+)
+
+// 用户资料变更事件中的字段名
+const (
+	FieldUsername    = "username"
+	FieldEmail       = "email"
+	FieldDisplayName = "display_name"
+	FieldAvatarURL   = "avatar_url"
+	FieldBio         = "bio"
+)
+
+// UpdateProfile 修改个人资料，只修改请求中出现且有变化的字段，有变化时发送 user.updated 事件
+func (ul *UserLogic) UpdateProfile(ctx context.Context, userID uint, req *models.UpdateProfileRequest) (*models.UserResponse, error) {
+	user, err := ul.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	if req.Username != nil && *req.Username != user.Username {
+		existing, err := ul.userRepo.GetUserByUsername(*req.Username)
+		if err == nil && existing.ID != user.ID {
+			return nil, ErrUsernameTaken
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to check username: %v", err)
+		}
+		user.Username = *req.Username
+		changed = append(changed, FieldUsername)
+	}
+	if req.DisplayName != nil && strings.TrimSpace(*req.DisplayName) != user.DisplayName {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+		changed = append(changed, FieldDisplayName)
+	}
+	if req.AvatarURL != nil && *req.AvatarURL != user.AvatarURL {
+		if *req.AvatarURL != "" && !isHTTPURL(*req.AvatarURL) {
+			return nil, ErrInvalidAvatarURL
+		}
+		user.AvatarURL = *req.AvatarURL
+		changed = append(changed, FieldAvatarURL)
+	}
+	if req.Bio != nil && strings.TrimSpace(*req.Bio) != user.Bio {
+		user.Bio = strings.TrimSpace(*req.Bio)
+		changed = append(changed, FieldBio)
+	}
+
+	if len(changed) == 0 {
+		return newUserResponse(user), nil
+	}
+
+	err = ul.userRepo.UpdateUser(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+	ul.sendUserUpdatedEvent(ctx, user, changed)
+
+	return newUserResponse(user), nil
+}
+
+// ChangePassword 使用当前密码修改密码，注销所有设备上的登录后为当前设备签发新的令牌
+// 当前密码错误计入登录失败，账户被锁定时返回 *LoginBlockedError
+func (ul *UserLogic) ChangePassword(ctx context.Context, userID uint, req *models.ChangePasswordRequest, clientIP string) (*models.TokenResponse, error) {
+	user, err := ul.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ul.verifyCurrentPassword(ctx, user, req.CurrentPassword, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+	user.Password = string(hashedPassword)
+	err = ul.userRepo.UpdateUser(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	return ul.reissueTokens(ctx, user)
+}
+
+// ChangeEmail 使用当前密码申请修改登录邮箱
+// 新邮箱先保存为待验证邮箱并发送验证码，登录邮箱不变；通过 VerifyEmail 验证后才替换，见 confirmEmailChange
+func (ul *UserLogic) ChangeEmail(ctx context.Context, userID uint, req *models.ChangeEmailRequest, clientIP string) (*models.UserResponse, error) {
+	user, err := ul.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ul.verifyCurrentPassword(ctx, user, req.Password, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(req.NewEmail, user.Email) {
+		return nil, ErrSameEmail
+	}
+	err = ul.checkEmailAvailable(user.ID, req.NewEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ul.userRepo.SetPendingEmail(user.ID, req.NewEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+	user.PendingEmail = req.NewEmail
+	tracing.Printf(ctx, "User %d requested email change, verification pending", user.ID)
+
+	// 发送验证码失败时不返回错误，用户可以稍后重新发送
+	err = ul.sendVerificationCode(user.PendingEmail)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send verification code: %v", err)
+	}
+
+	return newUserResponse(user), nil
+}
+
+// confirmEmailChange 新邮箱通过验证后替换登录邮箱
+// 原邮箱收到通知，注销所有设备上的登录，需要使用新邮箱重新登录
+func (ul *UserLogic) confirmEmailChange(ctx context.Context, user *models.User) error {
+	// 申请后新邮箱可能已被注册
+	err := ul.checkEmailAvailable(user.ID, user.PendingEmail)
+	if err != nil {
+		return err
+	}
+
+	// 先注销登录，失败时不替换邮箱，验证码仍然有效，可以重试
+	err = ul.tokens.RevokeUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	oldEmail := user.Email
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	user.IsVerified = true
+	err = ul.userRepo.UpdateUser(user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+	tracing.Printf(ctx, "User %d changed email", user.ID)
+
+	err = ul.emailRepo.DeleteVerificationCode(user.Email)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to delete verification code: %v", err)
+	}
+
+	go func(ctx context.Context) {
+		err := ul.emailSvc.SendEmailChangedEmail(oldEmail, user.Email)
+		if err != nil {
+			tracing.Printf(ctx, "Failed to send email changed notification: %v", err)
+		}
+	}(tracing.Detach(ctx))
+
+	ul.sendUserUpdatedEvent(ctx, user, []string{FieldEmail})
+	return nil
+}
+
+// checkEmailAvailable 邮箱未被其他用户使用
+func (ul *UserLogic) checkEmailAvailable(userID uint, email string) error {
+	existing, err := ul.userRepo.GetUserByEmail(email)
+	if err == nil && existing.ID != userID {
+		return ErrEmailTaken
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to check email: %v", err)
+	}
+	return nil
+}
+
+// getUser 获取用户，不存在时返回ErrUserNotFound
+func (ul *UserLogic) getUser(userID uint) (*models.User, error) {
+	user, err := ul.userRepo.GetUserByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	return user, nil
+}
+
+// verifyCurrentPassword 校验当前密码，与登录共用失败计数和锁定，防止持有访问令牌的人猜测密码
+func (ul *UserLogic) verifyCurrentPassword(ctx context.Context, user *models.User, password, clientIP string) error {
+	attempt, err := ul.guard.Begin(user.Email, clientIP)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err != nil {
+		ul.recordLoginFailure(ctx, attempt, user)
+		return ErrIncorrectPassword
+	}
+
+	err = ul.guard.Succeed(attempt)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to clear login failures: %v", err)
+	}
+	return nil
+}
+
+// reissueTokens 注销用户所有设备上的登录，并为当前设备签发新的令牌
+// 注销失败时返回错误，不签发新令牌
+func (ul *UserLogic) reissueTokens(ctx context.Context, user *models.User) (*models.TokenResponse, error) {
+	err := ul.tokens.RevokeUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	return ul.tokens.Issue(user)
+}
+
+// sendUserUpdatedEvent 发送用户资料变更事件
+func (ul *UserLogic) sendUserUpdatedEvent(ctx context.Context, user *models.User, changed []string) {
+	event := &models.UserUpdatedEvent{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Bio:         user.Bio,
+		Changed:     changed,
+		UpdatedAt:   time.Now(),
+	}
+	err := ul.producer.SendMessage(ctx, kafka.TopicUserUpdated, fmt.Sprintf("%d", user.ID), event)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send user updated event: %v", err)
+	}
+}
+
+// isHTTPURL 是否为带主机名的http或https地址
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
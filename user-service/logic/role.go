@@ -0,0 +1,173 @@
+package logic
+
+import (
+	"blog/shared/auth"
+	"blog/shared/kafka"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"blog/user-service/repository"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidRole 未定义的角色
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrSelfRoleChange 管理员不能修改自己的角色，避免误操作后没有管理员
+	ErrSelfRoleChange = errors.New("cannot change your own role")
+)
+
+// 角色变更记录分页
+const (
+	defaultAuditPageSize = 20
+	maxAuditPageSize     = 100
+)
+
+// bootstrapReason 按配置初始化管理员时变更记录中的原因
+const bootstrapReason = "bootstrap admin from config"
+
+// rolePermissions 各角色拥有的权限，签发访问令牌时写入permissions声明
+var rolePermissions = map[string][]string{
+	auth.RoleUser:      {},
+	auth.RoleModerator: {auth.PermCommentsModerate},
+	auth.RoleAdmin:     {auth.PermProductsWrite, auth.PermCommentsModerate, auth.PermUsersManage, auth.PermGatewayAdmin},
+}
+
+// roleOrder 角色列表按权限从少到多排列
+var roleOrder = []string{auth.RoleUser, auth.RoleModerator, auth.RoleAdmin}
+
+// Permissions 角色拥有的权限，未定义的角色没有任何权限
+func Permissions(role string) []string {
+	return append([]string{}, rolePermissions[role]...)
+}
+
+// ListRoles 列出所有角色及其权限
+func (ul *UserLogic) ListRoles() []models.RoleResponse {
+	roles := make([]models.RoleResponse, 0, len(roleOrder))
+	for _, role := range roleOrder {
+		roles = append(roles, models.RoleResponse{Name: role, Permissions: Permissions(role)})
+	}
+	return roles
+}
+
+// AssignRole 管理员为用户分配角色，变更与变更记录在同一事务中写入
+// 角色失去权限时注销该用户所有设备上的登录，已签发的访问令牌不再带有旧权限；获得权限时用户刷新令牌即可生效
+func (ul *UserLogic) AssignRole(ctx context.Context, userID uint, req *models.AssignRoleRequest, operator uint) (*models.UserResponse, error) {
+	if userID == operator {
+		return nil, ErrSelfRoleChange
+	}
+	if _, ok := rolePermissions[req.Role]; !ok {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := ul.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 角色未变化时不写变更记录
+	if user.Role != req.Role {
+		err = ul.changeRole(ctx, user, req.Role, operator, req.Reason)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newUserResponse(user), nil
+}
+
+// GetRoleAuditLogs 分页查询角色变更记录，userID 为0时查询所有用户
+// 页码从1开始，每页条数超出范围时使用默认值
+func (ul *UserLogic) GetRoleAuditLogs(userID uint, page, pageSize int) ([]models.RoleAuditLog, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > maxAuditPageSize {
+		pageSize = defaultAuditPageSize
+	}
+	logs, total, err := ul.userRepo.ListRoleAuditLogs(userID, page, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list role audit logs: %v", err)
+	}
+	return logs, total, nil
+}
+
+// BootstrapAdmins 启动时把配置中的邮箱设为管理员，用于创建第一个管理员
+// 邮箱未注册或已是管理员时跳过，失败只打印日志
+func (ul *UserLogic) BootstrapAdmins(ctx context.Context, emails []string) {
+	for _, email := range emails {
+		user, err := ul.userRepo.GetUserByEmail(email)
+		if err != nil {
+			tracing.Printf(ctx, "Skipping bootstrap admin %s: %v", email, err)
+			continue
+		}
+		if user.Role == auth.RoleAdmin {
+			continue
+		}
+		if err := ul.changeRole(ctx, user, auth.RoleAdmin, 0, bootstrapReason); err != nil {
+			tracing.Printf(ctx, "Failed to bootstrap admin %s: %v", email, err)
+		}
+	}
+}
+
+// changeRole 修改用户角色并写入变更记录，成功后更新 user.Role、按需注销登录并发送Kafka事件
+func (ul *UserLogic) changeRole(ctx context.Context, user *models.User, role string, operator uint, reason string) error {
+	audit := &models.RoleAuditLog{
+		UserID:     user.ID,
+		OldRole:    user.Role,
+		NewRole:    role,
+		OperatorID: operator,
+		Reason:     reason,
+	}
+	err := ul.userRepo.ChangeUserRole(audit)
+	if errors.Is(err, repository.ErrRoleConflict) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to change role: %v", err)
+	}
+	tracing.Printf(ctx, "User %d changed role of user %d from %s to %s", operator, user.ID, audit.OldRole, role)
+
+	if losesPermissions(audit.OldRole, role) {
+		if err := ul.tokens.RevokeUser(ctx, user.ID); err != nil {
+			tracing.Printf(ctx, "Failed to revoke sessions of user %d after role change: %v", user.ID, err)
+		}
+	}
+	user.Role = role
+
+	// 发送Kafka事件
+	event := &models.UserRoleChangedEvent{
+		UserID:     user.ID,
+		Email:      user.Email,
+		OldRole:    audit.OldRole,
+		NewRole:    role,
+		OperatorID: operator,
+		Reason:     reason,
+		ChangedAt:  audit.CreatedAt,
+	}
+	err = ul.producer.SendMessage(ctx, kafka.TopicUserRoleChange, fmt.Sprintf("%d", user.ID), event)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to send user role change event: %v", err)
+	}
+	return nil
+}
+
+// losesPermissions 从旧角色改为新角色时是否会失去某项权限
+func losesPermissions(oldRole, newRole string) bool {
+	for _, p := range rolePermissions[oldRole] {
+		found := false
+		for _, q := range rolePermissions[newRole] {
+			if p == q {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
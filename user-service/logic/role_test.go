@@ -0,0 +1,33 @@
+package logic
+
+import (
+	"blog/shared/auth"
+	"testing"
+)
+
+func TestLosesPermissions(t *testing.T) {
+	tests := []struct {
+		oldRole, newRole string
+		want             bool
+	}{
+		{auth.RoleUser, auth.RoleUser, false},
+		{auth.RoleUser, auth.RoleModerator, false},
+		{auth.RoleUser, auth.RoleAdmin, false},
+		{auth.RoleModerator, auth.RoleAdmin, false},
+		{auth.RoleModerator, auth.RoleModerator, false},
+		{auth.RoleAdmin, auth.RoleAdmin, false},
+		{auth.RoleModerator, auth.RoleUser, true},
+		{auth.RoleAdmin, auth.RoleModerator, true},
+		{auth.RoleAdmin, auth.RoleUser, true},
+		{auth.RoleAdmin, "unknown", true},
+		{"unknown", auth.RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.oldRole+"->"+tt.newRole, func(t *testing.T) {
+			if got := losesPermissions(tt.oldRole, tt.newRole); got != tt.want {
+				t.Errorf("losesPermissions(%q, %q) = %v, want %v", tt.oldRole, tt.newRole, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package logic
+
+import (
+	"blog/shared/auth"
+	"blog/shared/models"
+	"blog/shared/tracing"
+	"blog/user-service/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌不存在、已过期或已注销
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused 已轮换的刷新令牌被再次使用，整个令牌家族已注销
+	ErrRefreshTokenReused = errors.New("refresh token reused, please login again")
+)
+
+// TokenIssuer 签发短期访问令牌和可轮换的刷新令牌
+//
+// 每次登录创建一个令牌家族；刷新时旧令牌作废并签发新令牌，
+// 已作废的令牌再次出现说明令牌可能被盗用，注销整个家族并吊销最近签发的访问令牌。
+// 刷新时重新读取用户，访问令牌中的邮箱、验证状态、角色和权限随之更新
+type TokenIssuer struct {
+	keyRing     *KeyRing
+	userRepo    repository.UserRepository
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	tokenRepo   repository.TokenRepository
+	revocations *auth.RevocationList
+}
+
+// NewTokenIssuer 创建令牌签发器
+func NewTokenIssuer(keyRing *KeyRing, userRepo repository.UserRepository, accessTTL, refreshTTL time.Duration, tokenRepo repository.TokenRepository, revocations *auth.RevocationList) *TokenIssuer {
+	return &TokenIssuer{
+		keyRing:     keyRing,
+		userRepo:    userRepo,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+		tokenRepo:   tokenRepo,
+		revocations: revocations,
+	}
+}
+
+// Issue 登录时签发令牌，创建新的令牌家族
+func (ti *TokenIssuer) Issue(user *models.User) (*models.TokenResponse, error) {
+	record := &repository.RefreshToken{UserID: user.ID, Email: user.Email, Family: randomToken(16)}
+
+	access, family, err := ti.signAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := randomToken(32)
+	err = ti.tokenRepo.CreateFamily(hashToken(refresh), record, family, ti.refreshTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refresh token: %v", err)
+	}
+
+	return ti.response(access, refresh), nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，旧刷新令牌随即失效
+func (ti *TokenIssuer) Refresh(ctx context.Context, refreshToken string) (*models.TokenResponse, error) {
+	oldHash := hashToken(refreshToken)
+	record, err := ti.tokenRepo.GetRefreshToken(oldHash)
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %v", err)
+	}
+
+	// 用户已不存在时刷新令牌随之失效
+	user, err := ti.userRepo.GetUserByID(record.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	access, family, err := ti.signAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := randomToken(32)
+	rotated, err := ti.tokenRepo.RotateRefreshToken(oldHash, hashToken(refresh), record, family, ti.refreshTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	if !rotated {
+		// 旧令牌已被使用过或家族已注销，注销家族使攻击者和用户手中的令牌都失效
+		tracing.Printf(ctx, "Refresh token reuse detected for user %d, revoking token family", record.UserID)
+		if err := ti.revokeFamily(ctx, record.Family); err != nil {
+			tracing.Printf(ctx, "Failed to revoke token family: %v", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	return ti.response(access, refresh), nil
+}
+
+// Revoke 注销刷新令牌所属的令牌家族，并吊销该家族最近签发的访问令牌
+func (ti *TokenIssuer) Revoke(ctx context.Context, refreshToken string) error {
+	record, err := ti.tokenRepo.GetRefreshToken(hashToken(refreshToken))
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token: %v", err)
+	}
+	return ti.revokeFamily(ctx, record.Family)
+}
+
+// RevokeUser 注销用户的全部令牌家族，所有设备上的登录随之失效
+// 某个家族注销失败时继续注销其余家族，返回第一个错误
+func (ti *TokenIssuer) RevokeUser(ctx context.Context, userID uint) error {
+	families, err := ti.tokenRepo.TakeUserFamilies(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get token families: %v", err)
+	}
+
+	var firstErr error
+	for _, family := range families {
+		if err := ti.revokeFamily(ctx, family); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// revokeFamily 删除令牌家族并吊销其最近签发的访问令牌
+func (ti *TokenIssuer) revokeFamily(ctx context.Context, family string) error {
+	state, err := ti.tokenRepo.DeleteFamily(family)
+	if err != nil {
+		return fmt.Errorf("failed to delete token family: %v", err)
+	}
+	if state == nil {
+		return nil
+	}
+	if err := ti.revocations.Revoke(ctx, state.AccessJTI, state.AccessExp); err != nil {
+		return fmt.Errorf("failed to revoke access token: %v", err)
+	}
+	return nil
+}
+
+// signAccessToken 用当前签名密钥签发访问令牌（EdDSA），返回令牌和需要记录到家族中的令牌信息
+// verified 声明为邮箱是否已验证，网关和各服务据此拒绝未验证用户的写操作；
+// role 和 permissions 声明为用户的角色及其权限，网关和各服务按权限做访问控制
+func (ti *TokenIssuer) signAccessToken(user *models.User) (string, *repository.TokenFamily, error) {
+	key, err := ti.keyRing.signingKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ti.accessTTL)
+	jti := randomToken(16)
+
+	claims := jwt.MapClaims{
+		"sub":         user.ID,
+		"email":       user.Email,
+		"verified":    user.IsVerified,
+		"role":        user.Role,
+		"permissions": Permissions(user.Role),
+		"iat":         now.Unix(),
+		"exp":         expiresAt.Unix(),
+		"iss":         "micblog",
+		"jti":         jti,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.kid
+	signed, err := token.SignedString(key.privateKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	return signed, &repository.TokenFamily{AccessJTI: jti, AccessExp: expiresAt}, nil
+}
+
+// response 构造令牌响应
+func (ti *TokenIssuer) response(access, refresh string) *models.TokenResponse {
+	return &models.TokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(ti.accessTTL / time.Second),
+	}
+}
+
+// randomToken 生成n字节随机数的URL安全编码
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand unavailable: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken 刷新令牌的摘要，Redis中只保存摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,44 +1,95 @@
 package logic
 
 import (
+	"blog/shared/auth"
 	"blog/shared/email"
 	"blog/shared/kafka"
 	"blog/shared/models"
+	"blog/shared/tracing"
+	"blog/shared/userdata"
 	"blog/user-service/repository"
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"log"
 	"math/big"
 	"time"
 
 	"github.com/Shopify/sarama"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+var (
+	// ErrInvalidVerificationCode 验证码错误、不存在或已过期
+	ErrInvalidVerificationCode = errors.New("invalid or expired verification code")
+	// ErrVerificationAttemptsExceeded 验证码错误次数过多，验证码已作废
+	ErrVerificationAttemptsExceeded = errors.New("too many invalid verification attempts, please request a new code")
+)
+
+// VerificationPolicy 邮箱验证码策略
+type VerificationPolicy struct {
+	CodeTTL        time.Duration // 验证码有效期
+	MaxAttempts    int           // 每个验证码允许的尝试次数
+	ResendCooldown time.Duration // 同一邮箱两次发送的最短间隔
+	DailyLimit     int           // 同一邮箱24小时内最多发送的次数
+}
+
+// VerificationThrottledError 发送验证码过于频繁，RetryAfter 后可以再次发送
+type VerificationThrottledError struct {
+	DailyLimit bool // 是否因达到每日上限被拒绝
+	RetryAfter time.Duration
+}
+
+// Error 实现error接口
+func (e *VerificationThrottledError) Error() string {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if e.DailyLimit {
+		return fmt.Sprintf("daily verification code limit reached, try again in %d seconds", seconds)
+	}
+	return fmt.Sprintf("verification code sent recently, try again in %d seconds", seconds)
+}
+
 // UserLogic 用户业务逻辑
 type UserLogic struct {
 	userRepo  repository.UserRepository
 	emailRepo repository.EmailRepository
 	producer  *kafka.Producer
 	emailSvc  *email.EmailService
-	jwtSecret string
+	tokens    *TokenIssuer
+	guard     *LoginGuard
+	verify    VerificationPolicy
+	resetURL  string        // 前端重置密码页面
+	resetTTL  time.Duration // 重置令牌有效期
+
+	dataRepo   repository.DataRequestRepository
+	exports    *userdata.Store // 各服务导出的数据
+	dataPolicy DataRequestPolicy
 }
 
 // NewUserLogic 创建用户业务逻辑
-func NewUserLogic(userRepo repository.UserRepository, emailRepo repository.EmailRepository, producer *kafka.Producer, emailConfig email.EmailConfig, jwtSecret string) *UserLogic {
+func NewUserLogic(userRepo repository.UserRepository, emailRepo repository.EmailRepository, producer *kafka.Producer, emailConfig email.EmailConfig, tokens *TokenIssuer, guard *LoginGuard, verify VerificationPolicy, resetURL string, resetTTL time.Duration, dataRepo repository.DataRequestRepository, exports *userdata.Store, dataPolicy DataRequestPolicy) *UserLogic {
 	emailSvc := email.NewEmailService(&emailConfig)
 	return &UserLogic{
 		userRepo:  userRepo,
 		emailRepo: emailRepo,
 		producer:  producer,
 		emailSvc:  emailSvc,
-		jwtSecret: jwtSecret,
+		tokens:    tokens,
+		guard:     guard,
+		verify:    verify,
+		resetURL:  resetURL,
+		resetTTL:  resetTTL,
+
+		dataRepo:   dataRepo,
+		exports:    exports,
+		dataPolicy: dataPolicy,
 	}
 }
 
 // Register 用户注册
-func (ul *UserLogic) Register(req *models.UserRegisterRequest) (*models.UserResponse, error) {
+func (ul *UserLogic) Register(ctx context.Context, req *models.UserRegisterRequest) (*models.UserResponse, error) {
 	// 检查邮箱是否已存在
 	existingUser, err := ul.userRepo.GetUserByEmail(req.Email)
 	if err == nil && existingUser != nil {
@@ -57,6 +108,7 @@ func (ul *UserLogic) Register(req *models.UserRegisterRequest) (*models.UserResp
 		Email:      req.Email,
 		Password:   string(hashedPassword),
 		IsVerified: false,
+		Role:       auth.RoleUser,
 	}
 
 	err = ul.userRepo.CreateUser(user)
@@ -64,18 +116,10 @@ func (ul *UserLogic) Register(req *models.UserRegisterRequest) (*models.UserResp
 		return nil, fmt.Errorf("failed to create user: %v", err)
 	}
 
-	// 发送验证邮件
-	verificationCode := ul.generateVerificationCode()
-	err = ul.emailSvc.SendVerificationEmail(user.Email, verificationCode)
-	if err != nil {
-		log.Printf("Failed to send verification email: %v", err)
-		// 不返回错误，用户可以稍后重新发送
-	}
-
-	// 保存验证码到Redis
-	err = ul.emailRepo.SetVerificationCode(user.Email, verificationCode, 10*time.Minute)
+	// 发送验证邮件，失败时不返回错误，用户可以稍后重新发送
+	err = ul.sendVerificationCode(user.Email)
 	if err != nil {
-		log.Printf("Failed to save verification code: %v", err)
+		tracing.Printf(ctx, "Failed to send verification code: %v", err)
 	}
 
 	// 发送Kafka事件
@@ -84,32 +128,41 @@ func (ul *UserLogic) Register(req *models.UserRegisterRequest) (*models.UserResp
 		Username: user.Username,
 		Email:    user.Email,
 	}
-	err = ul.producer.SendMessage(kafka.TopicUserRegister, fmt.Sprintf("%d", user.ID), event)
+	err = ul.producer.SendMessage(ctx, kafka.TopicUserRegister, fmt.Sprintf("%d", user.ID), event)
 	if err != nil {
-		log.Printf("Failed to send user register event: %v", err)
+		tracing.Printf(ctx, "Failed to send user register event: %v", err)
 	}
 
-	return &models.UserResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Email:      user.Email,
-		IsVerified: user.IsVerified,
-		CreatedAt:  user.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return newUserResponse(user), nil
 }
 
-// Login 用户登录
-func (ul *UserLogic) Login(req *models.UserLoginRequest) (*models.UserResponse, string, error) {
-	// 获取用户
+// Login 用户登录，clientIP 为客户端地址，用于按IP统计登录失败
+// 邮箱或IP被锁定、失败过多仍需等待时返回 *LoginBlockedError，不再校验密码
+func (ul *UserLogic) Login(ctx context.Context, req *models.UserLoginRequest, clientIP string) (*models.UserResponse, *models.TokenResponse, error) {
+	// 登录保护不可用时拒绝登录，避免无限制地尝试密码
+	attempt, err := ul.guard.Begin(req.Email, clientIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 获取用户，未注册的邮箱同样计入失败
 	user, err := ul.userRepo.GetUserByEmail(req.Email)
 	if err != nil {
-		return nil, "", fmt.Errorf("invalid email or password")
+		ul.recordLoginFailure(ctx, attempt, nil)
+		return nil, nil, fmt.Errorf("invalid email or password")
 	}
 
 	// 验证密码
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
-		return nil, "", fmt.Errorf("invalid email or password")
+		ul.recordLoginFailure(ctx, attempt, user)
+		return nil, nil, fmt.Errorf("invalid email or password")
+	}
+
+	// 登录成功清空该邮箱的失败计数
+	err = ul.guard.Succeed(attempt)
+	if err != nil {
+		tracing.Printf(ctx, "Failed to clear login failures: %v", err)
 	}
 
 	// 发送Kafka事件
@@ -117,60 +170,69 @@ func (ul *UserLogic) Login(req *models.UserLoginRequest) (*models.UserResponse,
 		UserID: user.ID,
 		Email:  user.Email,
 	}
-	err = ul.producer.SendMessage(kafka.TopicUserLogin, fmt.Sprintf("%d", user.ID), event)
+	err = ul.producer.SendMessage(ctx, kafka.TopicUserLogin, fmt.Sprintf("%d", user.ID), event)
 	if err != nil {
-		log.Printf("Failed to send user login event: %v", err)
+		tracing.Printf(ctx, "Failed to send user login event: %v", err)
 	}
 
-	// 生成JWT Token（HS256）
-	token, err := ul.generateJWTToken(user.ID, user.Email)
+	// 签发访问令牌和刷新令牌，未验证邮箱的用户可以登录，但网关和各服务拒绝其写操作
+	tokens, err := ul.tokens.Issue(user)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate token: %v", err)
+		return nil, nil, err
 	}
 
-	return &models.UserResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Email:      user.Email,
-		IsVerified: user.IsVerified,
-		CreatedAt:  user.CreatedAt.Format(time.RFC3339),
-	}, token, nil
+	return newUserResponse(user), tokens, nil
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对
+func (ul *UserLogic) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenResponse, error) {
+	return ul.tokens.Refresh(ctx, refreshToken)
 }
 
-// generateJWTToken 生成JWT Token
-func (ul *UserLogic) generateJWTToken(userID uint, email string) (string, error) {
-	now := time.Now()
-	claims := jwt.MapClaims{
-		"sub":   userID,
-		"email": email,
-		"iat":   now.Unix(),
-		"exp":   now.Add(24 * time.Hour).Unix(),
-		"iss":   "micblog",
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(ul.jwtSecret))
+// Logout 登出，注销本次登录的刷新令牌并吊销访问令牌
+func (ul *UserLogic) Logout(ctx context.Context, refreshToken string) error {
+	return ul.tokens.Revoke(ctx, refreshToken)
+}
+
+// VerifyEmail 验证邮箱，email 为注册时的邮箱或修改邮箱时的新邮箱
+// 每个验证码只允许尝试 MaxAttempts 次，用完后验证码作废，需要重新发送
+func (ul *UserLogic) VerifyEmail(ctx context.Context, email, code string) error {
+	// 获取用户，验证码按注册时的邮箱或待验证的新邮箱保存
+	user, pending, err := ul.getUserForVerification(email)
 	if err != nil {
-		return "", err
+		return err
+	}
+	codeEmail := user.Email
+	if pending {
+		codeEmail = user.PendingEmail
 	}
-	return signed, nil
-}
 
-// VerifyEmail 验证邮箱
-func (ul *UserLogic) VerifyEmail(email, code string) error {
-	// 从Redis获取验证码
-	storedCode, err := ul.emailRepo.GetVerificationCode(email)
+	// 先消耗一次尝试机会再比较，并发请求也不能超出次数
+	storedCode, remaining, err := ul.emailRepo.TakeVerificationAttempt(codeEmail, ul.verify.MaxAttempts)
+	if errors.Is(err, repository.ErrVerificationCodeNotFound) {
+		return ErrInvalidVerificationCode
+	}
+	if errors.Is(err, repository.ErrVerificationAttemptsExceeded) {
+		return ErrVerificationAttemptsExceeded
+	}
 	if err != nil {
-		return fmt.Errorf("verification code not found or expired")
+		return fmt.Errorf("failed to get verification code: %v", err)
 	}
 
-	if storedCode != code {
-		return fmt.Errorf("invalid verification code")
+	// 固定时间比较，响应时间不随匹配的位数变化
+	if subtle.ConstantTimeCompare([]byte(storedCode), []byte(code)) != 1 {
+		if remaining == 0 {
+			if err := ul.emailRepo.DeleteVerificationCode(codeEmail); err != nil {
+				tracing.Printf(ctx, "Failed to delete verification code: %v", err)
+			}
+			return ErrVerificationAttemptsExceeded
+		}
+		return fmt.Errorf("%w, %d attempts remaining", ErrInvalidVerificationCode, remaining)
 	}
 
-	// 获取用户
-	user, err := ul.userRepo.GetUserByEmail(email)
-	if err != nil {
-		return fmt.Errorf("user not found")
+	// 新邮箱通过验证后才替换登录邮箱
+	if pending {
+		return ul.confirmEmailChange(ctx, user)
 	}
 
 	// 更新用户验证状态
@@ -181,53 +243,85 @@ func (ul *UserLogic) VerifyEmail(email, code string) error {
 	}
 
 	// 删除验证码
-	err = ul.emailRepo.DeleteVerificationCode(email)
+	err = ul.emailRepo.DeleteVerificationCode(user.Email)
 	if err != nil {
-		log.Printf("Failed to delete verification code: %v", err)
+		tracing.Printf(ctx, "Failed to delete verification code: %v", err)
 	}
 
 	// 发送欢迎邮件
 	err = ul.emailSvc.SendWelcomeEmail(user.Email, user.Username)
 	if err != nil {
-		log.Printf("Failed to send welcome email: %v", err)
+		tracing.Printf(ctx, "Failed to send welcome email: %v", err)
 	}
 
 	return nil
 }
 
-// ResendVerificationCode 重新发送验证码
+// ResendVerificationCode 重新发送验证码，之前的验证码随之作废
+// 发送过于频繁时返回 *VerificationThrottledError
 func (ul *UserLogic) ResendVerificationCode(email string) error {
-	// 检查用户是否存在
-	user, err := ul.userRepo.GetUserByEmail(email)
+	// 检查用户是否存在，待验证的新邮箱同样可以重新发送
+	user, pending, err := ul.getUserForVerification(email)
 	if err != nil {
-		return fmt.Errorf("user not found")
+		return err
+	}
+	if pending {
+		return ul.sendVerificationCode(user.PendingEmail)
 	}
 
 	if user.IsVerified {
 		return fmt.Errorf("email already verified")
 	}
 
-	// 生成新的验证码
-	verificationCode := ul.generateVerificationCode()
+	return ul.sendVerificationCode(user.Email)
+}
 
-	// 发送验证邮件
-	err = ul.emailSvc.SendVerificationEmail(user.Email, verificationCode)
+// getUserForVerification 按登录邮箱或待验证的新邮箱获取用户，pending 表示匹配的是待验证的新邮箱
+func (ul *UserLogic) getUserForVerification(email string) (*models.User, bool, error) {
+	user, err := ul.userRepo.GetUserByEmail(email)
+	if err == nil {
+		return user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to get user: %v", err)
+	}
+	user, err = ul.userRepo.GetUserByPendingEmail(email)
 	if err != nil {
-		return fmt.Errorf("failed to send verification email: %v", err)
+		return nil, false, fmt.Errorf("user not found")
 	}
+	return user, true, nil
+}
 
-	// 保存验证码到Redis
-	err = ul.emailRepo.SetVerificationCode(user.Email, verificationCode, 10*time.Minute)
+// sendVerificationCode 生成并发送新的验证码，受发送冷却和每日上限限制
+func (ul *UserLogic) sendVerificationCode(email string) error {
+	retryAfter, err := ul.emailRepo.AcquireVerificationSend(email, ul.verify.ResendCooldown, ul.verify.DailyLimit)
+	if errors.Is(err, repository.ErrVerificationCooldown) || errors.Is(err, repository.ErrVerificationDailyLimit) {
+		return &VerificationThrottledError{
+			DailyLimit: errors.Is(err, repository.ErrVerificationDailyLimit),
+			RetryAfter: retryAfter,
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check verification cooldown: %v", err)
+	}
+
+	// 先保存再发送，用户收到的验证码一定可用
+	verificationCode := ul.generateVerificationCode()
+	err = ul.emailRepo.SetVerificationCode(email, verificationCode, ul.verify.CodeTTL)
 	if err != nil {
 		return fmt.Errorf("failed to save verification code: %v", err)
 	}
 
+	err = ul.emailSvc.SendVerificationEmail(email, verificationCode, int(ul.verify.CodeTTL/time.Minute))
+	if err != nil {
+		return fmt.Errorf("failed to send verification email: %v", err)
+	}
 	return nil
 }
 
 // HandleEmailVerification 处理邮箱验证消息
-func (ul *UserLogic) HandleEmailVerification(msg *sarama.ConsumerMessage) error {
-	log.Printf("Received email verification message: %s", string(msg.Value))
+func (ul *UserLogic) HandleEmailVerification(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	tracing.Printf(ctx, "Received email verification message: %s", string(msg.Value))
 	// 这里可以处理来自其他服务的邮箱验证请求
 	return nil
 }
@@ -235,7 +329,7 @@ func (ul *UserLogic) HandleEmailVerification(msg *sarama.ConsumerMessage) error
 // generateVerificationCode 生成验证码
 func (ul *UserLogic) generateVerificationCode() string {
 	// 生成6位数字验证码
-	max := big.NewInt(999999)
+	max := big.NewInt(1000000)
 	n, _ := rand.Int(rand.Reader, max)
 	return fmt.Sprintf("%06d", n.Int64())
 }
@@ -247,11 +341,38 @@ func (ul *UserLogic) GetUserProfile(userID uint) (*models.UserResponse, error) {
 		return nil, fmt.Errorf("user not found")
 	}
 
-	return &models.UserResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Email:      user.Email,
-		IsVerified: user.IsVerified,
-		CreatedAt:  user.CreatedAt.Format(time.RFC3339),
+	return newUserResponse(user), nil
+}
+
+// GetPublicProfile 获取用户的公开资料，供其他用户查看
+func (ul *UserLogic) GetPublicProfile(userID uint) (*models.PublicUserResponse, error) {
+	user, err := ul.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return &models.PublicUserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Bio:         user.Bio,
+		CreatedAt:   user.CreatedAt.Format(time.RFC3339),
 	}, nil
 }
+
+// newUserResponse 构造用户响应
+func newUserResponse(user *models.User) *models.UserResponse {
+	return &models.UserResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		IsVerified:   user.IsVerified,
+		PendingEmail: user.PendingEmail,
+		Role:         user.Role,
+		DisplayName:  user.DisplayName,
+		AvatarURL:    user.AvatarURL,
+		Bio:          user.Bio,
+		CreatedAt:    user.CreatedAt.Format(time.RFC3339),
+	}
+}
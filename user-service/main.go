@@ -1,30 +1,50 @@
 package main
 
 import (
+	"blog/shared/auth"
+	"blog/shared/database"
+	"blog/shared/health"
 	"blog/shared/kafka"
+	"blog/shared/registry"
+	"blog/shared/serviceauth"
+	"blog/shared/userdata"
 	"blog/user-service/config"
 	"blog/user-service/controller"
 	"blog/user-service/logic"
 	"blog/user-service/repository"
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// shutdownTimeout 关闭时等待处理中请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
+// keyReloadInterval 重新加载签名密钥的间隔，需小于新密钥从公布到启用的时间
+const keyReloadInterval = 30 * time.Second
+
+// dataRequestCheckInterval 检查未完成的个人数据请求和过期导出的间隔
+const dataRequestCheckInterval = time.Minute
+
 func main() {
 	// 初始化配置
 	cfg := config.LoadConfig()
 
 	// 初始化数据库
 	db := repository.InitDB(cfg.Database.DSN)
+	defer database.Close(db)
 
 	// 初始化Redis
 	redisClient := repository.InitRedis(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	defer redisClient.Close()
 
 	// 初始化Kafka生产者（可选）
 	var producer *kafka.Producer
 	var consumer *kafka.Consumer
+	var dataGroup *kafka.ConsumerGroup
 	if len(cfg.Kafka.Brokers) > 0 && cfg.Kafka.Brokers[0] != "" {
 		var err error
 		producer, err = kafka.NewProducer(cfg.Kafka.Brokers)
@@ -41,6 +61,14 @@ func main() {
 		} else {
 			defer consumer.Close()
 		}
+
+		// 各服务的个人数据处理结果使用消费者组，处理完成才提交位移，重启期间发送的结果不会丢失
+		dataGroup, err = kafka.NewConsumerGroup(cfg.Kafka.Brokers, "user-service")
+		if err != nil {
+			log.Printf("Failed to create Kafka consumer group: %v, continuing without data request progress", err)
+		} else {
+			defer dataGroup.Close()
+		}
 	} else {
 		log.Println("Kafka not configured, running without Kafka")
 	}
@@ -48,15 +76,96 @@ func main() {
 	// 初始化仓库
 	userRepo := repository.NewUserRepository(db)
 	emailRepo := repository.NewEmailRepository(redisClient)
+	tokenRepo := repository.NewTokenRepository(redisClient)
+	attemptRepo := repository.NewLoginAttemptRepository(redisClient)
+	dataRepo := repository.NewDataRequestRepository(db)
+	exportStore, err := userdata.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to init user data exports: %v", err)
+	}
+
+	// 签名密钥加密后保存在Redis中，各副本定期重新加载并按间隔轮换；加密密钥只由用户服务持有，未配置时拒绝启动
+	encryptionKey, err := repository.LoadEncryptionKey()
+	if err != nil {
+		log.Fatalf("Failed to load signing key encryption key: %v", err)
+	}
+	keyRepo, err := repository.NewKeyRepository(redisClient, encryptionKey)
+	if err != nil {
+		log.Fatalf("Failed to create key repository: %v", err)
+	}
+	accessTTL := time.Duration(cfg.JWT.AccessTTL) * time.Second
+	keyRing, err := logic.NewKeyRing(keyRepo, accessTTL, time.Duration(cfg.JWT.KeyRotationInterval)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	stopKeyRing := make(chan struct{})
+	defer close(stopKeyRing)
+	keyRing.Start(keyReloadInterval, stopKeyRing)
+
+	// 令牌签发：短期访问令牌和保存在Redis中的刷新令牌，吊销列表与网关共用
+	tokenIssuer := logic.NewTokenIssuer(keyRing, userRepo, accessTTL,
+		time.Duration(cfg.JWT.RefreshTTL)*time.Second,
+		tokenRepo, auth.NewRevocationList(redisClient))
+
+	// 登录保护：按邮箱和IP统计登录失败，逐次延长等待时间，达到阈值后临时锁定
+	guardCfg := cfg.LoginGuard
+	loginGuard := logic.NewLoginGuard(attemptRepo, logic.LoginPolicy{
+		Window:             time.Duration(guardCfg.Window) * time.Second,
+		FreeAttempts:       guardCfg.FreeAttempts,
+		BaseDelay:          time.Duration(guardCfg.BaseDelay) * time.Second,
+		MaxDelay:           time.Duration(guardCfg.MaxDelay) * time.Second,
+		EmailLockThreshold: guardCfg.EmailLockThreshold,
+		IPLockThreshold:    guardCfg.IPLockThreshold,
+		LockDuration:       time.Duration(guardCfg.LockDuration) * time.Second,
+	})
+
+	// 邮箱验证码：限制每个验证码的尝试次数和发送频率
+	verification := logic.VerificationPolicy{
+		CodeTTL:        time.Duration(cfg.Verification.CodeTTL) * time.Second,
+		MaxAttempts:    cfg.Verification.MaxAttempts,
+		ResendCooldown: time.Duration(cfg.Verification.ResendCooldown) * time.Second,
+		DailyLimit:     cfg.Verification.DailyLimit,
+	}
+
+	// 个人数据导出和账户注销：分发给各服务，未完成时按间隔重新发送
+	dataPolicy := logic.DataRequestPolicy{
+		Services:      cfg.DataRequests.Services,
+		RetryInterval: time.Duration(cfg.DataRequests.RetryInterval) * time.Second,
+		MaxAttempts:   cfg.DataRequests.MaxAttempts,
+		ExportTTL:     time.Duration(cfg.DataRequests.ExportTTL) * time.Second,
+	}
 
 	// 初始化业务逻辑
-	userLogic := logic.NewUserLogic(userRepo, emailRepo, producer, cfg.Email, cfg.JWT.Secret)
+	userLogic := logic.NewUserLogic(userRepo, emailRepo, producer, cfg.Email, tokenIssuer, loginGuard, verification,
+		cfg.PasswordReset.URL, time.Duration(cfg.PasswordReset.TTL)*time.Second, dataRepo, exportStore, dataPolicy)
+
+	// 按配置初始化管理员，之后由管理员通过接口分配角色
+	userLogic.BootstrapAdmins(context.Background(), cfg.Roles.BootstrapAdmins)
+
+	// 各副本定期重新发送未完成的个人数据请求，并删除过期的导出
+	stopDataRequests := make(chan struct{})
+	defer close(stopDataRequests)
+	userLogic.StartDataRequests(dataRequestCheckInterval, stopDataRequests)
 
 	// 初始化控制器
-	userController := controller.NewUserController(userLogic)
+	userController := controller.NewUserController(userLogic, keyRing)
+
+	// 就绪检查：MySQL、Redis（验证码、刷新令牌和登录失败计数）和Kafka生产者
+	healthChecker := health.NewChecker("user-service")
+	healthChecker.AddCheck("mysql", health.DBCheck(db))
+	healthChecker.AddCheck("redis", health.RedisCheck(redisClient))
+	if producer != nil {
+		healthChecker.AddCheck("kafka", health.KafkaCheck(producer))
+	}
+
+	// 校验网关的服务令牌
+	verifier, err := serviceauth.NewVerifier("user-service", cfg.ServiceAuth)
+	if err != nil {
+		log.Fatalf("Invalid service auth config: %v", err)
+	}
 
 	// 启动HTTP服务器
-	server := controller.NewServer(cfg.Server.Port, userController)
+	server := controller.NewServer(cfg.Server.Port, userController, verifier, healthChecker)
 
 	// 启动Kafka消费者（如果可用）
 	if consumer != nil {
@@ -68,6 +177,18 @@ func main() {
 		}()
 	}
 
+	// 各服务处理个人数据请求的结果
+	if dataGroup != nil {
+		dataGroup.Consume([]string{kafka.TopicUserDataProgress}, userLogic.HandleDataProgress)
+	}
+
+	// 注册到服务注册中心，供网关发现
+	deregister, err := registry.RegisterLocal(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, "user-service", cfg.Server.Port)
+	if err != nil {
+		log.Printf("Failed to register service: %v, continuing without service registry", err)
+		deregister = func() {}
+	}
+
 	log.Printf("User service starting on port %s", cfg.Server.Port)
 
 	// 在goroutine中启动服务器
@@ -83,5 +204,18 @@ func main() {
 	<-quit
 
 	log.Println("User service shutting down...")
-	server.Shutdown()
+
+	// 先摘除实例：就绪检查返回503并从注册中心注销，等待网关刷新实例列表后不再转发新请求
+	healthChecker.SetShuttingDown()
+	deregister()
+	time.Sleep(registry.DeregisterDelay)
+
+	// 等待处理中的请求完成
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("User service forced to shutdown: %v", err)
+	}
+
+	// 返回后按注册的相反顺序执行defer：停止Kafka消费者、刷新并关闭生产者、关闭Redis和数据库连接池
 }
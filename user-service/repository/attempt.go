@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 登录保护的统计维度
+const (
+	ScopeEmail = "email"
+	ScopeIP    = "ip"
+)
+
+// LoginFailures 窗口内的登录失败记录
+type LoginFailures struct {
+	Count       int
+	LastFailure time.Time
+}
+
+// AttemptSubject 预占登录尝试时检查的一个统计维度
+type AttemptSubject struct {
+	Scope     string
+	Subject   string
+	Threshold int             // 窗口内失败次数达到该值时拒绝尝试，0表示不限
+	Delays    []time.Duration // 下标为失败次数，值为距最近一次失败需要等待的时间；超出长度时取最后一个，为空表示不需要等待
+}
+
+// 预占被拒绝的原因
+const (
+	AttemptLocked = "locked" // 已锁定，或失败次数已达到阈值
+	AttemptDelay  = "delay"  // 距最近一次失败的时间不够
+)
+
+// AttemptReservation 预占的结果
+type AttemptReservation struct {
+	Blocked    *AttemptSubject // 拒绝尝试的维度，为nil表示已预占
+	Reason     string
+	RetryAfter time.Duration
+	Counts     []int // 预占后各维度的失败次数，与传入的维度一一对应
+}
+
+// LoginAttemptRepository 登录失败计数和锁定仓库接口
+type LoginAttemptRepository interface {
+	ReserveAttempt(subjects []AttemptSubject, window time.Duration) (*AttemptReservation, error)
+	ReleaseAttempt(scope, subject string) error
+	GetFailures(scope, subject string) (*LoginFailures, error)
+	ClearFailures(scope, subject string) error
+	SetLock(scope, subject string, until time.Time) error
+	GetLock(scope, subject string) (time.Time, error)
+	DeleteLock(scope, subject string) error
+}
+
+// loginAttemptRepository 登录失败计数和锁定仓库实现
+//
+// login:failures:<维度>:<邮箱或IP> 为失败次数和最近一次失败的时间，从第一次失败起保留一个窗口；
+// 每次尝试在校验密码前预先记为失败，成功后再撤销，进行中的尝试同样计入；
+// login:lock:<维度>:<邮箱或IP> 为锁定截止时间，到期自动删除
+type loginAttemptRepository struct {
+	redis *redis.Client
+}
+
+// NewLoginAttemptRepository 创建登录失败计数仓库
+func NewLoginAttemptRepository(redis *redis.Client) LoginAttemptRepository {
+	return &loginAttemptRepository{redis: redis}
+}
+
+// reserveAttemptScript 原子地检查各维度的锁定、失败次数阈值和等待时间，都通过时把本次尝试预先记为失败
+// KEYS 为每个维度的失败记录和锁定两个键；ARGV 为当前时间、窗口，以及每个维度的阈值、等待时间个数和各等待时间（毫秒）
+// 被拒绝时返回 {维度序号, 原因, 需要等待的毫秒数}，预占成功时返回 {0, 空字符串, 0, 各维度的失败次数...}
+var reserveAttemptScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local pos = 3
+for i = 1, #KEYS / 2 do
+	local failures, lock = KEYS[2 * i - 1], KEYS[2 * i]
+	local threshold = tonumber(ARGV[pos])
+	local n = tonumber(ARGV[pos + 1])
+	local lockedUntil = tonumber(redis.call('GET', lock) or '0')
+	if lockedUntil > now then
+		return {i, 'locked', lockedUntil - now}
+	end
+	local count = tonumber(redis.call('HGET', failures, 'count') or '0')
+	if threshold > 0 and count >= threshold then
+		local ttl = redis.call('PTTL', failures)
+		if ttl < 0 then
+			ttl = window
+		end
+		return {i, 'locked', ttl}
+	end
+	if n > 0 and count > 0 then
+		local delay = tonumber(ARGV[pos + 1 + math.min(count + 1, n)])
+		local last = tonumber(redis.call('HGET', failures, 'last') or '0')
+		if last + delay > now then
+			return {i, 'delay', last + delay - now}
+		end
+	end
+	pos = pos + 2 + n
+end
+local result = {0, '', 0}
+for i = 1, #KEYS / 2 do
+	local failures = KEYS[2 * i - 1]
+	local count = redis.call('HINCRBY', failures, 'count', 1)
+	redis.call('HSET', failures, 'last', ARGV[1])
+	if count == 1 then
+		redis.call('PEXPIRE', failures, window)
+	end
+	result[3 + i] = count
+end
+return result
+`)
+
+// releaseAttemptScript 撤销一次预先记入的失败，失败记录已过期时不做修改
+var releaseAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 and tonumber(redis.call('HGET', KEYS[1], 'count') or '0') > 0 then
+	redis.call('HINCRBY', KEYS[1], 'count', -1)
+end
+return 0
+`)
+
+// ReserveAttempt 在校验密码前预占一次登录尝试，并发的请求依次计数，不能同时通过检查
+func (r *loginAttemptRepository) ReserveAttempt(subjects []AttemptSubject, window time.Duration) (*AttemptReservation, error) {
+	ctx := context.Background()
+	now := time.Now()
+	keys := make([]string, 0, 2*len(subjects))
+	args := []interface{}{now.UnixMilli(), window.Milliseconds()}
+	for _, subject := range subjects {
+		keys = append(keys, failuresKey(subject.Scope, subject.Subject), lockKey(subject.Scope, subject.Subject))
+		args = append(args, subject.Threshold, len(subject.Delays))
+		for _, delay := range subject.Delays {
+			args = append(args, delay.Milliseconds())
+		}
+	}
+
+	values, err := reserveAttemptScript.Run(ctx, r.redis, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3+len(subjects) && len(values) != 3 {
+		return nil, fmt.Errorf("unexpected reserve attempt result: %v", values)
+	}
+
+	index, _ := values[0].(int64)
+	if index > 0 {
+		reason, _ := values[1].(string)
+		wait, _ := values[2].(int64)
+		return &AttemptReservation{
+			Blocked:    &subjects[index-1],
+			Reason:     reason,
+			RetryAfter: time.Duration(wait) * time.Millisecond,
+		}, nil
+	}
+
+	reservation := &AttemptReservation{Counts: make([]int, len(subjects))}
+	for i := range subjects {
+		count, _ := values[3+i].(int64)
+		reservation.Counts[i] = int(count)
+	}
+	return reservation, nil
+}
+
+// ReleaseAttempt 尝试成功，撤销预占时记入的失败
+func (r *loginAttemptRepository) ReleaseAttempt(scope, subject string) error {
+	ctx := context.Background()
+	return releaseAttemptScript.Run(ctx, r.redis, []string{failuresKey(scope, subject)}).Err()
+}
+
+// GetFailures 获取窗口内的失败记录，没有失败时次数为0
+func (r *loginAttemptRepository) GetFailures(scope, subject string) (*LoginFailures, error) {
+	ctx := context.Background()
+	values, err := r.redis.HGetAll(ctx, failuresKey(scope, subject)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	failures := &LoginFailures{}
+	if count, err := strconv.Atoi(values["count"]); err == nil {
+		failures.Count = count
+	}
+	if last, err := strconv.ParseInt(values["last"], 10, 64); err == nil {
+		failures.LastFailure = time.UnixMilli(last)
+	}
+	return failures, nil
+}
+
+// ClearFailures 清空失败记录
+func (r *loginAttemptRepository) ClearFailures(scope, subject string) error {
+	ctx := context.Background()
+	return r.redis.Del(ctx, failuresKey(scope, subject)).Err()
+}
+
+// SetLock 锁定到until
+func (r *loginAttemptRepository) SetLock(scope, subject string, until time.Time) error {
+	ctx := context.Background()
+	return r.redis.Set(ctx, lockKey(scope, subject), until.UnixMilli(), time.Until(until)).Err()
+}
+
+// GetLock 获取锁定截止时间，未锁定时返回零值
+func (r *loginAttemptRepository) GetLock(scope, subject string) (time.Time, error) {
+	ctx := context.Background()
+	until, err := r.redis.Get(ctx, lockKey(scope, subject)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(until), nil
+}
+
+// DeleteLock 解除锁定
+func (r *loginAttemptRepository) DeleteLock(scope, subject string) error {
+	ctx := context.Background()
+	return r.redis.Del(ctx, lockKey(scope, subject)).Err()
+}
+
+// failuresKey 失败记录的键
+func failuresKey(scope, subject string) string {
+	return fmt.Sprintf("login:failures:%s:%s", scope, subject)
+}
+
+// lockKey 锁定的键
+func lockKey(scope, subject string) string {
+	return fmt.Sprintf("login:lock:%s:%s", scope, subject)
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"blog/shared/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DataRequestRepository 个人数据请求仓库接口
+type DataRequestRepository interface {
+	CreateDataRequest(req *models.DataRequest) error
+	DeleteAccount(user *models.User, req *models.DataRequest) error
+	GetDataRequest(id uint) (*models.DataRequest, error)
+	GetLatestDataRequest(userID uint, requestType string) (*models.DataRequest, error)
+	CompleteTask(requestID uint, service string, exportID uint) (bool, error)
+	RecordTaskError(requestID uint, service, errMsg string) error
+	RecordDispatchError(requestID uint, errMsg string) error
+	FinishDataRequest(id uint, status string, expiresAt *time.Time) (bool, error)
+	ListDueDataRequests(sentBefore time.Time, limit int) ([]models.DataRequest, error)
+	MarkDataRequestSent(id uint, attempts int) (bool, error)
+	FailDataRequest(id uint, reason string) (bool, error)
+	DeleteExpiredExports(now time.Time) (int64, error)
+}
+
+// dataRequestRepository 个人数据请求仓库实现
+type dataRequestRepository struct {
+	db *gorm.DB
+}
+
+// NewDataRequestRepository 创建个人数据请求仓库
+func NewDataRequestRepository(db *gorm.DB) DataRequestRepository {
+	return &dataRequestRepository{db: db}
+}
+
+// CreateDataRequest 创建个人数据请求及各服务的处理进度
+func (r *dataRequestRepository) CreateDataRequest(req *models.DataRequest) error {
+	return r.db.Create(req).Error
+}
+
+// DeleteAccount 在同一事务中保存匿名化后的用户并创建注销请求
+func (r *dataRequestRepository) DeleteAccount(user *models.User, req *models.DataRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(req).Error
+	})
+}
+
+// GetDataRequest 根据ID获取个人数据请求及各服务的处理进度
+func (r *dataRequestRepository) GetDataRequest(id uint) (*models.DataRequest, error) {
+	var req models.DataRequest
+	err := r.db.Preload("Tasks", orderTasks).First(&req, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// GetLatestDataRequest 获取用户最近一次指定类型的请求及各服务的处理进度
+func (r *dataRequestRepository) GetLatestDataRequest(userID uint, requestType string) (*models.DataRequest, error) {
+	var req models.DataRequest
+	err := r.db.Preload("Tasks", orderTasks).
+		Where("user_id = ? AND type = ?", userID, requestType).
+		Order("id DESC").First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// CompleteTask 把服务的处理进度标记为完成并记录导出的ID，已完成或已失败时不做修改，返回false
+func (r *dataRequestRepository) CompleteTask(requestID uint, service string, exportID uint) (bool, error) {
+	result := r.db.Model(&models.DataRequestTask{}).
+		Where("request_id = ? AND service = ? AND status = ?", requestID, service, models.DataRequestPending).
+		Updates(map[string]interface{}{"status": models.DataRequestCompleted, "error": "", "export_id": exportID})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecordTaskError 记录服务处理失败的原因，处理进度保持未完成，等待重新发送
+func (r *dataRequestRepository) RecordTaskError(requestID uint, service, errMsg string) error {
+	return r.db.Model(&models.DataRequestTask{}).
+		Where("request_id = ? AND service = ? AND status = ?", requestID, service, models.DataRequestPending).
+		Update("error", truncate(errMsg, 255)).Error
+}
+
+// RecordDispatchError 请求未能发送给各服务时，把原因记录到所有未完成的处理进度
+func (r *dataRequestRepository) RecordDispatchError(requestID uint, errMsg string) error {
+	return r.db.Model(&models.DataRequestTask{}).
+		Where("request_id = ? AND status = ?", requestID, models.DataRequestPending).
+		Update("error", truncate(errMsg, 255)).Error
+}
+
+// FinishDataRequest 结束未完成的请求，已结束时不做修改，返回false
+func (r *dataRequestRepository) FinishDataRequest(id uint, status string, expiresAt *time.Time) (bool, error) {
+	result := r.db.Model(&models.DataRequest{}).
+		Where("id = ? AND status = ?", id, models.DataRequestPending).
+		Updates(map[string]interface{}{"status": status, "completed_at": time.Now(), "expires_at": expiresAt})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListDueDataRequests 获取未完成且最近一次发送早于 sentBefore 的请求
+func (r *dataRequestRepository) ListDueDataRequests(sentBefore time.Time, limit int) ([]models.DataRequest, error) {
+	var reqs []models.DataRequest
+	err := r.db.Where("status = ? AND last_sent_at < ?", models.DataRequestPending, sentBefore).
+		Order("id").Limit(limit).Find(&reqs).Error
+	if err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// MarkDataRequestSent 发送次数加一并记录发送时间
+// 发送次数已不是 attempts 时说明其他副本已重新发送，不做修改，返回false
+func (r *dataRequestRepository) MarkDataRequestSent(id uint, attempts int) (bool, error) {
+	result := r.db.Model(&models.DataRequest{}).
+		Where("id = ? AND status = ? AND attempts = ?", id, models.DataRequestPending, attempts).
+		Updates(map[string]interface{}{"attempts": attempts + 1, "last_sent_at": time.Now()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FailDataRequest 在同一事务中把请求和未完成的处理进度标记为失败，没有失败原因的进度记录 reason
+// 请求已结束时不做修改，返回false
+func (r *dataRequestRepository) FailDataRequest(id uint, reason string) (bool, error) {
+	failed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.DataRequest{}).
+			Where("id = ? AND status = ?", id, models.DataRequestPending).
+			Updates(map[string]interface{}{"status": models.DataRequestFailed, "completed_at": time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		failed = true
+
+		err := tx.Model(&models.DataRequestTask{}).
+			Where("request_id = ? AND status = ? AND error = ''", id, models.DataRequestPending).
+			Update("error", truncate(reason, 255)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.DataRequestTask{}).
+			Where("request_id = ? AND status = ?", id, models.DataRequestPending).
+			Update("status", models.DataRequestFailed).Error
+	})
+	return failed, err
+}
+
+// DeleteExpiredExports 删除下载有效期已过的导出请求，返回删除的请求数
+// 同时删除不属于未完成或已完成请求的导出数据，包括过期、失败的请求和服务在请求结束后才写入的数据
+func (r *dataRequestRepository) DeleteExpiredExports(now time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.DataRequest{}).Select("id").
+			Where("type = ? AND expires_at < ?", models.DataRequestExport, now)
+		if err := tx.Where("request_id IN (?)", expired).Delete(&models.DataRequestTask{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("type = ? AND expires_at < ?", models.DataRequestExport, now).Delete(&models.DataRequest{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+
+		live := tx.Model(&models.DataRequest{}).Select("id").Where("status <> ?", models.DataRequestFailed)
+		return tx.Where("request_id NOT IN (?)", live).Delete(&models.UserDataExport{}).Error
+	})
+	return deleted, err
+}
+
+// orderTasks 处理进度按服务名排序
+func orderTasks(db *gorm.DB) *gorm.DB {
+	return db.Order("service")
+}
+
+// truncate 按字符截断超出列长度的字符串
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 签名密钥的键：jwt:keys 为kid到密钥的哈希，jwt:keys:rotation 为轮换锁
+const (
+	signingKeysKey  = "jwt:keys"
+	keyRotationLock = "jwt:keys:rotation"
+)
+
+// 私钥种子的加密密钥：环境变量中为32字节的十六进制编码，或由 _FILE 变量指定保存该编码的文件
+// 加密密钥只由用户服务持有，不写入配置中心和Redis
+const (
+	EncryptionKeyEnv     = "JWT_KEY_ENCRYPTION_KEY"
+	EncryptionKeyFileEnv = "JWT_KEY_ENCRYPTION_KEY_FILE"
+)
+
+// SigningKey 访问令牌签名密钥，所有用户服务副本共用
+type SigningKey struct {
+	Kid         string    `json:"kid"`
+	Seed        []byte    `json:"-"` // Ed25519私钥种子，只以密文保存
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatesAt time.Time `json:"activates_at"` // 开始用于签名的时间，此前只公布公钥
+}
+
+// storedKey Redis中保存的签名密钥，私钥种子用AES-256-GCM加密，kid作为附加数据
+type storedKey struct {
+	SigningKey
+	SealedSeed []byte `json:"sealed_seed"` // nonce在前
+}
+
+// LoadEncryptionKey 从环境变量或文件读取私钥种子的加密密钥，都未配置或格式不对时返回错误
+func LoadEncryptionKey() ([]byte, error) {
+	value := os.Getenv(EncryptionKeyEnv)
+	if path := os.Getenv(EncryptionKeyFileEnv); value == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", EncryptionKeyFileEnv, err)
+		}
+		value = string(data)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("signing key encryption key not configured, set %s or %s", EncryptionKeyEnv, EncryptionKeyFileEnv)
+	}
+	key, err := hex.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("signing key encryption key must be 32 bytes in hex")
+	}
+	return key, nil
+}
+
+// KeyRepository 签名密钥仓库接口
+type KeyRepository interface {
+	GetKeys() ([]*SigningKey, error)
+	SaveKey(key *SigningKey) error
+	DeleteKeys(kids ...string) error
+	AcquireRotationLock(ttl time.Duration) (bool, error)
+}
+
+// keyRepository 签名密钥仓库实现
+type keyRepository struct {
+	redis *redis.Client
+	aead  cipher.AEAD
+}
+
+// NewKeyRepository 创建签名密钥仓库，encryptionKey 为32字节的私钥种子加密密钥
+func NewKeyRepository(redis *redis.Client, encryptionKey []byte) (KeyRepository, error) {
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key encryption key: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyRepository{redis: redis, aead: aead}, nil
+}
+
+// GetKeys 获取全部签名密钥并解密私钥种子，按启用时间升序排列
+// 无法解密时返回错误，避免加密密钥配置错误的副本生成新密钥
+func (r *keyRepository) GetKeys() ([]*SigningKey, error) {
+	ctx := context.Background()
+	values, err := r.redis.HGetAll(ctx, signingKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(values))
+	for kid, value := range values {
+		var stored storedKey
+		if err := json.Unmarshal([]byte(value), &stored); err != nil {
+			return nil, fmt.Errorf("invalid signing key %s: %v", kid, err)
+		}
+		seed, err := r.open(kid, stored.SealedSeed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %v", kid, err)
+		}
+		stored.Seed = seed
+		keys = append(keys, &stored.SigningKey)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+	return keys, nil
+}
+
+// SaveKey 加密私钥种子后保存签名密钥
+func (r *keyRepository) SaveKey(key *SigningKey) error {
+	ctx := context.Background()
+	sealed, err := r.seal(key.Kid, key.Seed)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&storedKey{SigningKey: *key, SealedSeed: sealed})
+	if err != nil {
+		return err
+	}
+	return r.redis.HSet(ctx, signingKeysKey, key.Kid, data).Err()
+}
+
+// seal 加密私钥种子，kid作为附加数据，密文不能挪用到其他kid
+func (r *keyRepository) seal(kid string, seed []byte) ([]byte, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return r.aead.Seal(nonce, nonce, seed, []byte(kid)), nil
+}
+
+// open 解密私钥种子
+func (r *keyRepository) open(kid string, sealed []byte) ([]byte, error) {
+	if len(sealed) < r.aead.NonceSize() {
+		return nil, errors.New("sealed seed too short")
+	}
+	nonce, ciphertext := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+	return r.aead.Open(nil, nonce, ciphertext, []byte(kid))
+}
+
+// DeleteKeys 删除签名密钥，用它签发的令牌随即无法通过验证
+func (r *keyRepository) DeleteKeys(kids ...string) error {
+	if len(kids) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	return r.redis.HDel(ctx, signingKeysKey, kids...).Err()
+}
+
+// AcquireRotationLock 获取轮换锁，避免多个副本同时生成新密钥
+// 锁到期自动释放，获取失败说明其他副本正在轮换
+func (r *keyRepository) AcquireRotationLock(ttl time.Duration) (bool, error) {
+	ctx := context.Background()
+	return r.redis.SetNX(ctx, keyRotationLock, 1, ttl).Result()
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrRefreshTokenNotFound 刷新令牌不存在或已过期
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// RefreshToken 刷新令牌记录，以令牌的SHA-256摘要为键保存，Redis中不保存令牌原文
+type RefreshToken struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	Family string `json:"family"` // 同一次登录轮换出的令牌属于同一家族
+}
+
+// TokenFamily 令牌家族的当前状态
+type TokenFamily struct {
+	Current   string    // 当前有效的刷新令牌摘要
+	AccessJTI string    // 最近一次签发的访问令牌ID
+	AccessExp time.Time // 最近一次签发的访问令牌过期时间
+}
+
+// TokenRepository 刷新令牌仓库接口
+type TokenRepository interface {
+	CreateFamily(hash string, token *RefreshToken, family *TokenFamily, ttl time.Duration) error
+	GetRefreshToken(hash string) (*RefreshToken, error)
+	RotateRefreshToken(oldHash, newHash string, token *RefreshToken, family *TokenFamily, ttl time.Duration) (bool, error)
+	DeleteFamily(family string) (*TokenFamily, error)
+	TakeUserFamilies(userID uint) ([]string, error)
+}
+
+// tokenRepository 刷新令牌仓库实现
+//
+// refresh:token:<摘要> 保存令牌记录，轮换后旧记录保留到过期，用于识别重放；
+// refresh:family:<家族> 保存当前有效的令牌摘要和最近签发的访问令牌；
+// refresh:user:<用户ID> 为用户的全部家族，修改或重置密码时整体注销
+type tokenRepository struct {
+	redis *redis.Client
+}
+
+// NewTokenRepository 创建刷新令牌仓库
+func NewTokenRepository(redis *redis.Client) TokenRepository {
+	return &tokenRepository{redis: redis}
+}
+
+// rotateScript 家族当前令牌仍为旧令牌时切换到新令牌，返回1；否则说明旧令牌已被使用或家族已注销，返回0
+var rotateScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'current') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'current', ARGV[2], 'jti', ARGV[4], 'exp', ARGV[5])
+redis.call('PEXPIRE', KEYS[1], ARGV[6])
+redis.call('PEXPIRE', KEYS[3], ARGV[6])
+redis.call('SET', KEYS[2], ARGV[3], 'PX', ARGV[6])
+return 1
+`)
+
+// CreateFamily 登录时创建令牌家族和第一个刷新令牌
+func (r *tokenRepository) CreateFamily(hash string, token *RefreshToken, family *TokenFamily, ttl time.Duration) error {
+	ctx := context.Background()
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	familyKey := familyKey(token.Family)
+	pipe := r.redis.TxPipeline()
+	pipe.Set(ctx, tokenKey(hash), data, ttl)
+	pipe.HSet(ctx, familyKey, "current", hash, "jti", family.AccessJTI, "exp", family.AccessExp.Unix())
+	pipe.PExpire(ctx, familyKey, ttl)
+	pipe.SAdd(ctx, userFamiliesKey(token.UserID), token.Family)
+	pipe.PExpire(ctx, userFamiliesKey(token.UserID), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetRefreshToken 获取刷新令牌记录
+func (r *tokenRepository) GetRefreshToken(hash string) (*RefreshToken, error) {
+	ctx := context.Background()
+	data, err := r.redis.Get(ctx, tokenKey(hash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token RefreshToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken 原子地把家族的当前令牌从旧令牌切换到新令牌
+// 旧令牌不是家族的当前令牌时返回false，调用方应视为令牌被重放
+func (r *tokenRepository) RotateRefreshToken(oldHash, newHash string, token *RefreshToken, family *TokenFamily, ttl time.Duration) (bool, error) {
+	ctx := context.Background()
+	data, err := json.Marshal(token)
+	if err != nil {
+		return false, err
+	}
+
+	rotated, err := rotateScript.Run(ctx, r.redis,
+		[]string{familyKey(token.Family), tokenKey(newHash), userFamiliesKey(token.UserID)},
+		oldHash, newHash, data, family.AccessJTI, family.AccessExp.Unix(), ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return rotated == 1, nil
+}
+
+// DeleteFamily 注销令牌家族，家族中的所有刷新令牌随之失效，返回注销前的状态
+// 家族不存在时返回nil
+func (r *tokenRepository) DeleteFamily(family string) (*TokenFamily, error) {
+	ctx := context.Background()
+	key := familyKey(family)
+
+	var values *redis.StringStringMapCmd
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := values.Val()
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	state := &TokenFamily{Current: fields["current"], AccessJTI: fields["jti"]}
+	if exp, err := strconv.ParseInt(fields["exp"], 10, 64); err == nil {
+		state.AccessExp = time.Unix(exp, 0)
+	}
+	return state, nil
+}
+
+// TakeUserFamilies 取出并清空用户的令牌家族列表，列表中可能包含已过期或已注销的家族
+func (r *tokenRepository) TakeUserFamilies(userID uint) ([]string, error) {
+	ctx := context.Background()
+	key := userFamiliesKey(userID)
+
+	var members *redis.StringSliceCmd
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		members = pipe.SMembers(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members.Val(), nil
+}
+
+// tokenKey 刷新令牌记录的键
+func tokenKey(hash string) string {
+	return "refresh:token:" + hash
+}
+
+// familyKey 令牌家族的键
+func familyKey(family string) string {
+	return "refresh:family:" + family
+}
+
+// userFamiliesKey 用户令牌家族列表的键，有效期随最近一次登录或刷新延长
+func userFamiliesKey(userID uint) string {
+	return "refresh:user:" + strconv.FormatUint(uint64(userID), 10)
+}
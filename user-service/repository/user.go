@@ -3,8 +3,10 @@ package repository
 import (
 	"blog/shared/models"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,12 +14,32 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrResetTokenNotFound 重置令牌不存在、已过期、已使用或已被更新的令牌取代
+	ErrResetTokenNotFound = errors.New("password reset token not found")
+	// ErrVerificationCodeNotFound 验证码不存在、已过期或已使用
+	ErrVerificationCodeNotFound = errors.New("verification code not found")
+	// ErrVerificationAttemptsExceeded 验证码的尝试次数已用完，验证码已删除
+	ErrVerificationAttemptsExceeded = errors.New("verification attempts exceeded")
+	// ErrVerificationCooldown 距上次发送验证码不足冷却时间
+	ErrVerificationCooldown = errors.New("verification code sent recently")
+	// ErrVerificationDailyLimit 24小时内发送验证码的次数已达上限
+	ErrVerificationDailyLimit = errors.New("verification code daily limit reached")
+	// ErrRoleConflict 用户的角色已被并发修改，与读取时的角色不一致
+	ErrRoleConflict = errors.New("user role was changed concurrently")
+)
+
 // UserRepository 用户仓库接口
 type UserRepository interface {
 	CreateUser(user *models.User) error
 	GetUserByEmail(email string) (*models.User, error)
+	GetUserByPendingEmail(email string) (*models.User, error)
+	SetPendingEmail(userID uint, email string) error
 	GetUserByID(id uint) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
 	UpdateUser(user *models.User) error
+	ChangeUserRole(audit *models.RoleAuditLog) error
+	ListRoleAuditLogs(userID uint, page, pageSize int) ([]models.RoleAuditLog, int64, error)
 	CreateEmailVerification(verification *models.EmailVerification) error
 	GetEmailVerification(email, code string) (*models.EmailVerification, error)
 	DeleteEmailVerification(email string) error
@@ -26,8 +48,12 @@ type UserRepository interface {
 // EmailRepository 邮箱仓库接口
 type EmailRepository interface {
 	SetVerificationCode(email, code string, expiration time.Duration) error
-	GetVerificationCode(email string) (string, error)
+	TakeVerificationAttempt(email string, maxAttempts int) (string, int, error)
 	DeleteVerificationCode(email string) error
+	AcquireVerificationSend(email string, cooldown time.Duration, dailyLimit int) (time.Duration, error)
+	AcquirePasswordResetCooldown(email string, cooldown time.Duration) (bool, error)
+	SetPasswordResetToken(userID uint, tokenHash string, expiration time.Duration) error
+	ConsumePasswordResetToken(tokenHash string) (uint, error)
 }
 
 // userRepository 用户仓库实现
@@ -36,6 +62,9 @@ type userRepository struct {
 }
 
 // emailRepository 邮箱仓库实现
+//
+// verification:code:<邮箱> 为验证码和已尝试的次数，发送新验证码时重置；
+// verification:cooldown:<邮箱> 为发送冷却，verification:daily:<邮箱> 为24小时内的发送次数
 type emailRepository struct {
 	redis *redis.Client
 }
@@ -58,7 +87,7 @@ func InitDB(dsn string) *gorm.DB {
 	}
 
 	// 自动迁移
-	err = db.AutoMigrate(&models.User{}, &models.EmailVerification{})
+	err = db.AutoMigrate(&models.User{}, &models.EmailVerification{}, &models.RoleAuditLog{}, &models.DataRequest{}, &models.DataRequestTask{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -100,6 +129,30 @@ func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUserByPendingEmail 根据待验证的新邮箱获取用户
+func (r *userRepository) GetUserByPendingEmail(email string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("pending_email = ?", email).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetPendingEmail 在同一事务中清除其他用户相同的待验证邮箱并设置该用户的待验证邮箱
+// 验证码按邮箱保存，同一邮箱只保留最新的修改请求
+func (r *userRepository) SetPendingEmail(userID uint, email string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("pending_email = ? AND id <> ?", email, userID).
+			Update("pending_email", "").Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("pending_email", email).Error
+	})
+}
+
 // GetUserByID 根据ID获取用户
 func (r *userRepository) GetUserByID(id uint) (*models.User, error) {
 	var user models.User
@@ -110,11 +163,58 @@ func (r *userRepository) GetUserByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUserByUsername 根据用户名获取用户
+func (r *userRepository) GetUserByUsername(username string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("username = ?", username).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // UpdateUser 更新用户
 func (r *userRepository) UpdateUser(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
+// ChangeUserRole 在同一事务中把用户角色从 audit.OldRole 改为 audit.NewRole 并写入变更记录
+// 用户当前角色不是 audit.OldRole 时不做修改，返回ErrRoleConflict
+func (r *userRepository) ChangeUserRole(audit *models.RoleAuditLog) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND role = ?", audit.UserID, audit.OldRole).
+			Update("role", audit.NewRole)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleConflict
+		}
+		return tx.Create(audit).Error
+	})
+}
+
+// ListRoleAuditLogs 按时间倒序分页查询角色变更记录，userID 为0时查询所有用户
+func (r *userRepository) ListRoleAuditLogs(userID uint, page, pageSize int) ([]models.RoleAuditLog, int64, error) {
+	query := r.db.Model(&models.RoleAuditLog{})
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.RoleAuditLog
+	err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
 // CreateEmailVerification 创建邮箱验证记录
 func (r *userRepository) CreateEmailVerification(verification *models.EmailVerification) error {
 	return r.db.Create(verification).Error
@@ -135,23 +235,167 @@ func (r *userRepository) DeleteEmailVerification(email string) error {
 	return r.db.Where("email = ?", email).Delete(&models.EmailVerification{}).Error
 }
 
-// SetVerificationCode 设置验证码
+// takeVerificationAttemptScript 尝试次数加一并返回验证码和剩余次数；验证码不存在时剩余次数为-1，次数已用完时删除验证码并返回0
+var takeVerificationAttemptScript = redis.NewScript(`
+local code = redis.call('HGET', KEYS[1], 'code')
+if not code then
+	return {'', -1}
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts > tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+	return {'', 0}
+end
+return {code, tonumber(ARGV[1]) - attempts}
+`)
+
+// acquireVerificationSendScript 未在冷却中且未达每日上限时记录一次发送，返回 {0, 0}；
+// 否则返回 {1, 冷却剩余毫秒} 或 {2, 每日计数剩余毫秒}
+var acquireVerificationSendScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	return {1, ttl}
+end
+local sent = tonumber(redis.call('GET', KEYS[2]) or '0')
+if sent >= tonumber(ARGV[2]) then
+	return {2, redis.call('PTTL', KEYS[2])}
+end
+redis.call('SET', KEYS[1], 1, 'PX', ARGV[1])
+if redis.call('INCR', KEYS[2]) == 1 then
+	redis.call('PEXPIRE', KEYS[2], ARGV[3])
+end
+return {0, 0}
+`)
+
+// verificationSendWindow 每日发送上限的统计窗口，从第一次发送开始计算
+const verificationSendWindow = 24 * time.Hour
+
+// SetVerificationCode 设置验证码，之前的验证码和尝试次数随之作废
 func (r *emailRepository) SetVerificationCode(email, code string, expiration time.Duration) error {
 	ctx := context.Background()
-	key := fmt.Sprintf("verification:%s", email)
-	return r.redis.Set(ctx, key, code, expiration).Err()
+	key := verificationCodeKey(email)
+	pipe := r.redis.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "code", code, "attempts", 0)
+	pipe.PExpire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// GetVerificationCode 获取验证码
-func (r *emailRepository) GetVerificationCode(email string) (string, error) {
+// TakeVerificationAttempt 消耗一次尝试机会，返回验证码和此后剩余的尝试次数
+// 验证码不存在时返回 ErrVerificationCodeNotFound，次数已用完时删除验证码并返回 ErrVerificationAttemptsExceeded
+func (r *emailRepository) TakeVerificationAttempt(email string, maxAttempts int) (string, int, error) {
 	ctx := context.Background()
-	key := fmt.Sprintf("verification:%s", email)
-	return r.redis.Get(ctx, key).Result()
+	result, err := takeVerificationAttemptScript.Run(ctx, r.redis, []string{verificationCodeKey(email)}, maxAttempts).Slice()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(result) != 2 {
+		return "", 0, fmt.Errorf("unexpected verification attempt result: %v", result)
+	}
+
+	code, _ := result[0].(string)
+	remaining, _ := result[1].(int64)
+	switch {
+	case remaining < 0:
+		return "", 0, ErrVerificationCodeNotFound
+	case code == "":
+		return "", 0, ErrVerificationAttemptsExceeded
+	}
+	return code, int(remaining), nil
 }
 
 // DeleteVerificationCode 删除验证码
 func (r *emailRepository) DeleteVerificationCode(email string) error {
 	ctx := context.Background()
-	key := fmt.Sprintf("verification:%s", email)
-	return r.redis.Del(ctx, key).Err()
+	return r.redis.Del(ctx, verificationCodeKey(email)).Err()
+}
+
+// AcquireVerificationSend 记录一次验证码发送；仍在冷却中或24小时内已达上限时返回对应的错误和需要等待的时间
+func (r *emailRepository) AcquireVerificationSend(email string, cooldown time.Duration, dailyLimit int) (time.Duration, error) {
+	ctx := context.Background()
+	keys := []string{
+		fmt.Sprintf("verification:cooldown:%s", email),
+		fmt.Sprintf("verification:daily:%s", email),
+	}
+	result, err := acquireVerificationSendScript.Run(ctx, r.redis, keys,
+		cooldown.Milliseconds(), dailyLimit, verificationSendWindow.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	if len(result) != 2 {
+		return 0, fmt.Errorf("unexpected verification send result: %v", result)
+	}
+
+	retryAfter := time.Duration(result[1]) * time.Millisecond
+	switch result[0] {
+	case 1:
+		return retryAfter, ErrVerificationCooldown
+	case 2:
+		if retryAfter <= 0 {
+			retryAfter = verificationSendWindow
+		}
+		return retryAfter, ErrVerificationDailyLimit
+	}
+	return 0, nil
+}
+
+// verificationCodeKey 验证码的键
+func verificationCodeKey(email string) string {
+	return fmt.Sprintf("verification:code:%s", email)
+}
+
+// AcquirePasswordResetCooldown 同一邮箱在冷却时间内只能申请一次重置，返回false表示仍在冷却中
+func (r *emailRepository) AcquirePasswordResetCooldown(email string, cooldown time.Duration) (bool, error) {
+	ctx := context.Background()
+	key := fmt.Sprintf("password_reset:cooldown:%s", email)
+	return r.redis.SetNX(ctx, key, 1, cooldown).Result()
+}
+
+// SetPasswordResetToken 保存重置令牌的摘要，并记为该用户最新的令牌，之前签发的令牌随之失效
+func (r *emailRepository) SetPasswordResetToken(userID uint, tokenHash string, expiration time.Duration) error {
+	ctx := context.Background()
+	pipe := r.redis.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("password_reset:%s", tokenHash), userID, expiration)
+	pipe.Set(ctx, fmt.Sprintf("password_reset:user:%d", userID), tokenHash, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ConsumePasswordResetToken 取出并删除重置令牌，返回用户ID；令牌只能使用一次
+// 令牌已被同一用户更新的令牌取代时返回 ErrResetTokenNotFound
+func (r *emailRepository) ConsumePasswordResetToken(tokenHash string) (uint, error) {
+	ctx := context.Background()
+
+	var value *redis.StringCmd
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		value = pipe.Get(ctx, fmt.Sprintf("password_reset:%s", tokenHash))
+		pipe.Del(ctx, fmt.Sprintf("password_reset:%s", tokenHash))
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrResetTokenNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, err := strconv.ParseUint(value.Val(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid password reset token record: %v", err)
+	}
+
+	// 只有用户最新的令牌有效，比较后删除
+	userKey := fmt.Sprintf("password_reset:user:%d", userID)
+	latest, err := r.redis.Get(ctx, userKey).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && latest != tokenHash) {
+		return 0, ErrResetTokenNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := r.redis.Del(ctx, userKey).Err(); err != nil {
+		return 0, err
+	}
+	return uint(userID), nil
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"blog/shared/config"
+	"blog/shared/serviceauth"
 	"encoding/json"
 	"log"
 	"os"
@@ -9,15 +10,17 @@ import (
 
 // Config 钱包服务配置
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Redis    RedisConfig    `json:"redis"`
-	Kafka    KafkaConfig    `json:"kafka"`
+	Server      ServerConfig       `json:"server"`
+	Database    DatabaseConfig     `json:"database"`
+	Redis       RedisConfig        `json:"redis"`
+	Kafka       KafkaConfig        `json:"kafka"`
+	ServiceAuth serviceauth.Config `json:"service_auth"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port string `json:"port"`
+	Port     string `json:"port"`
+	GRPCPort string `json:"grpc_port"` // 内部gRPC接口端口
 }
 
 // DatabaseConfig 数据库配置
@@ -67,6 +70,16 @@ func LoadConfig() *Config {
 		return loadDefaultConfig()
 	}
 
+	// 配置中心未配置gRPC端口时使用默认端口
+	if cfg.Server.GRPCPort == "" {
+		cfg.Server.GRPCPort = defaultGRPCPort()
+	}
+
+	// 配置中心未配置服务密钥时使用默认密钥
+	if len(cfg.ServiceAuth.Trusted) == 0 {
+		cfg.ServiceAuth = serviceauth.DefaultConfig("wallet-service", "api-gateway", "shop-service")
+	}
+
 	log.Printf("Config loaded from Redis config center for wallet-service")
 	return &cfg
 }
@@ -111,7 +124,8 @@ func loadDefaultConfig() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Port: port,
+			Port:     port,
+			GRPCPort: defaultGRPCPort(),
 		},
 		Database: DatabaseConfig{
 			DSN: "root:sta_go@tcp(" + dbHost + ":" + dbPort + ")/blog?charset=utf8mb4&parseTime=True&loc=Local",
@@ -124,5 +138,14 @@ func loadDefaultConfig() *Config {
 		Kafka: KafkaConfig{
 			Brokers: []string{kafkaHost + ":" + kafkaPort},
 		},
+		ServiceAuth: serviceauth.DefaultConfig("wallet-service", "api-gateway", "shop-service"),
+	}
+}
+
+// defaultGRPCPort 默认gRPC端口
+func defaultGRPCPort() string {
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		return port
 	}
+	return "9002"
 }
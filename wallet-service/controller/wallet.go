@@ -1,8 +1,17 @@
 package controller
 
 import (
+	"blog/shared/auth"
+	"blog/shared/health"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"blog/shared/validation"
 	"blog/wallet-service/logic"
+	"context"
+	"errors"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Dearlimg/Goutils/pkg/app"
 	"github.com/Dearlimg/Goutils/pkg/app/errcode"
@@ -71,16 +80,15 @@ func (wc *WalletController) AddBalance(c *gin.Context) {
 	}
 
 	var req struct {
-		Amount      float64 `json:"amount" validate:"required,gt=0"`
-		Description string  `json:"description" validate:"required"`
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	transaction, err := wc.walletLogic.AddBalance(uint(userID), req.Amount, req.Description)
+	transaction, err := wc.walletLogic.AddBalance(c.Request.Context(), uint(userID), req.Amount, req.Description)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -101,16 +109,15 @@ func (wc *WalletController) DeductBalance(c *gin.Context) {
 	}
 
 	var req struct {
-		Amount      float64 `json:"amount" validate:"required,gt=0"`
-		Description string  `json:"description" validate:"required"`
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	transaction, err := wc.walletLogic.DeductBalance(uint(userID), req.Amount, req.Description)
+	transaction, err := wc.walletLogic.DeductBalance(c.Request.Context(), uint(userID), req.Amount, req.Description)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -144,18 +151,17 @@ func (wc *WalletController) Transfer(c *gin.Context) {
 	rly := app.NewResponse(c)
 
 	var req struct {
-		FromUserID  uint    `json:"from_user_id" validate:"required"`
-		ToUserID    uint    `json:"to_user_id" validate:"required"`
-		Amount      float64 `json:"amount" validate:"required,gt=0"`
-		Description string  `json:"description" validate:"required"`
+		FromUserID  uint    `json:"from_user_id" binding:"required"`
+		ToUserID    uint    `json:"to_user_id" binding:"required"`
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		rly.Reply(errcode.ErrParamsNotValid.WithDetails(err.Error()))
+	if !validation.BindJSON(c, &req) {
 		return
 	}
 
-	err := wc.walletLogic.Transfer(req.FromUserID, req.ToUserID, req.Amount, req.Description)
+	err := wc.walletLogic.Transfer(c.Request.Context(), req.FromUserID, req.ToUserID, req.Amount, req.Description)
 	if err != nil {
 		rly.Reply(errcode.ErrServer.WithDetails(err.Error()))
 		return
@@ -164,54 +170,66 @@ func (wc *WalletController) Transfer(c *gin.Context) {
 	rly.Reply(nil, "Transfer completed successfully")
 }
 
-// HealthCheck 健康检查
-func (wc *WalletController) HealthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status":  "healthy",
-		"service": "wallet-service",
-	})
-}
-
 // Server HTTP服务器
 type Server struct {
-	router *gin.Engine
-	port   string
+	router     *gin.Engine
+	port       string
+	httpServer *http.Server
 }
 
 // NewServer 创建HTTP服务器
-func NewServer(port string, walletController *WalletController) *Server {
+func NewServer(port string, walletController *WalletController, verifier *serviceauth.Verifier, healthChecker *health.Checker) *Server {
 	router := gin.New()
-	router.Use(gin.Recovery(), gin.Logger())
+	router.Use(gin.Recovery(), tracing.Middleware(), tracing.Logger())
 
 	// 健康检查路由
-	router.GET("/health", walletController.HealthCheck)
+	healthChecker.RegisterRoutes(router)
 
 	// 钱包相关路由
+	// 只接受网关和已授权服务的调用；只能操作自己的钱包，管理员除外；未验证邮箱的用户只能查询
 	api := router.Group("/api/v1")
+	api.Use(verifier.Require(callerACL()), auth.Middleware(), auth.RequireVerifiedWrites())
 	{
 		wallets := api.Group("/wallets")
 		{
-			wallets.POST("/:user_id", walletController.CreateWallet)
-			wallets.GET("/:user_id", walletController.GetWallet)
-			wallets.POST("/:user_id/add", walletController.AddBalance)
-			wallets.POST("/:user_id/deduct", walletController.DeductBalance)
-			wallets.GET("/:user_id/transactions", walletController.GetTransactions)
-			wallets.POST("/transfer", walletController.Transfer)
+			wallets.POST("/:user_id", auth.RequireSelf("user_id"), walletController.CreateWallet)
+			wallets.GET("/:user_id", auth.RequireSelf("user_id"), walletController.GetWallet)
+			wallets.POST("/:user_id/add", auth.RequireSelf("user_id"), walletController.AddBalance)
+			wallets.POST("/:user_id/deduct", auth.RequireSelf("user_id"), walletController.DeductBalance)
+			wallets.GET("/:user_id/transactions", auth.RequireSelf("user_id"), walletController.GetTransactions)
+			wallets.POST("/transfer", auth.RequireSelfInBody("from_user_id"), walletController.Transfer)
 		}
 	}
 
 	return &Server{
 		router: router,
 		port:   port,
+		httpServer: &http.Server{
+			Addr:              ":" + port,
+			Handler:           router,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
 	}
 }
 
+// callerACL 各调用方可以访问的接口
+// 网关转发外部请求，可以访问所有接口；其他服务通过gRPC接口调用
+func callerACL() *serviceauth.ACL {
+	return serviceauth.NewACL().
+		Allow("api-gateway", serviceauth.AnyEndpoint)
+}
+
 // Start 启动服务器
+// 调用Shutdown后返回nil
 func (s *Server) Start() error {
-	return s.router.Run(":" + s.port)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Shutdown 关闭服务器
-func (s *Server) Shutdown() {
-	// 这里可以添加优雅关闭逻辑
+// Shutdown 优雅关闭：停止接受新连接，等待处理中的请求完成，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
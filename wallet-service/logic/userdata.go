@@ -0,0 +1,36 @@
+package logic
+
+import (
+	"blog/shared/tracing"
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ExportUserData 导出用户的钱包和全部交易记录，没有钱包时 wallet 为null
+func (wl *WalletLogic) ExportUserData(ctx context.Context, userID uint) (interface{}, error) {
+	wallet, err := wl.walletRepo.GetWalletByUserID(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get wallet: %v", err)
+	}
+	transactions, err := wl.transactionRepo.GetTransactionsByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %v", err)
+	}
+	return map[string]interface{}{
+		"wallet":       wallet,
+		"transactions": transactions,
+	}, nil
+}
+
+// EraseUserData 注销账户时清空交易说明；金额和余额属于账务记录，按用户ID保留
+func (wl *WalletLogic) EraseUserData(ctx context.Context, userID uint) error {
+	affected, err := wl.transactionRepo.ClearUserDescriptions(userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear transaction descriptions: %v", err)
+	}
+	tracing.Printf(ctx, "Cleared descriptions of %d transactions of user %d", affected, userID)
+	return nil
+}
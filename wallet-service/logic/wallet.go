@@ -3,13 +3,32 @@ package logic
 import (
 	"blog/shared/kafka"
 	"blog/shared/models"
+	"blog/shared/tracing"
 	"blog/wallet-service/repository"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 
 	"github.com/Shopify/sarama"
 )
 
+// 业务错误，调用方通过 errors.Is 判断，gRPC接口据此返回对应的错误码
+var (
+	ErrInvalidAmount       = errors.New("amount must be greater than 0")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrSameAccount         = errors.New("cannot transfer to yourself")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyRefunded     = errors.New("transaction already refunded")
+	ErrNotRefundable       = errors.New("only purchase transactions can be refunded")
+)
+
+// PurchaseItem 订单中的一个商品
+type PurchaseItem struct {
+	ProductID uint
+	Quantity  int
+	UnitPrice float64
+}
+
 // WalletLogic 钱包业务逻辑
 type WalletLogic struct {
 	walletRepo      repository.WalletRepository
@@ -59,9 +78,9 @@ func (wl *WalletLogic) GetWallet(userID uint) (*models.Wallet, error) {
 }
 
 // AddBalance 增加余额
-func (wl *WalletLogic) AddBalance(userID uint, amount float64, description string) (*models.Transaction, error) {
+func (wl *WalletLogic) AddBalance(ctx context.Context, userID uint, amount float64, description string) (*models.Transaction, error) {
 	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be greater than 0")
+		return nil, ErrInvalidAmount
 	}
 
 	// 获取钱包
@@ -99,7 +118,7 @@ func (wl *WalletLogic) AddBalance(userID uint, amount float64, description strin
 	transaction.Status = "completed"
 	err = wl.transactionRepo.UpdateTransaction(transaction)
 	if err != nil {
-		log.Printf("Failed to update transaction status: %v", err)
+		tracing.Printf(ctx, "Failed to update transaction status: %v", err)
 	}
 
 	// 发送Kafka事件
@@ -109,18 +128,18 @@ func (wl *WalletLogic) AddBalance(userID uint, amount float64, description strin
 		Description:   description,
 		TransactionID: transaction.ID,
 	}
-	err = wl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
+	err = wl.producer.SendMessage(ctx, kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
 	if err != nil {
-		log.Printf("Failed to send payment event: %v", err)
+		tracing.Printf(ctx, "Failed to send payment event: %v", err)
 	}
 
 	return transaction, nil
 }
 
 // DeductBalance 扣除余额
-func (wl *WalletLogic) DeductBalance(userID uint, amount float64, description string) (*models.Transaction, error) {
+func (wl *WalletLogic) DeductBalance(ctx context.Context, userID uint, amount float64, description string) (*models.Transaction, error) {
 	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be greater than 0")
+		return nil, ErrInvalidAmount
 	}
 
 	// 获取钱包
@@ -131,7 +150,7 @@ func (wl *WalletLogic) DeductBalance(userID uint, amount float64, description st
 
 	// 检查余额是否足够
 	if wallet.Balance < amount {
-		return nil, fmt.Errorf("insufficient balance")
+		return nil, ErrInsufficientBalance
 	}
 
 	// 创建交易记录
@@ -163,7 +182,7 @@ func (wl *WalletLogic) DeductBalance(userID uint, amount float64, description st
 	transaction.Status = "completed"
 	err = wl.transactionRepo.UpdateTransaction(transaction)
 	if err != nil {
-		log.Printf("Failed to update transaction status: %v", err)
+		tracing.Printf(ctx, "Failed to update transaction status: %v", err)
 	}
 
 	// 发送Kafka事件
@@ -173,9 +192,9 @@ func (wl *WalletLogic) DeductBalance(userID uint, amount float64, description st
 		Description:   description,
 		TransactionID: transaction.ID,
 	}
-	err = wl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
+	err = wl.producer.SendMessage(ctx, kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
 	if err != nil {
-		log.Printf("Failed to send payment event: %v", err)
+		tracing.Printf(ctx, "Failed to send payment event: %v", err)
 	}
 
 	return transaction, nil
@@ -187,33 +206,33 @@ func (wl *WalletLogic) GetTransactions(userID uint) ([]*models.Transaction, erro
 }
 
 // HandlePaymentEvent 处理支付事件
-func (wl *WalletLogic) HandlePaymentEvent(msg *sarama.ConsumerMessage) error {
-	log.Printf("Received payment event: %s", string(msg.Value))
+func (wl *WalletLogic) HandlePaymentEvent(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	tracing.Printf(ctx, "Received payment event: %s", string(msg.Value))
 	// 这里可以处理来自其他服务的支付事件
 	return nil
 }
 
 // Transfer 转账
-func (wl *WalletLogic) Transfer(fromUserID, toUserID uint, amount float64, description string) error {
+func (wl *WalletLogic) Transfer(ctx context.Context, fromUserID, toUserID uint, amount float64, description string) error {
 	if amount <= 0 {
-		return fmt.Errorf("amount must be greater than 0")
+		return ErrInvalidAmount
 	}
 
 	if fromUserID == toUserID {
-		return fmt.Errorf("cannot transfer to yourself")
+		return ErrSameAccount
 	}
 
 	// 扣除发送方余额
-	_, err := wl.DeductBalance(fromUserID, amount, fmt.Sprintf("Transfer to user %d: %s", toUserID, description))
+	_, err := wl.DeductBalance(ctx, fromUserID, amount, fmt.Sprintf("Transfer to user %d: %s", toUserID, description))
 	if err != nil {
-		return fmt.Errorf("failed to deduct balance: %v", err)
+		return fmt.Errorf("failed to deduct balance: %w", err)
 	}
 
 	// 增加接收方余额
-	_, err = wl.AddBalance(toUserID, amount, fmt.Sprintf("Transfer from user %d: %s", fromUserID, description))
+	_, err = wl.AddBalance(ctx, toUserID, amount, fmt.Sprintf("Transfer from user %d: %s", fromUserID, description))
 	if err != nil {
 		// 如果增加失败，需要回滚扣除操作
-		log.Printf("Failed to add balance, transaction may be inconsistent: %v", err)
+		tracing.Printf(ctx, "Failed to add balance, transaction may be inconsistent: %v", err)
 		return fmt.Errorf("failed to add balance: %v", err)
 	}
 
@@ -221,12 +240,12 @@ func (wl *WalletLogic) Transfer(fromUserID, toUserID uint, amount float64, descr
 }
 
 // PurchaseProduct 购买商品（为商城模块准备）
-func (wl *WalletLogic) PurchaseProduct(userID uint, productID uint, quantity int, unitPrice float64, orderID *uint) (*models.Transaction, error) {
+func (wl *WalletLogic) PurchaseProduct(ctx context.Context, userID uint, productID uint, quantity int, unitPrice float64, orderID *uint) (*models.Transaction, error) {
 	if quantity <= 0 {
-		return nil, fmt.Errorf("quantity must be greater than 0")
+		return nil, fmt.Errorf("%w: quantity must be greater than 0", ErrInvalidAmount)
 	}
 	if unitPrice <= 0 {
-		return nil, fmt.Errorf("unit price must be greater than 0")
+		return nil, fmt.Errorf("%w: unit price must be greater than 0", ErrInvalidAmount)
 	}
 
 	totalAmount := float64(quantity) * unitPrice
@@ -239,7 +258,7 @@ func (wl *WalletLogic) PurchaseProduct(userID uint, productID uint, quantity int
 
 	// 检查余额是否足够
 	if wallet.Balance < totalAmount {
-		return nil, fmt.Errorf("insufficient balance: need %.2f, have %.2f", totalAmount, wallet.Balance)
+		return nil, fmt.Errorf("%w: need %.2f, have %.2f", ErrInsufficientBalance, totalAmount, wallet.Balance)
 	}
 
 	// 创建交易记录
@@ -274,7 +293,7 @@ func (wl *WalletLogic) PurchaseProduct(userID uint, productID uint, quantity int
 	transaction.Status = "completed"
 	err = wl.transactionRepo.UpdateTransaction(transaction)
 	if err != nil {
-		log.Printf("Failed to update transaction status: %v", err)
+		tracing.Printf(ctx, "Failed to update transaction status: %v", err)
 	}
 
 	// 发送Kafka事件
@@ -287,96 +306,172 @@ func (wl *WalletLogic) PurchaseProduct(userID uint, productID uint, quantity int
 		OrderID:       orderID,
 		Quantity:      quantity,
 	}
-	err = wl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
+	err = wl.producer.SendMessage(ctx, kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
 	if err != nil {
-		log.Printf("Failed to send payment event: %v", err)
+		tracing.Printf(ctx, "Failed to send payment event: %v", err)
 	}
 
 	return transaction, nil
 }
 
 // Refund 退款（为商城模块准备）
-func (wl *WalletLogic) Refund(transactionID uint, reason string) (*models.Transaction, error) {
+func (wl *WalletLogic) Refund(ctx context.Context, transactionID uint, reason string) (*models.Transaction, error) {
 	// 获取原始交易记录
 	originalTransaction, err := wl.transactionRepo.GetTransactionByID(transactionID)
 	if err != nil {
-		return nil, fmt.Errorf("transaction not found: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrTransactionNotFound, err)
 	}
 
 	// 检查是否已经退款过
 	if originalTransaction.Status == "refunded" {
-		return nil, fmt.Errorf("transaction already refunded")
+		return nil, ErrAlreadyRefunded
 	}
 
 	// 只允许退款购买类型的交易
 	if originalTransaction.Type != "purchase" {
-		return nil, fmt.Errorf("only purchase transactions can be refunded")
+		return nil, ErrNotRefundable
 	}
 
-	// 获取钱包
-	wallet, err := wl.GetWallet(originalTransaction.UserID)
+	refunds, err := wl.refundPurchases(ctx, originalTransaction.WalletID, []uint{transactionID}, reason)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get wallet: %v", err)
+		return nil, err
 	}
+	return refunds[0], nil
+}
 
-	// 创建退款交易记录
-	refundTransaction := &models.Transaction{
-		UserID:      originalTransaction.UserID,
-		WalletID:    originalTransaction.WalletID,
-		Type:        "refund",
-		Amount:      originalTransaction.Amount, // 退款金额等于原交易金额
-		Description: fmt.Sprintf("Refund for transaction %d: %s", transactionID, reason),
-		ProductID:   originalTransaction.ProductID, // 关联原商品
-		OrderID:     originalTransaction.OrderID,   // 关联原订单
-		Quantity:    originalTransaction.Quantity,
-		Status:      "pending",
+// refundPurchases 在锁定的钱包上退回同一钱包的购买交易并发送退款事件
+// 状态检查、标记已退款和退回余额在同一数据库事务中完成，并发的退款只有一个生效
+func (wl *WalletLogic) refundPurchases(ctx context.Context, walletID uint, transactionIDs []uint, reason string) ([]*models.Transaction, error) {
+	refunds, err := wl.walletRepo.RefundPurchases(ctx, walletID, transactionIDs, func(original *models.Transaction) *models.Transaction {
+		return &models.Transaction{
+			UserID:      original.UserID,
+			Type:        "refund",
+			Amount:      original.Amount, // 退款金额等于原交易金额
+			Description: fmt.Sprintf("Refund for transaction %d: %s", original.ID, reason),
+			ProductID:   original.ProductID, // 关联原商品
+			OrderID:     original.OrderID,   // 关联原订单
+			Quantity:    original.Quantity,
+		}
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrAlreadyRefunded):
+			return nil, fmt.Errorf("%w: %v", ErrAlreadyRefunded, err)
+		case errors.Is(err, repository.ErrNotRefundable):
+			return nil, fmt.Errorf("%w: %v", ErrNotRefundable, err)
+		}
+		return nil, fmt.Errorf("failed to refund: %v", err)
 	}
 
-	err = wl.transactionRepo.CreateTransaction(refundTransaction)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create refund transaction: %v", err)
+	// 发送Kafka退款事件
+	for _, refund := range refunds {
+		event := &models.PaymentEvent{
+			UserID:        refund.UserID,
+			Amount:        refund.Amount, // 正数表示退款收入
+			Description:   refund.Description,
+			TransactionID: refund.ID,
+			ProductID:     refund.ProductID,
+			OrderID:       refund.OrderID,
+			Quantity:      refund.Quantity,
+		}
+		err = wl.producer.SendMessage(ctx, kafka.TopicWalletPayment, fmt.Sprintf("refund-%d", refund.ID), event)
+		if err != nil {
+			tracing.Printf(ctx, "Failed to send refund event: %v", err)
+		}
 	}
 
-	// 退回余额
-	wallet.Balance += originalTransaction.Amount
-	err = wl.walletRepo.UpdateWallet(wallet)
-	if err != nil {
-		// 回滚退款交易状态
-		refundTransaction.Status = "failed"
-		wl.transactionRepo.UpdateTransaction(refundTransaction)
-		return nil, fmt.Errorf("failed to refund balance: %v", err)
+	return refunds, nil
+}
+
+// PurchaseOrder 支付订单，每个商品生成一条关联订单的购买交易
+// 扣款和交易记录在同一数据库事务中完成，订单要么全部支付，要么不扣款
+// 同一订单重复支付时返回已有的交易，不重复扣款；订单已退款时返回 ErrAlreadyRefunded
+func (wl *WalletLogic) PurchaseOrder(ctx context.Context, userID, orderID uint, items []PurchaseItem) ([]*models.Transaction, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: order has no items", ErrInvalidAmount)
 	}
 
-	// 更新退款交易状态
-	refundTransaction.Status = "completed"
-	err = wl.transactionRepo.UpdateTransaction(refundTransaction)
-	if err != nil {
-		log.Printf("Failed to update refund transaction status: %v", err)
+	transactions := make([]*models.Transaction, 0, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 || item.UnitPrice <= 0 {
+			return nil, fmt.Errorf("%w: invalid quantity or unit price for product %d", ErrInvalidAmount, item.ProductID)
+		}
+		productID := item.ProductID
+		transactions = append(transactions, &models.Transaction{
+			UserID:      userID,
+			Type:        "purchase",
+			Amount:      float64(item.Quantity) * item.UnitPrice,
+			Description: fmt.Sprintf("Purchase product %d, quantity: %d", item.ProductID, item.Quantity),
+			ProductID:   &productID,
+			OrderID:     &orderID,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	// 没有钱包时先创建，支付在锁定的钱包行上进行
+	if _, err := wl.GetWallet(userID); err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %v", err)
 	}
 
-	// 更新原交易状态为已退款
-	originalTransaction.Status = "refunded"
-	err = wl.transactionRepo.UpdateTransaction(originalTransaction)
+	transactions, existed, err := wl.walletRepo.PurchaseOrder(ctx, userID, orderID, transactions)
 	if err != nil {
-		log.Printf("Failed to update original transaction status: %v", err)
+		if errors.Is(err, repository.ErrInsufficientBalance) {
+			return nil, fmt.Errorf("%w: %v", ErrInsufficientBalance, err)
+		}
+		return nil, fmt.Errorf("failed to pay order %d: %v", orderID, err)
 	}
 
-	// 发送Kafka退款事件
-	event := &models.PaymentEvent{
-		UserID:        originalTransaction.UserID,
-		Amount:        originalTransaction.Amount, // 正数表示退款收入
-		Description:   refundTransaction.Description,
-		TransactionID: refundTransaction.ID,
-		ProductID:     originalTransaction.ProductID,
-		OrderID:       originalTransaction.OrderID,
-		Quantity:      originalTransaction.Quantity,
-	}
-	err = wl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("refund-%d", refundTransaction.ID), event)
+	if existed {
+		for _, transaction := range transactions {
+			if transaction.Status == "refunded" {
+				return nil, fmt.Errorf("%w: order %d", ErrAlreadyRefunded, orderID)
+			}
+		}
+		tracing.Printf(ctx, "Order %d already paid, returning %d existing transactions", orderID, len(transactions))
+		return transactions, nil
+	}
+
+	// 发送Kafka事件
+	for _, transaction := range transactions {
+		event := &models.PaymentEvent{
+			UserID:        userID,
+			Amount:        -transaction.Amount, // 负数表示支出
+			Description:   transaction.Description,
+			TransactionID: transaction.ID,
+			ProductID:     transaction.ProductID,
+			OrderID:       transaction.OrderID,
+			Quantity:      transaction.Quantity,
+		}
+		err = wl.producer.SendMessage(ctx, kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
+		if err != nil {
+			tracing.Printf(ctx, "Failed to send payment event: %v", err)
+		}
+	}
+
+	return transactions, nil
+}
+
+// RefundOrder 退回订单的全部已完成的购买交易，要么全部退款，要么都不退
+func (wl *WalletLogic) RefundOrder(ctx context.Context, orderID uint, reason string) ([]*models.Transaction, error) {
+	transactions, err := wl.transactionRepo.GetTransactionsByOrderID(orderID)
 	if err != nil {
-		log.Printf("Failed to send refund event: %v", err)
+		return nil, fmt.Errorf("failed to get order transactions: %v", err)
 	}
 
-	return refundTransaction, nil
+	var walletID uint
+	var transactionIDs []uint
+	for _, transaction := range transactions {
+		if transaction.Type != "purchase" || transaction.Status != "completed" {
+			continue
+		}
+		walletID = transaction.WalletID
+		transactionIDs = append(transactionIDs, transaction.ID)
+	}
+
+	if len(transactionIDs) == 0 {
+		return nil, fmt.Errorf("%w: no refundable payment for order %d", ErrTransactionNotFound, orderID)
+	}
+	return wl.refundPurchases(ctx, walletID, transactionIDs, reason)
 }
 
 // GetTransactionsByProductID 根据商品ID获取交易记录（为商城模块准备）
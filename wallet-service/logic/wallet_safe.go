@@ -3,9 +3,10 @@ package logic
 import (
 	"blog/shared/kafka"
 	"blog/shared/models"
+	"blog/shared/tracing"
 	"blog/wallet-service/repository"
+	"context"
 	"fmt"
-	"log"
 	"time"
 )
 
@@ -26,9 +27,9 @@ func NewSafeWalletLogic(walletRepo repository.WalletRepository, transactionRepo
 }
 
 // SafeAddBalance 安全的增加余额（原子操作）
-func (swl *SafeWalletLogic) SafeAddBalance(userID uint, amount float64, description string) (*models.Transaction, error) {
+func (swl *SafeWalletLogic) SafeAddBalance(ctx context.Context, userID uint, amount float64, description string) (*models.Transaction, error) {
 	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be greater than 0")
+		return nil, ErrInvalidAmount
 	}
 
 	// 使用安全的余额更新
@@ -50,12 +51,14 @@ func (swl *SafeWalletLogic) SafeAddBalance(userID uint, amount float64, descript
 
 	err = swl.transactionRepo.CreateTransaction(transaction)
 	if err != nil {
-		log.Printf("Failed to create transaction record: %v", err)
+		tracing.Printf(ctx, "Failed to create transaction record: %v", err)
 		// 交易已经完成，记录日志即可
 	}
 
 	// 异步发送Kafka事件（不影响主流程）
 	if swl.producer != nil {
+		// 请求结束后context会被取消，只保留链路信息
+		eventCtx := tracing.Detach(ctx)
 		go func() {
 			event := &models.PaymentEvent{
 				UserID:        userID,
@@ -63,9 +66,9 @@ func (swl *SafeWalletLogic) SafeAddBalance(userID uint, amount float64, descript
 				Description:   description,
 				TransactionID: transaction.ID,
 			}
-			err := swl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
+			err := swl.producer.SendMessage(eventCtx, kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
 			if err != nil {
-				log.Printf("Failed to send payment event: %v", err)
+				tracing.Printf(eventCtx, "Failed to send payment event: %v", err)
 			}
 		}()
 	}
@@ -74,9 +77,9 @@ func (swl *SafeWalletLogic) SafeAddBalance(userID uint, amount float64, descript
 }
 
 // SafeDeductBalance 安全的扣除余额（原子操作）
-func (swl *SafeWalletLogic) SafeDeductBalance(userID uint, amount float64, description string) (*models.Transaction, error) {
+func (swl *SafeWalletLogic) SafeDeductBalance(ctx context.Context, userID uint, amount float64, description string) (*models.Transaction, error) {
 	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be greater than 0")
+		return nil, ErrInvalidAmount
 	}
 
 	// 使用安全的余额更新
@@ -98,11 +101,12 @@ func (swl *SafeWalletLogic) SafeDeductBalance(userID uint, amount float64, descr
 
 	err = swl.transactionRepo.CreateTransaction(transaction)
 	if err != nil {
-		log.Printf("Failed to create transaction record: %v", err)
+		tracing.Printf(ctx, "Failed to create transaction record: %v", err)
 	}
 
 	// 异步发送Kafka事件
 	if swl.producer != nil {
+		eventCtx := tracing.Detach(ctx)
 		go func() {
 			event := &models.PaymentEvent{
 				UserID:        userID,
@@ -110,9 +114,9 @@ func (swl *SafeWalletLogic) SafeDeductBalance(userID uint, amount float64, descr
 				Description:   description,
 				TransactionID: transaction.ID,
 			}
-			err := swl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
+			err := swl.producer.SendMessage(eventCtx, kafka.TopicWalletPayment, fmt.Sprintf("%d", transaction.ID), event)
 			if err != nil {
-				log.Printf("Failed to send payment event: %v", err)
+				tracing.Printf(eventCtx, "Failed to send payment event: %v", err)
 			}
 		}()
 	}
@@ -121,13 +125,13 @@ func (swl *SafeWalletLogic) SafeDeductBalance(userID uint, amount float64, descr
 }
 
 // SafeTransfer 安全的转账（使用数据库事务保证原子性）
-func (swl *SafeWalletLogic) SafeTransfer(fromUserID, toUserID uint, amount float64, description string) error {
+func (swl *SafeWalletLogic) SafeTransfer(ctx context.Context, fromUserID, toUserID uint, amount float64, description string) error {
 	if amount <= 0 {
-		return fmt.Errorf("amount must be greater than 0")
+		return ErrInvalidAmount
 	}
 
 	if fromUserID == toUserID {
-		return fmt.Errorf("cannot transfer to yourself")
+		return ErrSameAccount
 	}
 
 	// 使用安全的转账方法（包含完整事务）
@@ -138,6 +142,7 @@ func (swl *SafeWalletLogic) SafeTransfer(fromUserID, toUserID uint, amount float
 
 	// 异步发送Kafka事件（转账已完成，记录日志即可）
 	if swl.producer != nil {
+		eventCtx := tracing.Detach(ctx)
 		go func() {
 			event := &models.PaymentEvent{
 				UserID:        fromUserID,
@@ -145,7 +150,7 @@ func (swl *SafeWalletLogic) SafeTransfer(fromUserID, toUserID uint, amount float
 				Description:   fmt.Sprintf("Transfer to user %d: %s", toUserID, description),
 				TransactionID: 0, // 事务中已创建
 			}
-			swl.producer.SendMessage(kafka.TopicWalletPayment, fmt.Sprintf("transfer-%d-%d", fromUserID, toUserID), event)
+			swl.producer.SendMessage(eventCtx, kafka.TopicWalletPayment, fmt.Sprintf("transfer-%d-%d", fromUserID, toUserID), event)
 		}()
 	}
 
@@ -0,0 +1,147 @@
+package logic
+
+import (
+	"blog/shared/models"
+	"blog/wallet-service/repository"
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestWalletLogic 使用临时的SQLite数据库创建钱包业务逻辑，不连接Kafka
+// SQLite不支持 FOR UPDATE，事务开始时即取得写锁，并发事务依次执行
+func newTestWalletLogic(t *testing.T) (*WalletLogic, *gorm.DB) {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "wallet.db") + "?_txlock=immediate&_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Wallet{}, &models.Transaction{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewWalletLogic(repository.NewWalletRepository(db), repository.NewTransactionRepository(db), nil), db
+}
+
+// payTestOrder 为用户充值并支付一个订单
+func payTestOrder(t *testing.T, wl *WalletLogic, userID, orderID uint, items []PurchaseItem) []*models.Transaction {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := wl.AddBalance(ctx, userID, 100, "recharge"); err != nil {
+		t.Fatal(err)
+	}
+	purchases, err := wl.PurchaseOrder(ctx, userID, orderID, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return purchases
+}
+
+func countRefunds(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.Transaction{}).Where("type = ?", "refund").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRefundConcurrent(t *testing.T) {
+	wl, db := newTestWalletLogic(t)
+	purchases := payTestOrder(t, wl, 1, 7, []PurchaseItem{{ProductID: 3, Quantity: 2, UnitPrice: 10}})
+
+	// 同时按交易和按订单退款，只有一个请求生效
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = wl.Refund(context.Background(), purchases[0].ID, "concurrent")
+			} else {
+				_, err = wl.RefundOrder(context.Background(), 7, "concurrent")
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrAlreadyRefunded), errors.Is(err, ErrTransactionNotFound):
+		default:
+			t.Errorf("unexpected refund error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d refunds succeeded, want 1", succeeded)
+	}
+
+	wallet, err := wl.GetWallet(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Balance != 100 {
+		t.Errorf("balance = %v, want 100", wallet.Balance)
+	}
+	if n := countRefunds(t, db); n != 1 {
+		t.Errorf("%d refund transactions recorded, want 1", n)
+	}
+}
+
+func TestRefundOrder(t *testing.T) {
+	wl, db := newTestWalletLogic(t)
+	purchases := payTestOrder(t, wl, 1, 7, []PurchaseItem{
+		{ProductID: 3, Quantity: 2, UnitPrice: 10},
+		{ProductID: 4, Quantity: 1, UnitPrice: 5},
+	})
+	ctx := context.Background()
+
+	// 单独退过的商品不再随订单退款
+	if _, err := wl.Refund(ctx, purchases[0].ID, "first item"); err != nil {
+		t.Fatal(err)
+	}
+	refunds, err := wl.RefundOrder(ctx, 7, "rest of order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Amount != 5 {
+		t.Fatalf("RefundOrder() = %+v, want one refund of 5", refunds)
+	}
+
+	if _, err := wl.RefundOrder(ctx, 7, "again"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("second RefundOrder() error = %v, want ErrTransactionNotFound", err)
+	}
+	if _, err := wl.Refund(ctx, purchases[1].ID, "again"); !errors.Is(err, ErrAlreadyRefunded) {
+		t.Errorf("second Refund() error = %v, want ErrAlreadyRefunded", err)
+	}
+
+	wallet, err := wl.GetWallet(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Balance != 100 {
+		t.Errorf("balance = %v, want 100", wallet.Balance)
+	}
+	if n := countRefunds(t, db); n != 2 {
+		t.Errorf("%d refund transactions recorded, want 2", n)
+	}
+}
@@ -1,23 +1,35 @@
 package main
 
 import (
+	"blog/shared/database"
+	"blog/shared/health"
 	"blog/shared/kafka"
+	"blog/shared/registry"
+	"blog/shared/serviceauth"
+	"blog/shared/userdata"
 	"blog/wallet-service/config"
 	"blog/wallet-service/controller"
 	"blog/wallet-service/logic"
 	"blog/wallet-service/repository"
+	"blog/wallet-service/rpc"
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// shutdownTimeout 关闭时等待处理中请求完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// 初始化配置
 	cfg := config.LoadConfig()
 
 	// 初始化数据库
 	db := repository.InitDB(cfg.Database.DSN)
+	defer database.Close(db)
 
 	// 初始化Kafka生产者
 	producer, err := kafka.NewProducer(cfg.Kafka.Brokers)
@@ -33,6 +45,19 @@ func main() {
 	}
 	defer consumer.Close()
 
+	// 个人数据请求使用消费者组，处理完成才提交位移，停机期间发送的请求重启后继续处理
+	dataGroup, err := kafka.NewConsumerGroup(cfg.Kafka.Brokers, "wallet-service")
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer group: %v", err)
+	}
+	defer dataGroup.Close()
+
+	// 导出的个人数据写入数据库，处理结果中只带导出的ID
+	exportStore, err := userdata.NewStore(db)
+	if err != nil {
+		log.Fatalf("Failed to init user data exports: %v", err)
+	}
+
 	// 初始化仓库
 	walletRepo := repository.NewWalletRepository(db)
 	transactionRepo := repository.NewTransactionRepository(db)
@@ -43,8 +68,22 @@ func main() {
 	// 初始化控制器
 	walletController := controller.NewWalletController(walletLogic)
 
+	// 就绪检查：MySQL和Kafka生产者
+	healthChecker := health.NewChecker("wallet-service")
+	healthChecker.AddCheck("mysql", health.DBCheck(db))
+	healthChecker.AddCheck("kafka", health.KafkaCheck(producer))
+
+	// 校验调用方的服务令牌
+	verifier, err := serviceauth.NewVerifier("wallet-service", cfg.ServiceAuth)
+	if err != nil {
+		log.Fatalf("Invalid service auth config: %v", err)
+	}
+
 	// 启动HTTP服务器
-	server := controller.NewServer(cfg.Server.Port, walletController)
+	server := controller.NewServer(cfg.Server.Port, walletController, verifier, healthChecker)
+
+	// 内部gRPC服务器，供商城等服务调用
+	grpcServer := rpc.NewServer(cfg.Server.GRPCPort, rpc.NewWalletServer(walletLogic), verifier)
 
 	// 启动Kafka消费者
 	go func() {
@@ -54,7 +93,17 @@ func main() {
 		}
 	}()
 
-	log.Printf("Wallet service starting on port %s", cfg.Server.Port)
+	// 个人数据导出和账户注销，处理结果发送给用户服务
+	userdata.Subscribe(dataGroup, userdata.Handler("wallet-service", producer, exportStore, walletLogic.ExportUserData, walletLogic.EraseUserData))
+
+	// 注册到服务注册中心，供网关发现
+	deregister, err := registry.RegisterLocal(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, "wallet-service", cfg.Server.Port)
+	if err != nil {
+		log.Printf("Failed to register service: %v, continuing without service registry", err)
+		deregister = func() {}
+	}
+
+	log.Printf("Wallet service starting on port %s, gRPC on port %s", cfg.Server.Port, cfg.Server.GRPCPort)
 
 	// 在goroutine中启动服务器
 	go func() {
@@ -62,6 +111,11 @@ func main() {
 			log.Fatalf("Failed to start Wallet service: %v", err)
 		}
 	}()
+	go func() {
+		if err := grpcServer.Start(); err != nil {
+			log.Fatalf("Failed to start Wallet gRPC service: %v", err)
+		}
+	}()
 
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
@@ -69,5 +123,21 @@ func main() {
 	<-quit
 
 	log.Println("Wallet service shutting down...")
-	server.Shutdown()
+
+	// 先摘除实例：就绪检查返回503并从注册中心注销，等待网关刷新实例列表后不再转发新请求
+	healthChecker.SetShuttingDown()
+	deregister()
+	time.Sleep(registry.DeregisterDelay)
+
+	// 等待处理中的请求完成
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Wallet service forced to shutdown: %v", err)
+	}
+	if err := grpcServer.Shutdown(ctx); err != nil {
+		log.Printf("Wallet gRPC service forced to shutdown: %v", err)
+	}
+
+	// 返回后按注册的相反顺序执行defer：停止Kafka消费者、刷新并关闭生产者、关闭数据库连接池
 }
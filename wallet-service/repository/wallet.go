@@ -4,12 +4,14 @@ import (
 	"blog/shared/models"
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // WalletRepository 钱包仓库接口
@@ -19,8 +21,17 @@ type WalletRepository interface {
 	UpdateWallet(wallet *models.Wallet) error
 	SafeUpdateWalletBalance(userID uint, amount float64, operation string) (*models.Wallet, error)
 	SafeTransfer(fromUserID, toUserID uint, amount float64, description string) error
+	PurchaseOrder(ctx context.Context, userID, orderID uint, transactions []*models.Transaction) ([]*models.Transaction, bool, error)
+	RefundPurchases(ctx context.Context, walletID uint, transactionIDs []uint, newRefund func(original *models.Transaction) *models.Transaction) ([]*models.Transaction, error)
 }
 
+// 支付和退款在事务中发现的业务错误
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrAlreadyRefunded     = errors.New("transaction already refunded")
+	ErrNotRefundable       = errors.New("only completed purchase transactions can be refunded")
+)
+
 // TransactionRepository 交易仓库接口
 type TransactionRepository interface {
 	CreateTransaction(transaction *models.Transaction) error
@@ -29,6 +40,7 @@ type TransactionRepository interface {
 	GetTransactionsByProductID(productID uint) ([]*models.Transaction, error)
 	GetTransactionsByOrderID(orderID uint) ([]*models.Transaction, error)
 	UpdateTransaction(transaction *models.Transaction) error
+	ClearUserDescriptions(userID uint) (int64, error)
 }
 
 // walletRepository 钱包仓库实现
@@ -156,6 +168,14 @@ func (r *transactionRepository) UpdateTransaction(transaction *models.Transactio
 	return r.db.Save(transaction).Error
 }
 
+// ClearUserDescriptions 清空用户所有交易记录的说明，返回修改的记录数
+func (r *transactionRepository) ClearUserDescriptions(userID uint) (int64, error) {
+	result := r.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND description <> ''", userID).
+		Update("description", "")
+	return result.RowsAffected, result.Error
+}
+
 // SafeUpdateWalletBalance 安全的余额更新（使用数据库事务和锁）
 func (r *walletRepository) SafeUpdateWalletBalance(userID uint, amount float64, operation string) (*models.Wallet, error) {
 	var wallet models.Wallet
@@ -211,6 +231,121 @@ func (r *walletRepository) SafeUpdateWalletBalance(userID uint, amount float64,
 	return &wallet, nil
 }
 
+// PurchaseOrder 在同一事务中锁定钱包、检查余额、扣款并创建订单的全部购买交易，任一步失败时整体回滚
+// 订单已有购买交易（已完成或已退款）时不重复扣款，返回已有的交易和true
+// 事务使用调用方的ctx，调用方超时后未提交的扣款随之回滚
+func (r *walletRepository) PurchaseOrder(ctx context.Context, userID, orderID uint, transactions []*models.Transaction) ([]*models.Transaction, bool, error) {
+	var totalAmount float64
+	for _, transaction := range transactions {
+		totalAmount += transaction.Amount
+	}
+
+	var existing []*models.Transaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定钱包行，同一用户的支付串行执行，重复的支付请求在这里等待前一个提交
+		var wallet models.Wallet
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).
+			First(&wallet).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("order_id = ? AND type = ? AND status IN ?", orderID, "purchase", []string{"completed", "refunded"}).
+			Order("id").Find(&existing).Error
+		if err != nil || len(existing) > 0 {
+			return err
+		}
+
+		if wallet.Balance < totalAmount {
+			return fmt.Errorf("%w: need %.2f, have %.2f", ErrInsufficientBalance, totalAmount, wallet.Balance)
+		}
+
+		for _, transaction := range transactions {
+			transaction.WalletID = wallet.ID
+			transaction.Status = "completed"
+		}
+		if err := tx.Create(&transactions).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Wallet{}).
+			Where("id = ?", wallet.ID).
+			Update("balance", gorm.Expr("balance - ?", totalAmount)).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(existing) > 0 {
+		return existing, true, nil
+	}
+	return transactions, false, nil
+}
+
+// RefundPurchases 在同一事务中锁定钱包和原购买交易，把原交易标记为已退款、创建退款交易并退回余额，任一步失败时整体回滚
+// newRefund 根据锁定后读取的原交易生成退款交易；原交易已退款（包括被并发的请求退款）时返回 ErrAlreadyRefunded
+func (r *walletRepository) RefundPurchases(ctx context.Context, walletID uint, transactionIDs []uint, newRefund func(original *models.Transaction) *models.Transaction) ([]*models.Transaction, error) {
+	var refunds []*models.Transaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先锁钱包再锁交易，与支付的加锁顺序一致；同一钱包的退款和支付串行执行
+		var wallet models.Wallet
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, walletID).Error
+		if err != nil {
+			return err
+		}
+
+		var originals []*models.Transaction
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND wallet_id = ?", transactionIDs, walletID).
+			Order("id").Find(&originals).Error
+		if err != nil {
+			return err
+		}
+		if len(originals) != len(transactionIDs) {
+			return fmt.Errorf("%w: transaction not found in wallet %d", ErrNotRefundable, walletID)
+		}
+
+		var totalAmount float64
+		refunds = make([]*models.Transaction, 0, len(originals))
+		for _, original := range originals {
+			if original.Status == "refunded" {
+				return fmt.Errorf("%w: transaction %d", ErrAlreadyRefunded, original.ID)
+			}
+			if original.Type != "purchase" || original.Status != "completed" {
+				return fmt.Errorf("%w: transaction %d is %s %s", ErrNotRefundable, original.ID, original.Status, original.Type)
+			}
+
+			// 只把仍为已完成的交易改为已退款，同一交易不会退款两次
+			result := tx.Model(&models.Transaction{}).
+				Where("id = ? AND status = ?", original.ID, "completed").
+				Update("status", "refunded")
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: transaction %d", ErrAlreadyRefunded, original.ID)
+			}
+
+			refund := newRefund(original)
+			refund.WalletID = wallet.ID
+			refund.Status = "completed"
+			refunds = append(refunds, refund)
+			totalAmount += refund.Amount
+		}
+		if err := tx.Create(&refunds).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Wallet{}).
+			Where("id = ?", wallet.ID).
+			Update("balance", gorm.Expr("balance + ?", totalAmount)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
 // SafeTransfer 安全的转账（使用数据库事务）
 func (r *walletRepository) SafeTransfer(fromUserID, toUserID uint, amount float64, description string) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
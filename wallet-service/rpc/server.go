@@ -0,0 +1,222 @@
+package rpc
+
+import (
+	"blog/shared/models"
+	walletpb "blog/shared/proto/wallet"
+	"blog/shared/serviceauth"
+	"blog/shared/tracing"
+	"blog/wallet-service/logic"
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WalletServer 钱包服务的gRPC接口实现
+type WalletServer struct {
+	walletpb.UnimplementedWalletServiceServer
+	walletLogic *logic.WalletLogic
+}
+
+// NewWalletServer 创建gRPC接口实现
+func NewWalletServer(walletLogic *logic.WalletLogic) *WalletServer {
+	return &WalletServer{walletLogic: walletLogic}
+}
+
+// Deduct 扣除余额
+func (ws *WalletServer) Deduct(ctx context.Context, req *walletpb.DeductRequest) (*walletpb.Transaction, error) {
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	transaction, err := ws.walletLogic.DeductBalance(ctx, uint(req.GetUserId()), req.GetAmount(), req.GetDescription())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(transaction), nil
+}
+
+// Add 增加余额
+func (ws *WalletServer) Add(ctx context.Context, req *walletpb.AddRequest) (*walletpb.Transaction, error) {
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	transaction, err := ws.walletLogic.AddBalance(ctx, uint(req.GetUserId()), req.GetAmount(), req.GetDescription())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(transaction), nil
+}
+
+// Purchase 支付订单
+func (ws *WalletServer) Purchase(ctx context.Context, req *walletpb.PurchaseRequest) (*walletpb.PurchaseReply, error) {
+	if req.GetUserId() == 0 || req.GetOrderId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id and order_id are required")
+	}
+
+	items := make([]logic.PurchaseItem, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		items = append(items, logic.PurchaseItem{
+			ProductID: uint(item.GetProductId()),
+			Quantity:  int(item.GetQuantity()),
+			UnitPrice: item.GetUnitPrice(),
+		})
+	}
+
+	transactions, err := ws.walletLogic.PurchaseOrder(ctx, uint(req.GetUserId()), uint(req.GetOrderId()), items)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	reply := &walletpb.PurchaseReply{Transactions: toProtoList(transactions)}
+	for _, transaction := range transactions {
+		reply.TotalAmount += transaction.Amount
+	}
+	return reply, nil
+}
+
+// Refund 按交易ID或订单ID退款
+func (ws *WalletServer) Refund(ctx context.Context, req *walletpb.RefundRequest) (*walletpb.RefundReply, error) {
+	switch target := req.GetTarget().(type) {
+	case *walletpb.RefundRequest_TransactionId:
+		transaction, err := ws.walletLogic.Refund(ctx, uint(target.TransactionId), req.GetReason())
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		return &walletpb.RefundReply{Transactions: []*walletpb.Transaction{toProto(transaction)}}, nil
+	case *walletpb.RefundRequest_OrderId:
+		transactions, err := ws.walletLogic.RefundOrder(ctx, uint(target.OrderId), req.GetReason())
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		return &walletpb.RefundReply{Transactions: toProtoList(transactions)}, nil
+	default:
+		return nil, status.Error(codes.InvalidArgument, "transaction_id or order_id is required")
+	}
+}
+
+// Transfer 转账
+func (ws *WalletServer) Transfer(ctx context.Context, req *walletpb.TransferRequest) (*walletpb.TransferReply, error) {
+	if req.GetFromUserId() == 0 || req.GetToUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "from_user_id and to_user_id are required")
+	}
+
+	err := ws.walletLogic.Transfer(ctx, uint(req.GetFromUserId()), uint(req.GetToUserId()), req.GetAmount(), req.GetDescription())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &walletpb.TransferReply{}, nil
+}
+
+// toStatus 把业务错误转换为带错误码的gRPC错误，未知错误返回Internal
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, logic.ErrInsufficientBalance):
+		return walletpb.NewError(codes.FailedPrecondition, walletpb.ErrorCode_ERROR_CODE_INSUFFICIENT_FUNDS, err.Error())
+	case errors.Is(err, logic.ErrInvalidAmount):
+		return walletpb.NewError(codes.InvalidArgument, walletpb.ErrorCode_ERROR_CODE_INVALID_ARGUMENT, err.Error())
+	case errors.Is(err, logic.ErrSameAccount):
+		return walletpb.NewError(codes.InvalidArgument, walletpb.ErrorCode_ERROR_CODE_SAME_ACCOUNT, err.Error())
+	case errors.Is(err, logic.ErrTransactionNotFound):
+		return walletpb.NewError(codes.NotFound, walletpb.ErrorCode_ERROR_CODE_TRANSACTION_NOT_FOUND, err.Error())
+	case errors.Is(err, logic.ErrAlreadyRefunded):
+		return walletpb.NewError(codes.FailedPrecondition, walletpb.ErrorCode_ERROR_CODE_ALREADY_REFUNDED, err.Error())
+	case errors.Is(err, logic.ErrNotRefundable):
+		return walletpb.NewError(codes.FailedPrecondition, walletpb.ErrorCode_ERROR_CODE_NOT_REFUNDABLE, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// toProto 转换交易记录
+func toProto(t *models.Transaction) *walletpb.Transaction {
+	pb := &walletpb.Transaction{
+		Id:          uint64(t.ID),
+		UserId:      uint64(t.UserID),
+		WalletId:    uint64(t.WalletID),
+		Type:        t.Type,
+		Amount:      t.Amount,
+		Description: t.Description,
+		Quantity:    int32(t.Quantity),
+		Status:      t.Status,
+		CreatedAt:   t.CreatedAt.Unix(),
+	}
+	if t.ProductID != nil {
+		pb.ProductId = uint64(*t.ProductID)
+	}
+	if t.OrderID != nil {
+		pb.OrderId = uint64(*t.OrderID)
+	}
+	return pb
+}
+
+// toProtoList 转换交易记录列表
+func toProtoList(transactions []*models.Transaction) []*walletpb.Transaction {
+	result := make([]*walletpb.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		result = append(result, toProto(t))
+	}
+	return result
+}
+
+// Server gRPC服务器
+type Server struct {
+	port       string
+	grpcServer *grpc.Server
+}
+
+// NewServer 创建gRPC服务器，只接受已授权服务的调用
+func NewServer(port string, walletServer *WalletServer, verifier *serviceauth.Verifier) *Server {
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		tracing.UnaryServerInterceptor(),
+		verifier.UnaryServerInterceptor(callerACL()),
+	))
+	walletpb.RegisterWalletServiceServer(grpcServer, walletServer)
+
+	return &Server{port: port, grpcServer: grpcServer}
+}
+
+// callerACL 各调用方可以调用的gRPC方法
+// 商城服务支付订单，取消已支付订单时退款
+func callerACL() *serviceauth.ACL {
+	return serviceauth.NewACL().
+		Allow("shop-service",
+			"POST "+walletpb.WalletService_Purchase_FullMethodName,
+			"POST "+walletpb.WalletService_Refund_FullMethodName,
+		)
+}
+
+// Start 启动服务器
+// 调用Shutdown后返回nil
+func (s *Server) Start() error {
+	lis, err := net.Listen("tcp", ":"+s.port)
+	if err != nil {
+		return err
+	}
+	err = s.grpcServer.Serve(lis)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 优雅关闭：停止接受新调用，等待处理中的调用完成，ctx到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}